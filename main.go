@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-co-op/gocron"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/acastle/esperbot/pkg/bot"
+	"github.com/acastle/esperbot/pkg/config"
+	"github.com/acastle/esperbot/pkg/metrics"
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-redis/redis"
 )
@@ -18,20 +24,45 @@ func main() {
 		FullTimestamp: true,
 	})
 
-	var botToken = os.Getenv("BOT_TOKEN")
-	var redisAddr = os.Getenv("REDIS_ADDR")
-	session, err := discordgo.New("Bot " + botToken)
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a JSON configuration file")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = cfg.ConfigureLogger()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	session, err := discordgo.New("Bot " + cfg.Discord.Token)
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	rd := redis.NewClient(&redis.Options{
-		Addr: redisAddr,
+		Addr:     cfg.Redis.Addr,
+		Password: cfg.Redis.Password,
+		DB:       cfg.Redis.DB,
 	})
+	metrics.InstrumentRedis(rd)
 
-	instance, err := bot.NewBot(session, rd, gocron.NewScheduler(time.UTC))
+	instance, err := bot.NewBot(cfg, session, rd, gocron.NewScheduler(time.UTC))
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	err = instance.Run()
+	ctx, cancel := context.WithCancel(context.Background())
+	sc := make(chan os.Signal, 1)
+	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
+	go func() {
+		<-sc
+		cancel()
+	}()
+
+	err = instance.Run(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
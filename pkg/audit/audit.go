@@ -0,0 +1,182 @@
+package audit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/go-redis/redis"
+)
+
+// Source describes how a change was made
+type Source string
+
+const (
+	SourceReaction  Source = "reaction"
+	SourceButton    Source = "button"
+	SourceCommand   Source = "command"
+	SourceDM        Source = "dm"
+	SourceScheduler Source = "scheduler"
+	SourceConfig    Source = "config"
+	// SourceGuild is a change made in Discord, such as a nickname change
+	SourceGuild Source = "guild"
+	// SourceReconcile is a reaction made while the bot was not connected and applied once it was
+	SourceReconcile Source = "reconcile"
+)
+
+// Action describes what was changed
+type Action string
+
+const (
+	AttendanceAdd    Action = "attendance.add"
+	AttendanceRemove Action = "attendance.remove"
+	RuleAdd          Action = "rule.add"
+	RuleRemove       Action = "rule.remove"
+	AliasSet         Action = "alias.set"
+	AliasReset       Action = "alias.reset"
+	PrefixSet        Action = "prefix.set"
+	CharacterAdd     Action = "character.add"
+	CharacterRemove  Action = "character.remove"
+	EventSchedule    Action = "event.schedule"
+	EventAnnounce    Action = "event.announce"
+	TemplateUpsert   Action = "template.upsert"
+	TemplateEmbed    Action = "template.embed"
+	SummaryPin       Action = "summary.pin"
+)
+
+// MaxEntries bounds the length of each audit stream, the oldest entries are trimmed once it is exceeded
+const MaxEntries = 10000
+
+// Entry is a single change recorded in the audit log. Actor is the id of the member who made the change and
+// is empty for changes made by the bot itself. Target is the id of the member or the template the change
+// applies to.
+type Entry struct {
+	ID     string
+	Time   time.Time
+	Actor  string
+	Target string
+	Source Source
+	Action Action
+	// Events lists the ids of the events affected by the change
+	Events []string
+	// Detail describes the change, for example the list and date range marked
+	Detail string
+}
+
+// StreamKey holds every entry in the audit log
+const StreamKey = "audit"
+
+// StreamKeyForEvent holds the entries affecting an event, it expires events.EventTTL after its last entry
+func StreamKeyForEvent(eventID string) string {
+	return fmt.Sprintf("audit:event:%s", eventID)
+}
+
+// StreamKeyForUser holds the entries made by or applying to a member
+func StreamKeyForUser(userID string) string {
+	return fmt.Sprintf("audit:user:%s", userID)
+}
+
+// Record appends an entry to the audit log. The entry is also appended to the streams of its actor, its target
+// and each of its events so that they can be queried without scanning the whole log.
+func Record(r *redis.Client, entry Entry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	values := map[string]interface{}{
+		"time":   entry.Time.UTC().Unix(),
+		"actor":  entry.Actor,
+		"target": entry.Target,
+		"source": string(entry.Source),
+		"action": string(entry.Action),
+		"events": strings.Join(entry.Events, ","),
+		"detail": entry.Detail,
+	}
+
+	streams := []string{StreamKey}
+	if entry.Actor != "" {
+		streams = append(streams, StreamKeyForUser(entry.Actor))
+	}
+	if entry.Target != "" && entry.Target != entry.Actor {
+		streams = append(streams, StreamKeyForUser(entry.Target))
+	}
+	for _, id := range entry.Events {
+		streams = append(streams, StreamKeyForEvent(id))
+	}
+
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		for _, stream := range streams {
+			pipe.XAdd(&redis.XAddArgs{
+				Stream:       stream,
+				MaxLenApprox: MaxEntries,
+				Values:       values,
+			})
+		}
+		for _, id := range entry.Events {
+			pipe.Expire(StreamKeyForEvent(id), events.EventTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("append audit entry: %w", err)
+	}
+
+	return nil
+}
+
+// Recent returns up to count of the most recent entries in the log, newest first
+func Recent(r *redis.Client, count int64) ([]Entry, error) {
+	return read(r, StreamKey, count)
+}
+
+// ForEvent returns up to count of the most recent entries affecting an event, newest first
+func ForEvent(r *redis.Client, eventID string, count int64) ([]Entry, error) {
+	return read(r, StreamKeyForEvent(eventID), count)
+}
+
+// ForUser returns up to count of the most recent entries made by or applying to a member, newest first
+func ForUser(r *redis.Client, userID string, count int64) ([]Entry, error) {
+	return read(r, StreamKeyForUser(userID), count)
+}
+
+func read(r *redis.Client, stream string, count int64) ([]Entry, error) {
+	msgs, err := r.XRevRangeN(stream, "+", "-", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("read audit stream: %w", err)
+	}
+
+	entries := make([]Entry, len(msgs))
+	for i, msg := range msgs {
+		entries[i] = parseEntry(msg)
+	}
+
+	return entries, nil
+}
+
+func parseEntry(msg redis.XMessage) Entry {
+	str := func(field string) string {
+		v, _ := msg.Values[field].(string)
+		return v
+	}
+
+	entry := Entry{
+		ID:     msg.ID,
+		Actor:  str("actor"),
+		Target: str("target"),
+		Source: Source(str("source")),
+		Action: Action(str("action")),
+		Detail: str("detail"),
+	}
+
+	if events := str("events"); events != "" {
+		entry.Events = strings.Split(events, ",")
+	}
+
+	if unix, err := strconv.ParseInt(str("time"), 10, 64); err == nil {
+		entry.Time = time.Unix(unix, 0).UTC()
+	}
+
+	return entry
+}
@@ -0,0 +1,84 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestRecord(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	now := time.Date(2021, 8, 25, 12, 0, 0, 0, time.UTC)
+	byOfficer := Entry{
+		Time:   now,
+		Actor:  "officer",
+		Target: "member",
+		Source: SourceCommand,
+		Action: AttendanceAdd,
+		Events: []string{"wed", "thu"},
+		Detail: "absent Aug 25 2021 to Aug 26 2021",
+	}
+	byMember := Entry{
+		Time:   now.Add(time.Minute),
+		Actor:  "member",
+		Target: "member",
+		Source: SourceReaction,
+		Action: AttendanceRemove,
+		Events: []string{"wed"},
+		Detail: "absent",
+	}
+
+	for _, entry := range []Entry{byOfficer, byMember} {
+		err = Record(client, entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name       string
+		read       func() ([]Entry, error)
+		expEntries []Entry
+	}{
+		{"recent", func() ([]Entry, error) { return Recent(client, 10) }, []Entry{byMember, byOfficer}},
+		{"limited", func() ([]Entry, error) { return Recent(client, 1) }, []Entry{byMember}},
+		{"event", func() ([]Entry, error) { return ForEvent(client, "thu", 10) }, []Entry{byOfficer}},
+		{"actor", func() ([]Entry, error) { return ForUser(client, "officer", 10) }, []Entry{byOfficer}},
+		{"target", func() ([]Entry, error) { return ForUser(client, "member", 10) }, []Entry{byMember, byOfficer}},
+		{"unknown event", func() ([]Entry, error) { return ForEvent(client, "fri", 10) }, []Entry{}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			entries, err := c.read()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := range entries {
+				entries[i].ID = ""
+			}
+
+			if !reflect.DeepEqual(entries, c.expEntries) {
+				t.Errorf("expected '%v' got '%v'", c.expEntries, entries)
+			}
+		})
+	}
+
+	if ttl := svc.TTL(StreamKeyForEvent("wed")); ttl != events.EventTTL {
+		t.Errorf("expected the event stream to expire after '%v' got '%v'", events.EventTTL, ttl)
+	}
+}
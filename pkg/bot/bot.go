@@ -1,211 +1,582 @@
 package bot
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net/http"
 	"os"
-	"os/signal"
-	"syscall"
+	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-co-op/gocron"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/acastle/esperbot/pkg/audit"
 	"github.com/acastle/esperbot/pkg/commands"
+	"github.com/acastle/esperbot/pkg/config"
+	"github.com/acastle/esperbot/pkg/discord"
 	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/lease"
+	"github.com/acastle/esperbot/pkg/metrics"
 	"github.com/acastle/esperbot/pkg/parser"
+	"github.com/acastle/esperbot/pkg/util"
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-redis/redis"
+	"github.com/google/uuid"
 )
 
+// Bot connects esperbot to Discord. Several replicas may run at once for availability, only the replica
+// holding the leader lease responds to Discord events and runs scheduled jobs.
 type Bot struct {
 	session   *discordgo.Session
+	messenger discord.Messenger
 	redis     *redis.Client
 	scheduler *gocron.Scheduler
-
-	channelID string
+	elector   *lease.Elector
+	refresher *events.Refresher
+	config    config.Config
+	settings  events.Settings
+
+	mu              sync.RWMutex
+	stopping        bool
+	inFlight        sync.WaitGroup
+	lastScheduleRun time.Time
 }
 
-func NewBot(session *discordgo.Session, redis *redis.Client, scheduler *gocron.Scheduler) (*Bot, error) {
-	return &Bot{
+const LeaseName string = "esperbot"
+
+func NewBot(cfg config.Config, session *discordgo.Session, redis *redis.Client, scheduler *gocron.Scheduler) (*Bot, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("get hostname: %w", err)
+	}
+
+	messenger := discord.NewSession(session)
+	settings := events.Settings{
+		GuildID: cfg.Discord.GuildID,
+		Theme:   cfg.Theme(),
+	}
+	b := &Bot{
 		session:   session,
+		messenger: messenger,
+		refresher: events.NewRefresher(messenger, redis, settings, events.DefaultRefreshWindow),
 		redis:     redis,
 		scheduler: scheduler,
-	}, nil
+		config:    cfg,
+		settings:  settings,
+		elector:   lease.NewElector(redis, lease.KeyForLease(LeaseName), fmt.Sprintf("%s-%s", hostname, uuid.New().String()), lease.DefaultTTL),
+	}
+	b.elector.OnElected = b.onElected
+	return b, nil
 }
 
-const GuildID string = "256295245816397824"
-const ChannelID string = "256297257052274688"
-
-func (b *Bot) Run() error {
+// Run connects to Discord and handles events until the context is canceled, then shuts down in order: the
+// scheduler is stopped, handlers are removed, in-flight commands and jobs are drained, pending announcement
+// refreshes are flushed, the leader lease is released, the health and metrics server is stopped and finally
+// the Discord session and Redis client are closed.
+func (b *Bot) Run(ctx context.Context) error {
 	log.Info("starting esperbot")
-	defer b.session.Close()
-	b.session.AddHandler(b.handleMessage)
-	b.session.AddHandler(b.handleReactionAdd)
-	b.session.AddHandler(b.handleReactionRemove)
-	b.session.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsGuildMessages | discordgo.IntentsDirectMessages | discordgo.IntentsDirectMessageReactions | discordgo.IntentsGuildMessageReactions)
-
-	b.scheduler.StartAsync()
+	// IntentsGuildMembers is privileged, see config.DiscordConfig
+	b.session.Identify.Intents = discordgo.MakeIntent(discordgo.IntentsGuildMessages | discordgo.IntentsGuildMembers | discordgo.IntentsDirectMessages | discordgo.IntentsDirectMessageReactions | discordgo.IntentsGuildMessageReactions)
+	removeHandlers := []func(){
+		b.session.AddHandler(b.handleMessage),
+		b.session.AddHandler(b.handleReactionAdd),
+		b.session.AddHandler(b.handleReactionRemove),
+		b.session.AddHandler(b.handleMemberUpdate),
+		b.session.AddHandler(b.handleMemberAdd),
+		b.session.AddHandler(b.handleMemberRemove),
+		b.session.AddHandler(b.handleMembersChunk),
+		b.session.AddHandler(b.handleRawEvent),
+		b.session.AddHandler(b.handleMessageDelete),
+	}
 
 	err := b.session.Open()
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("open discord session: %w", err)
 	}
 
 	b.session.State.User, err = b.session.User("@me")
 	if err != nil {
-		log.Fatal(err)
+		b.session.Close()
+		return fmt.Errorf("get bot user: %w", err)
 	}
 
-	events.UpsertRecurringEvent(b.redis, events.RecurringEvent{
-		ID:       "MainRaid",
-		Name:     "Main Raid",
-		Weekdays: []time.Weekday{time.Wednesday},
-	})
+	// Templates are stored by the leader once elected, the configuration is checked here so that a replica
+	// with an invalid one never starts
+	_, err = b.config.RecurringEvents()
+	if err != nil {
+		b.session.Close()
+		return fmt.Errorf("get configured templates: %w", err)
+	}
 
-	b.scheduler.Every(1).Day().Do(b.scheduleEvents)
-	b.scheduleEvents()
+	// Events are scheduled as soon as this replica is elected leader and daily after that
+	_, err = b.scheduler.Every(1).Day().Do(b.scheduleEvents)
+	if err != nil {
+		b.session.Close()
+		return fmt.Errorf("schedule job: %w", err)
+	}
 
-	log.Printf(`Now running. Press CTRL-C to exit.`)
-	sc := make(chan os.Signal, 1)
-	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt, os.Kill)
-	<-sc
+	// Tentative members are asked to confirm once an event is less than a day away
+	_, err = b.scheduler.Every(1).Hour().Do(b.nudgeTentative)
+	if err != nil {
+		b.session.Close()
+		return fmt.Errorf("schedule job: %w", err)
+	}
+
+	// Summaries move on to the new week as it begins
+	_, err = b.scheduler.Every(1).Sunday().At("00:00").Do(b.refreshSummaries)
+	if err != nil {
+		b.session.Close()
+		return fmt.Errorf("schedule job: %w", err)
+	}
 
+	b.scheduler.StartAsync()
+	stopElector := make(chan struct{})
+	electorStopped := make(chan struct{})
+	go func() {
+		b.elector.Run(stopElector)
+		close(electorStopped)
+	}()
+
+	var server *http.Server
+	if b.config.HTTP.Addr != "" {
+		server = b.newHTTPServer(b.config.HTTP.Addr)
+		go func() {
+			log.WithField("addr", server.Addr).Info("serving health and metrics")
+			err := server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				log.Error(err)
+			}
+		}()
+	}
+
+	log.Info("now running")
+	<-ctx.Done()
+	log.Info("shutting down")
+
+	b.scheduler.Stop()
+	b.scheduler.Clear()
+	for _, remove := range removeHandlers {
+		remove()
+	}
+
+	drainErr := b.drain(ShutdownTimeout)
+	if drainErr != nil {
+		log.Error(drainErr)
+	}
+
+	b.refresher.Flush()
+
+	close(stopElector)
+	<-electorStopped
+
+	if server != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		err := server.Shutdown(shutdownCtx)
+		cancel()
+		if err != nil {
+			log.Error(err)
+		}
+	}
+
+	sessionErr := b.session.Close()
+	if sessionErr != nil {
+		sessionErr = fmt.Errorf("close discord session: %w", sessionErr)
+	}
+
+	redisErr := b.redis.Close()
+	if redisErr != nil {
+		redisErr = fmt.Errorf("close redis: %w", redisErr)
+	}
+
+	for _, err := range []error{drainErr, sessionErr, redisErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("shutdown complete")
 	return nil
 }
 
-const FutureWeeksToSchedule int = 3
+// onElected runs when this replica becomes the leader. Templates are stored from this replica's configuration
+// and the nickname cache is primed from the guild before any events are scheduled so that announcements render
+// without querying Discord for each member, and reactions made while no replica was leading are applied.
+func (b *Bot) onElected() {
+	b.upsertTemplates()
+	b.primeMembers()
+	b.reconcileReactions()
+	b.scheduleEvents()
+}
 
-func (b *Bot) scheduleEvents() {
-	if time.Now().Weekday() <= time.Thursday {
+// upsertTemplates stores the configured templates, recording those that changed in the audit log. Only the
+// leader replica stores templates so that each change is recorded once.
+func (b *Bot) upsertTemplates() {
+	if !b.elector.IsLeader() || !b.begin() {
 		return
 	}
+	defer b.done()
 
-	allEvents := []events.Event{}
-	for i := 0; i < FutureWeeksToSchedule; i++ {
-		err := events.ScheduleEventsForWeek(b.redis, time.Now().AddDate(0, 0, 7*i))
+	templates, err := b.config.RecurringEvents()
+	if err != nil {
+		metrics.ErrorsTotal.Inc("template")
+		log.Error(err)
+		return
+	}
+
+	for _, template := range templates {
+		changed, err := events.UpsertRecurringEventIfChanged(b.redis, template)
 		if err != nil {
-			log.Error(err)
+			metrics.ErrorsTotal.Inc("template")
+			log.WithField("template", template.ID).Error(err)
 			continue
 		}
 
-		weekEvents, err := events.GetEventsForWeek(b.redis, time.Now().UTC())
-		if err != nil {
-			log.Error(err)
+		if !changed {
 			continue
 		}
 
-		allEvents = append(allEvents, weekEvents...)
+		log.WithField("template", template.ID).Info("stored changed template")
+		b.record(audit.Entry{
+			Target: template.ID,
+			Source: audit.SourceConfig,
+			Action: audit.TemplateUpsert,
+			Detail: template.Name,
+		})
+	}
+}
+
+// scheduleEvents creates any instances of recurring events missing from their scheduling horizon and posts
+// the announcements that have come due. Only the leader replica schedules events.
+func (b *Bot) scheduleEvents() {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	plan, err := events.PlanSchedule(b.redis, time.Now())
+	if err != nil {
+		metrics.ErrorsTotal.Inc("schedule")
+		log.Error(err)
+		return
+	}
+
+	err = events.ExecutePlan(b.redis, plan)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("schedule")
+		log.Error(err)
+		return
+	}
+
+	b.mu.Lock()
+	b.lastScheduleRun = time.Now()
+	b.mu.Unlock()
+
+	for _, evt := range plan.Schedule {
+		b.record(audit.Entry{
+			Target: evt.RecurringEventID,
+			Source: audit.SourceScheduler,
+			Action: audit.EventSchedule,
+			Events: []string{evt.ID},
+			Detail: evt.Time.Format(commands.StandardDateFormat),
+		})
 	}
 
-	for _, evt := range allEvents {
-		evt.AnnounceChannelID = ChannelID
-		err := events.AnnounceEvent(b.session, b.redis, evt)
+	for _, evt := range plan.Announce {
+		evt.AnnounceChannelID = b.config.Discord.ChannelID
+		err := events.AnnounceEvent(b.messenger, b.redis, b.settings, evt)
 		if err != nil {
+			metrics.ErrorsTotal.Inc("schedule")
 			log.Error(err)
+			continue
 		}
+
+		b.record(audit.Entry{
+			Target: evt.RecurringEventID,
+			Source: audit.SourceScheduler,
+			Action: audit.EventAnnounce,
+			Events: []string{evt.ID},
+			Detail: fmt.Sprintf("in channel %s", evt.AnnounceChannelID),
+		})
+	}
+
+	// Events scheduled without being announced yet are listed in the summaries too
+	if len(plan.Schedule) > 0 {
+		b.updateSummaries()
+	}
+}
+
+// refreshSummaries edits the pinned summary of every guild to list the events of the current week. Only the
+// leader replica edits summaries.
+func (b *Bot) refreshSummaries() {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	b.updateSummaries()
+}
+
+func (b *Bot) updateSummaries() {
+	err := events.RefreshSummaries(b.messenger, b.redis, b.settings.Theme, time.Now())
+	if err != nil {
+		metrics.ErrorsTotal.Inc("summary")
+		log.Error(err)
+	}
+}
+
+// record appends an entry to the audit log. Failures are logged rather than returned as the change being
+// recorded has already been made.
+func (b *Bot) record(entry audit.Entry) {
+	err := audit.Record(b.redis, entry)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("audit")
+		log.Error(err)
 	}
 }
 
 func (b *Bot) handleMessage(s *discordgo.Session, m *discordgo.MessageCreate) {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
 	if m.Author.ID == s.State.User.ID {
 		return
 	}
 
-	cmd, err := parser.Parse(m.Content)
+	prefix, err := commands.GetGuildPrefix(b.redis, m.GuildID)
 	if err != nil {
-		log.WithField("input", m.Content).Error(err)
+		metrics.ErrorsTotal.Inc("command")
+		log.Error(err)
+		return
+	}
+
+	cmd, err := parser.ParseWithPrefixes(m.Content, append([]string{prefix}, parser.MentionPrefixes(s.State.User.ID)...)...)
+	var parseErr *util.ParseError
+	if errors.Is(err, parser.ErrMissingPrefix) {
+		return
+	} else if errors.As(err, &parseErr) {
+		metrics.ErrorsTotal.Inc("parse")
+		b.respondParseError(m, prefix, parseErr)
+		return
+	} else if err != nil {
+		log.WithField("input", m.Content).Info(err)
 		return
 	}
 
 	ctx := commands.Context{
-		Session:   s,
+		Messenger: b.messenger,
+		GuildID:   m.GuildID,
 		Sender:    m.Author,
 		ChannelID: m.ChannelID,
 		Redis:     b.redis,
+		Refresher: b.refresher,
+		Settings:  b.settings,
+		Prefix:    prefix,
+
+		OfficerRoles: b.config.Discord.OfficerRoles,
 	}
+	metrics.CommandsTotal.Inc(commandName(cmd))
 	err = cmd.Execute(ctx)
 	if err != nil {
+		metrics.ErrorsTotal.Inc("command")
 		log.Error(err)
 		return
 	}
 }
 
-func (b *Bot) handleReactionAdd(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
-	if m.UserID == s.State.User.ID {
-		return
+// commandName returns the name used to label metrics for a command, for example "out" for OutCommand
+func commandName(cmd commands.Command) string {
+	name := reflect.Indirect(reflect.ValueOf(cmd)).Type().Name()
+	return strings.ToLower(strings.TrimSuffix(name, "Command"))
+}
+
+// respondParseError tells the sender why their command could not be understood, suggesting a corrected
+// command using the guild's prefix when one is available
+func (b *Bot) respondParseError(m *discordgo.MessageCreate, prefix string, parseErr *util.ParseError) {
+	log.WithField("input", m.Content).Info(parseErr)
+	msg := fmt.Sprintf("I couldn't understand '%s', %s.", parseErr.Input, parseErr.Err)
+	fields, err := parser.Split(m.Content, append([]string{prefix}, parser.MentionPrefixes(b.session.State.User.ID)...)...)
+	if parseErr.Suggestion != "" && err == nil {
+		msg = msg + fmt.Sprintf(" Did you mean `%s%s %s`?", prefix, fields[0], parseErr.Suggestion)
 	}
 
-	var t events.UserListType
-	switch m.Emoji.Name {
-	case "❌":
-		t = events.Absent
-	case "🕘":
-		t = events.Late
-	default:
-		return
+	_, err = b.messenger.SendMessage(m.ChannelID, msg)
+	if err != nil {
+		log.Error(err)
 	}
+}
 
-	evt, err := events.GetEventByMessage(b.redis, m.ChannelID, m.MessageID)
+// reactionList returns the event announced by the message and the attendance list that the reaction puts
+// members on. ok is false when the message is not an announcement or the reaction does not change attendance.
+func (b *Bot) reactionList(channelID string, messageID string, emoji discordgo.Emoji) (events.Event, events.UserListType, bool, error) {
+	evt, err := events.GetEventByMessage(b.redis, channelID, messageID)
 	if errors.Is(err, events.ErrEventNotFound) {
+		return events.Event{}, "", false, nil
+	} else if err != nil {
+		return events.Event{}, "", false, err
+	}
+
+	template, err := events.GetRecurringEventForEvent(b.redis, evt)
+	if err != nil {
+		return events.Event{}, "", false, err
+	}
+
+	t, ok := events.ListForReaction(template.ReactionMappings(), emoji.APIName())
+	return evt, t, ok, nil
+}
+
+// handleMessageDelete posts a new announcement when one is deleted, upcoming events keep their attendance and
+// past events only forget the deleted message
+func (b *Bot) handleMessageDelete(s *discordgo.Session, m *discordgo.MessageDelete) {
+	if !b.elector.IsLeader() || !b.begin() {
 		return
+	}
+	defer b.done()
+
+	err := b.reannounceDeleted(m.ChannelID, m.ID, time.Now())
+	if err != nil {
+		metrics.ErrorsTotal.Inc("announce")
+		log.Error(err)
+	}
+}
+
+func (b *Bot) reannounceDeleted(channelID string, messageID string, now time.Time) error {
+	evt, err := events.GetEventByMessage(b.redis, channelID, messageID)
+	if errors.Is(err, events.ErrEventNotFound) {
+		return nil
 	} else if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"id":         evt.ID,
+		"message_id": messageID,
+	}).Info("announce message deleted")
+	evt, err = events.ClearAnnouncement(b.redis, evt)
+	if err != nil {
+		return err
+	}
+
+	if evt.Time.Before(now) {
+		return nil
+	}
+
+	err = events.AnnounceEvent(b.messenger, b.redis, b.settings, evt)
+	if err != nil {
+		return fmt.Errorf("announce event: %w", err)
+	}
+
+	b.record(audit.Entry{
+		Target: evt.RecurringEventID,
+		Source: audit.SourceGuild,
+		Action: audit.EventAnnounce,
+		Events: []string{evt.ID},
+		Detail: fmt.Sprintf("in channel %s after the announcement was deleted", evt.AnnounceChannelID),
+	})
+	return nil
+}
+
+func (b *Bot) handleReactionAdd(s *discordgo.Session, m *discordgo.MessageReactionAdd) {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	if m.UserID == s.State.User.ID {
+		return
+	}
+
+	evt, t, ok, err := b.reactionList(m.ChannelID, m.MessageID, m.Emoji)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("reaction")
 		log.Error(err)
 		return
+	} else if !ok {
+		return
 	}
 
+	metrics.ReactionsTotal.Inc("add")
+
 	log.WithFields(log.Fields{
 		"user": m.UserID,
 		"list": t,
 	}).Info("add user to event list")
 	err = events.EventUserListAdd(b.redis, evt, m.UserID, t)
 	if err != nil {
+		metrics.ErrorsTotal.Inc("reaction")
 		log.Error(err)
 		return
 	}
 
-	err = events.AnnounceEvent(s, b.redis, evt)
+	err = events.ObserveReaction(b.redis, evt, m.UserID, m.Emoji.APIName(), true)
 	if err != nil {
+		metrics.ErrorsTotal.Inc("reaction")
 		log.Error(err)
-		return
 	}
+
+	b.record(audit.Entry{
+		Actor:  m.UserID,
+		Target: m.UserID,
+		Source: audit.SourceReaction,
+		Action: audit.AttendanceAdd,
+		Events: []string{evt.ID},
+		Detail: string(t),
+	})
+
+	b.refresher.Request(evt)
 }
 
 func (b *Bot) handleReactionRemove(s *discordgo.Session, m *discordgo.MessageReactionRemove) {
-	if m.UserID == s.State.User.ID {
+	if !b.elector.IsLeader() || !b.begin() {
 		return
 	}
+	defer b.done()
 
-	var t events.UserListType
-	switch m.Emoji.Name {
-	case "❌":
-		t = events.Absent
-	case "🕘":
-		t = events.Late
-	default:
+	if m.UserID == s.State.User.ID {
 		return
 	}
 
-	evt, err := events.GetEventByMessage(b.redis, m.ChannelID, m.MessageID)
-	if errors.Is(err, events.ErrEventNotFound) {
-		return
-	} else if err != nil {
+	evt, t, ok, err := b.reactionList(m.ChannelID, m.MessageID, m.Emoji)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("reaction")
 		log.Error(err)
 		return
+	} else if !ok {
+		return
 	}
 
+	metrics.ReactionsTotal.Inc("remove")
+
 	log.WithFields(log.Fields{
 		"user": m.UserID,
 		"list": t,
 	}).Info("remove user to event list")
 	err = events.EventUserListRemove(b.redis, evt, m.UserID, t)
 	if err != nil {
+		metrics.ErrorsTotal.Inc("reaction")
 		log.Error(err)
 		return
 	}
 
-	err = events.AnnounceEvent(s, b.redis, evt)
+	err = events.ObserveReaction(b.redis, evt, m.UserID, m.Emoji.APIName(), false)
 	if err != nil {
+		metrics.ErrorsTotal.Inc("reaction")
 		log.Error(err)
-		return
 	}
+
+	b.record(audit.Entry{
+		Actor:  m.UserID,
+		Target: m.UserID,
+		Source: audit.SourceReaction,
+		Action: audit.AttendanceRemove,
+		Events: []string{evt.ID},
+		Detail: string(t),
+	})
+
+	b.refresher.Request(evt)
 }
@@ -0,0 +1,88 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestReannounceDeleted(t *testing.T) {
+	now := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		time     time.Time
+		reposted bool
+	}{
+		{"upcoming", now.Add(time.Hour), true},
+		{"past", now.Add(-time.Hour), false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			svc, err := miniredis.Run()
+			if err != nil {
+				panic(err)
+			}
+			defer svc.Close()
+			client := redis.NewClient(&redis.Options{
+				Addr: svc.Addr(),
+			})
+
+			fake := discord.NewFake()
+			b := &Bot{
+				messenger: fake,
+				redis:     client,
+			}
+
+			evt := events.Event{
+				ID:                "raid",
+				Name:              "Raid",
+				Time:              c.time,
+				AnnounceChannelID: "announcements",
+			}
+			err = events.ScheduleEvent(client, evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = events.AnnounceEvent(fake, client, b.settings, evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			deleted, err := events.GetEventById(client, evt.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fake.DeleteMessage(deleted.AnnounceMessageID)
+			err = b.reannounceDeleted(deleted.AnnounceChannelID, deleted.AnnounceMessageID, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = events.GetEventByMessage(client, deleted.AnnounceChannelID, deleted.AnnounceMessageID)
+			if !errors.Is(err, events.ErrEventNotFound) {
+				t.Errorf("expected ErrEventNotFound got '%v'", err)
+			}
+
+			stored, err := events.GetEventById(client, evt.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if reposted := stored.AnnounceMessageID != ""; reposted != c.reposted {
+				t.Errorf("expected reposted '%v' got '%v'", c.reposted, stored.AnnounceMessageID)
+			}
+
+			if c.reposted && len(fake.Sent) != 2 {
+				t.Errorf("expected 2 announcements got '%v'", len(fake.Sent))
+			}
+		})
+	}
+}
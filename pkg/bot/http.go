@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// Health reports the state of the bot's dependencies
+type Health struct {
+	Healthy         bool       `json:"healthy"`
+	Discord         string     `json:"discord"`
+	Redis           string     `json:"redis"`
+	RedisLatency    string     `json:"redis_latency,omitempty"`
+	Leader          bool       `json:"leader"`
+	LastScheduleRun *time.Time `json:"last_schedule_run,omitempty"`
+}
+
+// Health checks the Discord session and Redis connection. The bot is healthy when both are available, the
+// last scheduler run is informational as only the leader replica runs the scheduler.
+func (b *Bot) Health() Health {
+	h := Health{
+		Healthy: true,
+		Discord: "connected",
+		Redis:   "ok",
+		Leader:  b.elector.IsLeader(),
+	}
+
+	if b.session.State == nil || !b.session.DataReady {
+		h.Healthy = false
+		h.Discord = "disconnected"
+	}
+
+	start := time.Now()
+	err := b.redis.Ping().Err()
+	if err != nil {
+		h.Healthy = false
+		h.Redis = err.Error()
+	} else {
+		h.RedisLatency = time.Since(start).String()
+	}
+
+	b.mu.RLock()
+	if !b.lastScheduleRun.IsZero() {
+		last := b.lastScheduleRun
+		h.LastScheduleRun = &last
+	}
+	b.mu.RUnlock()
+
+	return h
+}
+
+func (b *Bot) newHTTPServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", b.handleHealth)
+	mux.HandleFunc("/metrics", handleMetrics)
+	return &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+}
+
+func (b *Bot) handleHealth(w http.ResponseWriter, r *http.Request) {
+	h := b.Health()
+	w.Header().Set("Content-Type", "application/json")
+	if !h.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	err := json.NewEncoder(w).Encode(h)
+	if err != nil {
+		log.Error(err)
+	}
+}
+
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	err := metrics.WriteAll(w)
+	if err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,81 @@
+package bot
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/lease"
+	"github.com/acastle/esperbot/pkg/metrics"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+)
+
+func TestHealth(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	session := &discordgo.Session{State: discordgo.NewState()}
+	b := &Bot{
+		session: session,
+		redis:   client,
+		elector: lease.NewElector(client, lease.KeyForLease("test"), "test", time.Second),
+	}
+	server := b.newHTTPServer("")
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected unavailable before the session is ready, got %d", rec.Code)
+	}
+
+	session.DataReady = true
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected ok, got %d", rec.Code)
+	}
+
+	h := Health{}
+	err = json.NewDecoder(rec.Body).Decode(&h)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !h.Healthy || h.Discord != "connected" || h.Redis != "ok" || h.LastScheduleRun != nil {
+		t.Errorf("unexpected health '%v'", h)
+	}
+
+	svc.Close()
+	rec = httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected unavailable without redis, got %d", rec.Code)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	metrics.CommandsTotal.Inc("out")
+	b := &Bot{}
+	server := b.newHTTPServer("")
+
+	rec := httptest.NewRecorder()
+	server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected ok, got %d", rec.Code)
+	}
+
+	if !strings.Contains(rec.Body.String(), `esperbot_commands_total{command="out"}`) {
+		t.Errorf("expected command counter in output, got '%s'", rec.Body.String())
+	}
+}
@@ -0,0 +1,102 @@
+package bot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/commands"
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/metrics"
+	"github.com/bwmarrin/discordgo"
+)
+
+// handleRawEvent receives every gateway event, interactions are picked out here as the session does not
+// decode them itself
+func (b *Bot) handleRawEvent(s *discordgo.Session, e *discordgo.Event) {
+	if e.Type != discord.InteractionCreateEvent {
+		return
+	}
+
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	interaction, err := discord.ParseInteraction(e.RawData)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("interaction")
+		log.Error(err)
+		return
+	}
+
+	err = b.handleInteraction(interaction)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("interaction")
+		log.Error(err)
+	}
+}
+
+// handleInteraction changes the attendance of the member who pressed one of the buttons on an announcement and
+// confirms the change to them alone
+func (b *Bot) handleInteraction(i discord.Interaction) error {
+	if i.Type != discord.InteractionMessageComponent || i.Message == nil {
+		return nil
+	}
+
+	t, err := events.ParseButton(i.Data.CustomID)
+	if errors.Is(err, events.ErrUnknownButton) {
+		return nil
+	}
+
+	userID := i.UserID()
+	evt, err := events.GetEventByMessage(b.redis, i.Message.ChannelID, i.Message.ID)
+	if errors.Is(err, events.ErrEventNotFound) {
+		return b.messenger.RespondToInteraction(i, "I'm no longer keeping track of that event.", true)
+	} else if err != nil {
+		return fmt.Errorf("get event by message: %w", err)
+	}
+
+	metrics.ReactionsTotal.Inc("button")
+	log.WithFields(log.Fields{
+		"user":  userID,
+		"list":  t,
+		"event": evt.ID,
+	}).Info("set user event list")
+	err = events.EventUserListSet(b.redis, evt, userID, t)
+	if err != nil {
+		return fmt.Errorf("set user list: %w", err)
+	}
+
+	entry := audit.Entry{
+		Actor:  userID,
+		Target: userID,
+		Source: audit.SourceButton,
+		Action: audit.AttendanceAdd,
+		Events: []string{evt.ID},
+		Detail: string(t),
+	}
+	if t == "" {
+		entry.Action = audit.AttendanceRemove
+		entry.Detail = "all"
+	}
+	b.record(entry)
+	b.refresher.Request(evt)
+
+	when := fmt.Sprintf("%s on %s", evt.Name, evt.Time.Format(commands.StandardDateFormat))
+	msg := fmt.Sprintf("Cleared your attendance for %s, see you there.", when)
+	if t != "" {
+		msg = fmt.Sprintf("Marked you %s for %s.", strings.ToLower(t.Title()), when)
+	}
+
+	err = b.messenger.RespondToInteraction(i, msg, true)
+	if err != nil {
+		return fmt.Errorf("respond to interaction: %w", err)
+	}
+
+	return nil
+}
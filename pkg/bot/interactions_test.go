@@ -0,0 +1,98 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+)
+
+func TestHandleInteraction(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	fake.AddUser(&discordgo.User{ID: "user", Username: "User"})
+	b := &Bot{
+		messenger: fake,
+		redis:     client,
+		refresher: events.NewRefresher(fake, client, events.Settings{}, time.Hour),
+	}
+
+	evt := events.Event{
+		ID:                "raid",
+		Name:              "Raid",
+		Time:              time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		AnnounceChannelID: "announcements",
+	}
+	err = events.ScheduleEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = events.AnnounceEvent(fake, client, b.settings, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt, err = events.GetEventById(client, evt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	press := func(customID string) {
+		t.Helper()
+		i := discord.Interaction{
+			ID:      customID,
+			Type:    discord.InteractionMessageComponent,
+			Member:  &discordgo.Member{User: &discordgo.User{ID: "user"}},
+			Message: &discordgo.Message{ID: evt.AnnounceMessageID, ChannelID: evt.AnnounceChannelID},
+		}
+		i.Data.CustomID = customID
+		err := b.handleInteraction(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		name     string
+		customID string
+		absent   int
+		late     int
+		response string
+	}{
+		{"out", "attendance:absent", 1, 0, "Marked you out for Raid on Wednesday Aug 25 2021."},
+		{"late replaces out", "attendance:late", 0, 1, "Marked you late for Raid on Wednesday Aug 25 2021."},
+		{"clear", "attendance:clear", 0, 0, "Cleared your attendance for Raid on Wednesday Aug 25 2021, see you there."},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			press(c.customID)
+			attendance, err := events.GetAttendanceForEvent(client, evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(attendance.Absent) != c.absent || len(attendance.Late) != c.late {
+				t.Errorf("expected %d absent and %d late got '%v'", c.absent, c.late, attendance)
+			}
+
+			response := fake.Responses[len(fake.Responses)-1]
+			if response.Content != c.response || !response.Ephemeral {
+				t.Errorf("expected an ephemeral '%s' got '%v'", c.response, response)
+			}
+		})
+	}
+}
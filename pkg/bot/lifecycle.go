@@ -0,0 +1,48 @@
+package bot
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrShutdownTimeout = errors.New("timed out waiting for in-flight work to finish")
+
+const ShutdownTimeout = 30 * time.Second
+
+// begin registers a unit of in-flight work such as a command or scheduled job. It returns false once the bot
+// has started shutting down, in which case the work must not be started. Every successful call must be
+// paired with a call to done.
+func (b *Bot) begin() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.stopping {
+		return false
+	}
+
+	b.inFlight.Add(1)
+	return true
+}
+
+func (b *Bot) done() {
+	b.inFlight.Done()
+}
+
+// drain stops new work from starting and waits for in-flight work to finish or for the timeout to pass
+func (b *Bot) drain(timeout time.Duration) error {
+	b.mu.Lock()
+	b.stopping = true
+	b.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		b.inFlight.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-time.After(timeout):
+		return ErrShutdownTimeout
+	}
+}
@@ -0,0 +1,49 @@
+package bot
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDrain(t *testing.T) {
+	b := &Bot{}
+	if !b.begin() {
+		t.Fatal("expected work to start before shutdown")
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(finished)
+		b.done()
+	}()
+
+	err := b.drain(time.Second)
+	if err != nil {
+		t.Error(err)
+	}
+
+	select {
+	case <-finished:
+	default:
+		t.Error("drain returned before in-flight work finished")
+	}
+
+	if b.begin() {
+		t.Error("expected work to be rejected after shutdown")
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	b := &Bot{}
+	if !b.begin() {
+		t.Fatal("expected work to start before shutdown")
+	}
+	defer b.done()
+
+	err := b.drain(10 * time.Millisecond)
+	if !errors.Is(err, ErrShutdownTimeout) {
+		t.Errorf("expected ErrShutdownTimeout got '%v'", err)
+	}
+}
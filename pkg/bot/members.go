@@ -0,0 +1,217 @@
+package bot
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/metrics"
+	"github.com/acastle/esperbot/pkg/roster"
+	"github.com/bwmarrin/discordgo"
+)
+
+// primeMembers migrates legacy aliases, caches the nicknames of the guild members already in the session state
+// and requests the rest from Discord, they are cached by handleMembersChunk as they arrive
+func (b *Bot) primeMembers() {
+	if !b.begin() {
+		return
+	}
+	defer b.done()
+
+	members := []*discordgo.Member{}
+	guild, err := b.session.State.Guild(b.config.Discord.GuildID)
+	if err == nil {
+		members = guild.Members
+	}
+
+	migrated, err := events.MigrateLegacyAliases(b.redis, b.messenger, members)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+	} else if migrated > 0 {
+		log.WithField("migrated", migrated).Info("migrated legacy aliases")
+	}
+
+	if len(members) > 0 {
+		b.cacheMembers(members)
+		err = roster.AddMembers(b.redis, memberIDs(members))
+		if err != nil {
+			metrics.ErrorsTotal.Inc("member")
+			log.Error(err)
+		}
+	}
+
+	err = b.session.RequestGuildMembers(b.config.Discord.GuildID, "", 0, false)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+	}
+}
+
+func (b *Bot) handleMembersChunk(s *discordgo.Session, m *discordgo.GuildMembersChunk) {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	if m.GuildID != b.config.Discord.GuildID {
+		return
+	}
+
+	b.cacheMembers(m.Members)
+	err := roster.StageMembers(b.redis, memberIDs(m.Members), m.ChunkIndex, m.ChunkCount)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+	}
+}
+
+// handleMemberAdd counts the main of a member who joined the guild in the composition of announcements
+func (b *Bot) handleMemberAdd(s *discordgo.Session, m *discordgo.GuildMemberAdd) {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	if m.Member == nil || m.User == nil || m.GuildID != b.config.Discord.GuildID {
+		return
+	}
+
+	err := roster.AddMembers(b.redis, []string{m.User.ID})
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+		return
+	}
+
+	b.refreshComposition(m.User.ID)
+}
+
+// handleMemberRemove stops counting the main of a member who left the guild in the composition of
+// announcements
+func (b *Bot) handleMemberRemove(s *discordgo.Session, m *discordgo.GuildMemberRemove) {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	if m.Member == nil || m.User == nil || m.GuildID != b.config.Discord.GuildID {
+		return
+	}
+
+	err := roster.RemoveMember(b.redis, m.User.ID)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+		return
+	}
+
+	b.refreshComposition(m.User.ID)
+}
+
+// refreshComposition refreshes the upcoming announcements when the member has a main counted in their
+// composition
+func (b *Bot) refreshComposition(userID string) {
+	mains, err := roster.GetMainsFor(b.redis, []string{userID})
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+		return
+	}
+
+	if len(mains) == 0 {
+		return
+	}
+
+	evts, err := events.GetAnnouncedEvents(b.redis, time.Now())
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+		return
+	}
+
+	for _, evt := range evts {
+		b.refresher.Request(evt)
+	}
+}
+
+func memberIDs(members []*discordgo.Member) []string {
+	ids := []string{}
+	for _, m := range members {
+		if m.User != nil {
+			ids = append(ids, m.User.ID)
+		}
+	}
+
+	return ids
+}
+
+func (b *Bot) cacheMembers(members []*discordgo.Member) {
+	changed, err := events.RefreshMemberNicknames(b.redis, members)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"members": len(members),
+		"changed": len(changed),
+	}).Debug("cached member nicknames")
+}
+
+// handleMemberUpdate refreshes the cached nickname of a member and the announcements of upcoming events they
+// are listed on when it changes
+func (b *Bot) handleMemberUpdate(s *discordgo.Session, m *discordgo.GuildMemberUpdate) {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	if m.Member == nil || m.User == nil || m.GuildID != b.config.Discord.GuildID {
+		return
+	}
+
+	changed, err := events.RefreshMemberNickname(b.redis, m.Member)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+		return
+	} else if !changed {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"user":     m.User.ID,
+		"nickname": events.DisplayName(m.Member),
+	}).Info("member nickname changed")
+	b.record(audit.Entry{
+		Actor:  m.User.ID,
+		Target: m.User.ID,
+		Source: audit.SourceGuild,
+		Action: audit.AliasSet,
+		Detail: events.DisplayName(m.Member),
+	})
+
+	evts, err := events.GetAnnouncedEvents(b.redis, time.Now())
+	if err != nil {
+		metrics.ErrorsTotal.Inc("member")
+		log.Error(err)
+		return
+	}
+
+	for _, evt := range evts {
+		attendance, err := events.GetAttendanceForEvent(b.redis, evt)
+		if err != nil {
+			metrics.ErrorsTotal.Inc("member")
+			log.Error(err)
+			return
+		}
+
+		if attendance.Includes(m.User.ID) {
+			b.refresher.Request(evt)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package bot
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/acastle/esperbot/pkg/commands"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/metrics"
+)
+
+// nudgeTentative asks the tentative members of events starting within events.NudgeLead to confirm whether they
+// can attend. Each member is only asked once per event, members who become tentative later are asked on the
+// next run.
+func (b *Bot) nudgeTentative() {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	evts, err := events.EventsDueForNudge(b.redis, time.Now())
+	if err != nil {
+		metrics.ErrorsTotal.Inc("nudge")
+		log.Error(err)
+		return
+	}
+
+	for _, evt := range evts {
+		attendance, err := events.GetAttendanceForEvent(b.redis, evt)
+		if err != nil {
+			metrics.ErrorsTotal.Inc("nudge")
+			log.Error(err)
+			continue
+		}
+
+		claimed, err := events.ClaimNudges(b.redis, evt, attendance.Tentative)
+		if err != nil {
+			metrics.ErrorsTotal.Inc("nudge")
+			log.Error(err)
+			continue
+		} else if len(claimed) == 0 {
+			continue
+		}
+
+		log.WithFields(log.Fields{
+			"event":   evt.ID,
+			"members": len(claimed),
+		}).Info("ask tentative members to confirm")
+		for _, userID := range claimed {
+			_, err := b.messenger.SendDirectMessage(userID, nudgeMessage(evt))
+			if err != nil {
+				metrics.ErrorsTotal.Inc("nudge")
+				log.WithField("user", userID).Error(err)
+			}
+		}
+	}
+}
+
+// nudgeMessage asks a tentative member to confirm, direct messages always use the default prefix
+func nudgeMessage(evt events.Event) string {
+	day := evt.Time.Format("Jan 2")
+	return fmt.Sprintf(
+		"You are tentative for %s on %s. Can you make it? Reply with `%sin %s`, `%slate %s` or `%sout %s`, or react to the announcement.",
+		evt.Name, evt.Time.Format(commands.StandardDateFormat),
+		commands.DefaultPrefix, day, commands.DefaultPrefix, day, commands.DefaultPrefix, day,
+	)
+}
@@ -0,0 +1,71 @@
+package bot
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/metrics"
+)
+
+// reconcileReactions applies the reactions added to or removed from upcoming announcements while no replica
+// was connected to Discord, as those never arrive as gateway events. Each of them is logged and recorded in
+// the audit log.
+func (b *Bot) reconcileReactions() {
+	if !b.elector.IsLeader() || !b.begin() {
+		return
+	}
+	defer b.done()
+
+	evts, err := events.GetAnnouncedEvents(b.redis, time.Now())
+	if err != nil {
+		metrics.ErrorsTotal.Inc("reconcile")
+		log.Error(err)
+		return
+	}
+
+	total := 0
+	for _, evt := range evts {
+		changed, err := events.ReconcileReactions(b.messenger, b.redis, evt, b.session.State.User.ID)
+		if err != nil {
+			metrics.ErrorsTotal.Inc("reconcile")
+			log.WithField("event", evt.ID).Error(err)
+			continue
+		}
+
+		for _, d := range changed {
+			action, msg := audit.AttendanceAdd, "reaction added while offline"
+			if d.Removed {
+				action, msg = audit.AttendanceRemove, "reaction removed while offline"
+			}
+
+			log.WithFields(log.Fields{
+				"event": evt.ID,
+				"user":  d.UserID,
+				"emoji": d.Emoji,
+				"list":  d.List,
+			}).Warn(msg)
+			b.record(audit.Entry{
+				Actor:  d.UserID,
+				Target: d.UserID,
+				Source: audit.SourceReconcile,
+				Action: action,
+				Events: []string{evt.ID},
+				Detail: string(d.List),
+			})
+		}
+
+		if len(changed) > 0 {
+			metrics.ReactionsTotal.Add("reconcile", float64(len(changed)))
+			b.refresher.Request(evt)
+		}
+		total += len(changed)
+	}
+
+	log.WithFields(log.Fields{
+		"events":    len(evts),
+		"reactions": total,
+	}).Info("reconciled announcement reactions")
+}
@@ -4,10 +4,20 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/acastle/esperbot/pkg/audit"
 	"github.com/acastle/esperbot/pkg/events"
 	log "github.com/sirupsen/logrus"
 )
 
+var AnnounceSpec = Spec{
+	Name:        "announce",
+	Description: "announce this week's events in the current channel",
+	Hidden:      true,
+	Parse: func(args []string) (Command, error) {
+		return &AnnounceCommand{}, nil
+	},
+}
+
 type AnnounceCommand struct {
 }
 
@@ -19,11 +29,15 @@ func (c AnnounceCommand) Execute(ctx Context) error {
 
 	for _, evt := range evts {
 		evt.AnnounceChannelID = ctx.ChannelID
-		err := events.AnnounceEvent(ctx.Session, ctx.Redis, evt)
+		err := events.AnnounceEvent(ctx.Messenger, ctx.Redis, ctx.Settings, evt)
 		if err != nil {
 			return fmt.Errorf("announce event: %w", err)
 		}
 	}
 
-	return nil
+	return ctx.record(audit.Entry{
+		Action: audit.EventAnnounce,
+		Events: eventIDs(evts),
+		Detail: fmt.Sprintf("in channel %s", ctx.ChannelID),
+	})
 }
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+)
+
+func TestAnnounceCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	err := events.ScheduleEvent(ctx.Redis, events.Event{
+		ID:   "raid",
+		Name: "Raid",
+		Time: util.BeginningOfDay(time.Now().UTC()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = AnnounceCommand{}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt, err := events.GetEventById(ctx.Redis, "raid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, ok := fake.Messages[evt.AnnounceMessageID]
+	if !ok {
+		t.Fatalf("expected announcement message to be stored on the event, got '%s'", evt.AnnounceMessageID)
+	}
+
+	if msg.ChannelID != testChannelID || len(msg.Embeds) != 1 {
+		t.Errorf("expected an embed in channel '%s' got '%v'", testChannelID, msg)
+	}
+
+	if len(fake.Reactions) != len(events.DefaultReactions) {
+		t.Errorf("expected reactions to be added, got '%v'", fake.Reactions)
+	}
+}
@@ -0,0 +1,157 @@
+package commands
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/bwmarrin/discordgo"
+)
+
+// AuditEntries is the number of entries shown by the audit command
+const AuditEntries = 15
+
+var AuditSpec = Spec{
+	Name:        "audit",
+	Usage:       "[event id | @member]",
+	Description: "show the most recent changes to attendance, names and events, optionally only those for an event or member",
+	Examples:    []string{"audit", "audit @member", "audit MainRaid-2021-08-25"},
+	Parse: func(args []string) (Command, error) {
+		c := &AuditCommand{}
+		if len(args) == 0 {
+			return c, nil
+		}
+
+		if id, ok := ParseMention(args[0]); ok {
+			c.UserID = id
+		} else {
+			c.EventID = args[0]
+		}
+
+		return c, nil
+	},
+}
+
+// AuditCommand lists recent audit entries, limited to an event or a member when one is set
+type AuditCommand struct {
+	EventID string
+	UserID  string
+}
+
+func (c AuditCommand) Execute(ctx Context) error {
+	var entries []audit.Entry
+	var err error
+	switch {
+	case c.EventID != "":
+		entries, err = audit.ForEvent(ctx.Redis, c.EventID, AuditEntries)
+	case c.UserID != "":
+		entries, err = audit.ForUser(ctx.Redis, c.UserID, AuditEntries)
+	default:
+		entries, err = audit.Recent(ctx.Redis, AuditEntries)
+	}
+	if err != nil {
+		return fmt.Errorf("read audit log: %w", err)
+	}
+
+	aliases, err := events.GetUserAliases(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, c.userIDs(entries))
+	if err != nil {
+		return fmt.Errorf("fetch user aliases: %w", err)
+	}
+
+	scope := "for all changes"
+	if c.EventID != "" {
+		scope = fmt.Sprintf("for event %s", c.EventID)
+	} else if c.UserID != "" {
+		scope = fmt.Sprintf("for %s", aliases[c.UserID])
+	}
+
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = c.format(entry, aliases)
+	}
+
+	description := fmt.Sprintf("Most recent changes %s", scope)
+	if len(lines) == 0 {
+		description = fmt.Sprintf("No changes recorded %s", scope)
+	}
+
+	embed := discordgo.MessageEmbed{
+		Author: &discordgo.MessageEmbedAuthor{
+			Name: "Audit log",
+		},
+		Title:       description,
+		Description: strings.Join(lines, "\n"),
+	}
+
+	_, err = ctx.Messenger.SendEmbed(ctx.ChannelID, &embed)
+	if err != nil {
+		return fmt.Errorf("send audit log: %w", err)
+	}
+
+	return nil
+}
+
+// format describes an entry on a single line, for example
+// "`Aug 25 15:04` Officer attendance.add Member: absent Aug 25 2021 (command, MainRaid-2021-08-25)"
+func (c AuditCommand) format(entry audit.Entry, aliases map[string]string) string {
+	actor := "esperbot"
+	if entry.Actor != "" {
+		actor = aliases[entry.Actor]
+	}
+
+	target := entry.Target
+	if target != "" && targetsMember(entry.Action) {
+		target = aliases[target]
+	}
+
+	line := fmt.Sprintf("`%s` %s %s", entry.Time.Format("Jan _2 15:04"), actor, entry.Action)
+	if target != "" {
+		line = line + " " + target
+	}
+	if entry.Detail != "" {
+		line = line + ": " + entry.Detail
+	}
+
+	notes := []string{string(entry.Source)}
+	if c.EventID == "" {
+		evts := entry.Events
+		if len(evts) > 3 {
+			evts = append(evts[:3:3], fmt.Sprintf("%d more", len(entry.Events)-3))
+		}
+		notes = append(notes, evts...)
+	}
+
+	return line + fmt.Sprintf(" (%s)", strings.Join(notes, ", "))
+}
+
+// userIDs returns the members named in the listing, so that their aliases can be fetched together
+func (c AuditCommand) userIDs(entries []audit.Entry) []string {
+	ids := []string{}
+	add := func(id string) {
+		if id != "" && !containsString(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+
+	add(c.UserID)
+	for _, entry := range entries {
+		add(entry.Actor)
+		if targetsMember(entry.Action) {
+			add(entry.Target)
+		}
+	}
+
+	return ids
+}
+
+// targetsMember returns true for actions whose target is a member, the targets of other actions are templates
+func targetsMember(action audit.Action) bool {
+	for _, prefix := range []string{"attendance.", "rule.", "alias.", "character."} {
+		if strings.HasPrefix(string(action), prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/audit"
+)
+
+func TestAuditCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+
+	err := OutCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SetNameCommand{Name: "Renamed"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := audit.ForEvent(ctx.Redis, wed.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || entries[0].Actor != ctx.Sender.ID || entries[0].Action != audit.AttendanceAdd || entries[0].Source != audit.SourceDM {
+		t.Fatalf("expected the absence to be recorded for the event got '%v'", entries)
+	}
+
+	cases := []struct {
+		name     string
+		command  AuditCommand
+		expLines int
+	}{
+		{"recent", AuditCommand{}, 2},
+		{"event", AuditCommand{EventID: wed.ID}, 1},
+		{"member", AuditCommand{UserID: ctx.Sender.ID}, 2},
+		{"unknown event", AuditCommand{EventID: "fri"}, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.command.Execute(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			embed := fake.Sent[len(fake.Sent)-1].Embeds[0]
+			lines := 0
+			if embed.Description != "" {
+				lines = len(strings.Split(embed.Description, "\n"))
+			}
+
+			if lines != c.expLines {
+				t.Errorf("expected '%v' entries got '%v'", c.expLines, embed.Description)
+			}
+		})
+	}
+
+	embed := fake.Sent[len(fake.Sent)-2].Embeds[0]
+	if !strings.Contains(embed.Description, "Renamed attendance.add Renamed: absent Aug 25 2021 (dm, wed)") {
+		t.Errorf("expected a readable entry got '%s'", embed.Description)
+	}
+}
@@ -0,0 +1,261 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/roster"
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrCharacterUsage = errors.New("expected 'add Name-Realm class spec main|alt' or 'remove Name-Realm'")
+
+var CharSpec = Spec{
+	Name:        "char",
+	Aliases:     []string{"character"},
+	Usage:       "add [@member] <Name-Realm> <class> <spec> [main|alt] | remove [@member] <Name-Realm>",
+	Description: "register one of your characters so that it is shown on announcements and counted in the raid composition. Officers can mention a member to act for them",
+	Examples:    []string{"char add Banana-Illidan mage frost main", "char add Phone-Area-52 dk blood alt", "char remove Phone-Area-52"},
+	Parse:       parseChar,
+}
+
+var CharsSpec = Spec{
+	Name:        "chars",
+	Aliases:     []string{"characters"},
+	Usage:       "[@member]",
+	Description: "list your characters, or those of another member",
+	Examples:    []string{"chars", "chars @member"},
+	Parse: func(args []string) (Command, error) {
+		c := &CharsCommand{}
+		if len(args) > 0 {
+			id, ok := ParseMention(args[0])
+			if !ok {
+				return nil, &util.ParseError{
+					Input: args[0],
+					Err:   errors.New("expected a mention of a member"),
+				}
+			}
+			c.Target = id
+		}
+
+		return c, nil
+	},
+}
+
+func parseChar(args []string) (Command, error) {
+	usageErr := &util.ParseError{
+		Input: strings.Join(args, " "),
+		Err:   ErrCharacterUsage,
+	}
+	if len(args) < 2 {
+		return nil, usageErr
+	}
+
+	action := strings.ToLower(args[0])
+	args = args[1:]
+	target := ""
+	if id, ok := ParseMention(args[0]); ok {
+		target = id
+		args = args[1:]
+	}
+
+	if len(args) == 0 {
+		return nil, usageErr
+	}
+
+	name, realm, err := roster.ParseCharacterName(args[0])
+	if err != nil {
+		return nil, &util.ParseError{Input: args[0], Err: err}
+	}
+
+	switch action {
+	case "remove", "rm", "delete":
+		if len(args) != 1 {
+			return nil, usageErr
+		}
+
+		return &CharCommand{
+			Remove:    true,
+			Character: roster.Character{Name: name, Realm: realm},
+			Target:    target,
+		}, nil
+	case "add", "set":
+		if len(args) < 3 || len(args) > 4 {
+			return nil, usageErr
+		}
+
+		class, err := roster.ParseClass(args[1])
+		if err != nil {
+			return nil, &util.ParseError{Input: args[1], Err: err}
+		}
+
+		spec, err := roster.ParseSpec(class, args[2])
+		if err != nil {
+			return nil, &util.ParseError{Input: args[2], Err: err}
+		}
+
+		main := false
+		if len(args) == 4 {
+			switch strings.ToLower(args[3]) {
+			case "main":
+				main = true
+			case "alt":
+			default:
+				return nil, &util.ParseError{Input: args[3], Err: errors.New("expected main or alt")}
+			}
+		}
+
+		return &CharCommand{
+			Character: roster.Character{Name: name, Realm: realm, Class: class, Spec: spec, Main: main},
+			Target:    target,
+		}, nil
+	default:
+		return nil, usageErr
+	}
+}
+
+// CharCommand adds a character for a member, or removes it when Remove is set
+type CharCommand struct {
+	Character roster.Character
+	Remove    bool
+	// Target is the id of the member to act for, the sender is used when it is empty
+	Target string
+}
+
+func (c CharCommand) Execute(ctx Context) error {
+	user, err := ctx.Subject(c.Target)
+	if errors.Is(err, ErrNotOfficer) {
+		return respondNotOfficer(ctx)
+	} else if err != nil {
+		return fmt.Errorf("resolve target: %w", err)
+	}
+
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, user)
+	if err != nil {
+		return fmt.Errorf("fetch user alias: %w", err)
+	}
+
+	var msg string
+	var mainChanged bool
+	entry := audit.Entry{Target: user}
+	if c.Remove {
+		removed, err := roster.RemoveCharacter(ctx.Redis, user, c.Character.Key())
+		if errors.Is(err, roster.ErrUnknownCharacter) {
+			return c.respond(ctx, fmt.Sprintf("'%s' has no character named %s.", alias, c.Character))
+		} else if err != nil {
+			return fmt.Errorf("remove character: %w", err)
+		}
+
+		mainChanged = removed.Main
+		entry.Action = audit.CharacterRemove
+		entry.Detail = removed.String()
+		msg = fmt.Sprintf("Removed %s from '%s'", removed, alias)
+	} else {
+		added, err := roster.AddCharacter(ctx.Redis, user, c.Character)
+		if err != nil {
+			return fmt.Errorf("add character: %w", err)
+		}
+
+		kind := "alt"
+		if added.Main {
+			kind = "main"
+		}
+		mainChanged = added.Main
+		entry.Action = audit.CharacterAdd
+		entry.Detail = fmt.Sprintf("%s %s %s", added, added.Description(), kind)
+		msg = fmt.Sprintf("Added %s, %s, as the %s of '%s'", added, added.Description(), kind, alias)
+	}
+
+	log.WithFields(log.Fields{
+		"user":      user,
+		"actor":     ctx.Sender.ID,
+		"character": c.Character.String(),
+		"remove":    c.Remove,
+	}).Info("update character")
+	err = ctx.record(entry)
+	if err != nil {
+		return err
+	}
+
+	// Announcements show the main of listed members and count the main of everyone in their composition
+	if mainChanged {
+		evts, err := events.GetAnnouncedEvents(ctx.Redis, time.Now())
+		if err != nil {
+			return fmt.Errorf("get announced events: %w", err)
+		}
+
+		for _, evt := range evts {
+			ctx.Refresher.Request(evt)
+		}
+	}
+
+	return c.respond(ctx, msg)
+}
+
+func (c CharCommand) respond(ctx Context, msg string) error {
+	_, err := ctx.Messenger.SendMessage(ctx.ChannelID, msg)
+	if err != nil {
+		return fmt.Errorf("send response: %w", err)
+	}
+
+	return nil
+}
+
+// CharsCommand lists the characters of a member, the sender when Target is empty
+type CharsCommand struct {
+	Target string
+}
+
+func (c CharsCommand) Execute(ctx Context) error {
+	user := c.Target
+	if user == "" {
+		user = ctx.Sender.ID
+	}
+
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, user)
+	if err != nil {
+		return fmt.Errorf("fetch user alias: %w", err)
+	}
+
+	characters, err := roster.GetCharacters(ctx.Redis, user)
+	if err != nil {
+		return fmt.Errorf("get characters: %w", err)
+	}
+
+	if len(characters) == 0 {
+		_, err = ctx.Messenger.SendMessage(ctx.ChannelID, fmt.Sprintf("'%s' has no characters, add one with `%schar add Name-Realm class spec main`.", alias, ctx.CommandPrefix()))
+		if err != nil {
+			return fmt.Errorf("send response: %w", err)
+		}
+		return nil
+	}
+
+	lines := make([]string, len(characters))
+	for i, character := range characters {
+		kind := "alt"
+		if character.Main {
+			kind = "main"
+		}
+		lines[i] = fmt.Sprintf("**%s** %s (%s)", character, character.Description(), kind)
+	}
+
+	embed := discordgo.MessageEmbed{
+		Author: &discordgo.MessageEmbedAuthor{
+			Name: fmt.Sprintf("Characters of %s", alias),
+		},
+		Description: strings.Join(lines, "\n"),
+	}
+
+	_, err = ctx.Messenger.SendEmbed(ctx.ChannelID, &embed)
+	if err != nil {
+		return fmt.Errorf("send characters: %w", err)
+	}
+
+	return nil
+}
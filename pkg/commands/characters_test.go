@@ -0,0 +1,161 @@
+package commands
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/roster"
+	"github.com/acastle/esperbot/pkg/util"
+)
+
+func TestParseChar(t *testing.T) {
+	cases := []struct {
+		name       string
+		args       []string
+		expErr     error
+		expCommand Command
+	}{
+		{
+			"add main",
+			[]string{"add", "Banana-Illidan", "mage", "frost", "main"},
+			nil,
+			&CharCommand{Character: roster.Character{Name: "Banana", Realm: "Illidan", Class: "mage", Spec: "frost", Main: true}},
+		},
+		{
+			"add alt for member",
+			[]string{"add", "<@123>", "Phone-Area-52", "dk", "blood"},
+			nil,
+			&CharCommand{Character: roster.Character{Name: "Phone", Realm: "Area-52", Class: "deathknight", Spec: "blood"}, Target: "123"},
+		},
+		{
+			"remove",
+			[]string{"remove", "Phone-Area-52"},
+			nil,
+			&CharCommand{Character: roster.Character{Name: "Phone", Realm: "Area-52"}, Remove: true},
+		},
+		{"missing spec", []string{"add", "Banana-Illidan", "mage"}, ErrCharacterUsage, nil},
+		{"unknown spec", []string{"add", "Banana-Illidan", "mage", "holy"}, roster.ErrUnknownSpec, nil},
+		{"missing realm", []string{"add", "Banana", "mage", "frost"}, roster.ErrInvalidCharacterName, nil},
+		{"unknown action", []string{"list", "Banana-Illidan"}, ErrCharacterUsage, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cmd, err := parseChar(c.args)
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("expected '%v' got '%v'", c.expErr, err)
+				return
+			}
+
+			var parseErr *util.ParseError
+			if err != nil && !errors.As(err, &parseErr) {
+				t.Errorf("expected a parse error got '%v'", err)
+			}
+
+			if !reflect.DeepEqual(cmd, c.expCommand) {
+				t.Errorf("expected '%v' got '%v'", c.expCommand, cmd)
+			}
+		})
+	}
+}
+
+func TestCharCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+	err := roster.AddMembers(ctx.Redis, []string{ctx.Sender.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = CharCommand{Character: roster.Character{Name: "Banana", Realm: "Illidan", Class: "mage", Spec: "frost"}}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Added Banana-Illidan, Frost Mage, as the main of 'Tester'"
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+
+	err = LateCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	embed := fake.Messages[wed.AnnounceMessageID].Embeds[0]
+	if !strings.Contains(embed.Fields[1].Value, "Tester · Frost Mage") {
+		t.Errorf("expected the main character in the embed got '%s'", embed.Fields[1].Value)
+	}
+
+	if embed.Fields[3].Name != "Composition" || embed.Fields[3].Value != "0 tank · 0 healer · 1 dps" {
+		t.Errorf("expected the composition in the embed got '%v'", embed.Fields[3])
+	}
+
+	err = CharsCommand{}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list := fake.Sent[len(fake.Sent)-1].Embeds[0].Description
+	if list != "**Banana-Illidan** Frost Mage (main)" {
+		t.Errorf("expected the character list got '%s'", list)
+	}
+
+	err = CharCommand{Character: roster.Character{Name: "Phone", Realm: "Illidan"}, Remove: true}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "'Tester' has no character named Phone-Illidan."
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+}
+
+func TestCharCommandRefreshesAnnouncements(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	evt := announceTestEvent(t, ctx, "raid", util.BeginningOfDay(time.Now().UTC()).Add(23*time.Hour))
+	err := roster.AddMembers(ctx.Redis, []string{ctx.Sender.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = CharCommand{Character: roster.Character{Name: "Banana", Realm: "Illidan", Class: "mage", Spec: "frost"}}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	fields := fake.Messages[evt.AnnounceMessageID].Embeds[0].Fields
+	if fields[3].Name != "Composition" || fields[3].Value != "0 tank · 0 healer · 1 dps" {
+		t.Errorf("expected the new main to be counted got '%v'", fields[3])
+	}
+
+	// Alts are not shown on announcements
+	edits := len(fake.Edits)
+	err = CharCommand{Character: roster.Character{Name: "Phone", Realm: "Illidan", Class: "deathknight", Spec: "blood"}}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	if len(fake.Edits) != edits {
+		t.Errorf("expected adding an alt not to refresh announcements, got %d edits", len(fake.Edits)-edits)
+	}
+
+	err = CharCommand{Character: roster.Character{Name: "Banana", Realm: "Illidan"}, Remove: true}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	fields = fake.Messages[evt.AnnounceMessageID].Embeds[0].Fields
+	if fields[3].Name != "Composition" || fields[3].Value != "1 tank · 0 healer · 0 dps" {
+		t.Errorf("expected the promoted main to be counted got '%v'", fields[3])
+	}
+}
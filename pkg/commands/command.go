@@ -1,6 +1,11 @@
 package commands
 
 import (
+	"fmt"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/events"
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-redis/redis"
 )
@@ -12,8 +17,58 @@ type Command interface {
 }
 
 type Context struct {
-	Session   *discordgo.Session
+	Messenger discord.Messenger
+	// GuildID is empty for direct messages
+	GuildID   string
 	ChannelID string
 	Sender    *discordgo.User
 	Redis     *redis.Client
+	// Refresher updates the announcements of events changed by the command, coalescing them with the
+	// refreshes requested by reactions and buttons
+	Refresher *events.Refresher
+	// Settings are the configured guild and theme that names and announcements are rendered with
+	Settings events.Settings
+	// Prefix is the command prefix used in the guild the command was sent from
+	Prefix string
+	// OfficerRoles lists the ids of roles allowed to act on behalf of other members
+	OfficerRoles []string
+}
+
+// CommandPrefix returns the prefix commands are invoked with in the context's guild
+func (ctx Context) CommandPrefix() string {
+	if ctx.Prefix == "" {
+		return DefaultPrefix
+	}
+
+	return ctx.Prefix
+}
+
+// Source returns how the command was sent, for recording in the audit log
+func (ctx Context) Source() audit.Source {
+	if ctx.GuildID == "" {
+		return audit.SourceDM
+	}
+
+	return audit.SourceCommand
+}
+
+// record appends an entry to the audit log made by the sender through this command
+func (ctx Context) record(entry audit.Entry) error {
+	entry.Actor = ctx.Sender.ID
+	entry.Source = ctx.Source()
+	err := audit.Record(ctx.Redis, entry)
+	if err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+
+	return nil
+}
+
+func eventIDs(evts []events.Event) []string {
+	ids := make([]string, len(evts))
+	for i, evt := range evts {
+		ids[i] = evt.ID
+	}
+
+	return ids
 }
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+)
+
+const testChannelID = "commands"
+const testAnnounceChannelID = "announcements"
+
+func newTestContext(t *testing.T) (Context, *discord.Fake, *miniredis.Miniredis) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	t.Cleanup(svc.Close)
+
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	sender := &discordgo.User{ID: "sender", Username: "Tester"}
+	fake := discord.NewFake()
+	fake.AddUser(sender)
+	settings := events.Settings{Theme: events.DefaultEmbedTheme}
+	return Context{
+		Messenger: fake,
+		ChannelID: testChannelID,
+		Sender:    sender,
+		Redis:     client,
+		Refresher: events.NewRefresher(fake, client, settings, time.Hour),
+		Settings:  settings,
+	}, fake, svc
+}
+
+// announceTestEvent schedules and announces an event so that updates to its announcement can be observed
+func announceTestEvent(t *testing.T, ctx Context, id string, date time.Time) events.Event {
+	evt := events.Event{
+		ID:                id,
+		Name:              "Raid",
+		Time:              date,
+		AnnounceChannelID: testAnnounceChannelID,
+	}
+	err := events.ScheduleEvent(ctx.Redis, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = events.AnnounceEvent(ctx.Messenger, ctx.Redis, ctx.Settings, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt, err = events.GetEventById(ctx.Redis, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return evt
+}
+
+func dayRange(date time.Time) util.DateRange {
+	return util.DateRange{
+		Begin: util.BeginningOfDay(date),
+		End:   util.EndOfDay(date),
+	}
+}
+
+func assertAttendance(t *testing.T, ctx Context, evt events.Event, expAbsent bool, expLate bool) {
+	t.Helper()
+	attendance, err := events.GetAttendanceForEvent(ctx.Redis, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	absent := contains(attendance.Absent, ctx.Sender.ID)
+	late := contains(attendance.Late, ctx.Sender.ID)
+	if absent != expAbsent || late != expLate {
+		t.Errorf("event '%s' expected absent=%v late=%v, got absent=%v late=%v", evt.ID, expAbsent, expLate, absent, late)
+	}
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+
+	return false
+}
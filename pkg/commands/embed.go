@@ -0,0 +1,198 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/bwmarrin/discordgo"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrEmbedUsage = errors.New("expected '<template> [<setting> <value>]' or '<template> reset <setting>'")
+
+var EmbedSpec = Spec{
+	Name:        "embed",
+	Usage:       "<template> [<setting> <value> | reset <setting>]",
+	Description: fmt.Sprintf("show or change how the announcements of a recurring event look, if you can manage the server. The settings are %s. Descriptions are Go templates with {{.Name}}, {{.Date}}, {{.Time}}, {{.Out}}, {{.Late}} and {{.Tentative}}", strings.Join(events.EmbedSettingNames, ", ")),
+	Examples:    []string{"embed MainRaid", "embed MainRaid title Castle Nathria", "embed MainRaid description {{.Date}}, {{.Out}} out", "embed MainRaid reset color"},
+	Parse:       parseEmbed,
+}
+
+func parseEmbed(args []string) (Command, error) {
+	if len(args) == 0 || len(args) == 2 {
+		return nil, &util.ParseError{
+			Input: strings.Join(args, " "),
+			Err:   ErrEmbedUsage,
+		}
+	}
+
+	c := &EmbedCommand{TemplateID: args[0]}
+	if len(args) == 1 {
+		return c, nil
+	}
+
+	c.Setting = strings.ToLower(args[1])
+	c.Value = strings.Join(args[2:], " ")
+	if c.Setting == "reset" {
+		c.Setting = strings.ToLower(args[2])
+		c.Value = ""
+		if len(args) != 3 {
+			return nil, &util.ParseError{
+				Input: strings.Join(args, " "),
+				Err:   ErrEmbedUsage,
+			}
+		}
+	}
+
+	if !containsString(events.EmbedSettingNames, c.Setting) {
+		return nil, &util.ParseError{
+			Input: c.Setting,
+			Err:   fmt.Errorf("%w, expected one of %s", events.ErrUnknownEmbedSetting, strings.Join(events.EmbedSettingNames, ", ")),
+		}
+	}
+
+	c.Change = true
+	return c, nil
+}
+
+// EmbedCommand shows the embed settings of a recurring event, or changes one of them when Change is set. An
+// empty Value resets the setting.
+type EmbedCommand struct {
+	TemplateID string
+	Change     bool
+	Setting    string
+	Value      string
+}
+
+func (c EmbedCommand) Execute(ctx Context) error {
+	admin, err := ctx.CanManageServer()
+	if err != nil {
+		return err
+	}
+
+	if !admin {
+		return c.respond(ctx, "Only members who can manage the server can change announcements.")
+	}
+
+	template, err := events.GetRecurringEventById(ctx.Redis, c.TemplateID)
+	if errors.Is(err, events.ErrUnknownRecurringEvent) {
+		return c.respond(ctx, fmt.Sprintf("There is no recurring event with the id '%s'.", c.TemplateID))
+	} else if err != nil {
+		return fmt.Errorf("get recurring event: %w", err)
+	}
+
+	if !c.Change {
+		return c.show(ctx, template)
+	}
+
+	settings := template.Embed
+	err = settings.Set(c.Setting, c.Value)
+	if errors.Is(err, events.ErrInvalidEmbedSetting) {
+		return c.respond(ctx, fmt.Sprintf("I couldn't change the %s, %s.", c.Setting, err))
+	} else if err != nil {
+		return err
+	}
+
+	err = events.SetEmbedSettings(ctx.Redis, template.ID, settings)
+	if err != nil {
+		return fmt.Errorf("set embed settings: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"template": template.ID,
+		"user":     ctx.Sender.ID,
+		"setting":  c.Setting,
+		"value":    c.Value,
+	}).Info("set embed setting")
+	evts, err := c.reannounce(ctx, template)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.record(audit.Entry{
+		Target: template.ID,
+		Action: audit.TemplateEmbed,
+		Events: eventIDs(evts),
+		Detail: fmt.Sprintf("%s %s", c.Setting, c.Value),
+	})
+	if err != nil {
+		return err
+	}
+
+	if c.Value == "" {
+		return c.respond(ctx, fmt.Sprintf("Reset the %s of %s announcements.", c.Setting, template.Name))
+	}
+
+	return c.respond(ctx, fmt.Sprintf("Changed the %s of %s announcements.", c.Setting, template.Name))
+}
+
+// reannounce requests a refresh of the announcements already posted for upcoming instances of the template
+func (c EmbedCommand) reannounce(ctx Context, template events.RecurringEvent) ([]events.Event, error) {
+	now := time.Now().UTC()
+	evts, err := events.GetEventsForDateRange(ctx.Redis, util.DateRange{
+		Begin: util.BeginningOfDay(now),
+		End:   util.EndOfWeek(now.AddDate(0, 0, 7*template.Horizon())),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get upcoming events: %w", err)
+	}
+
+	announced := []events.Event{}
+	for _, evt := range evts {
+		if evt.RecurringEventID != template.ID || evt.AnnounceMessageID == "" {
+			continue
+		}
+
+		ctx.Refresher.Request(evt)
+		announced = append(announced, evt)
+	}
+
+	return announced, nil
+}
+
+func (c EmbedCommand) show(ctx Context, template events.RecurringEvent) error {
+	fields := make([]*discordgo.MessageEmbedField, len(events.EmbedSettingNames))
+	for i, name := range events.EmbedSettingNames {
+		value := template.Embed.Get(name)
+		if value == "" {
+			value = "default"
+		} else {
+			value = fmt.Sprintf("`%s`", value)
+		}
+
+		fields[i] = &discordgo.MessageEmbedField{
+			Name:   name,
+			Value:  value,
+			Inline: name != "description",
+		}
+	}
+
+	embed := discordgo.MessageEmbed{
+		Author: &discordgo.MessageEmbedAuthor{
+			Name: fmt.Sprintf("Announcements of %s", template.Name),
+		},
+		Description: fmt.Sprintf("Change a setting with `%sembed %s <setting> <value>`.", ctx.CommandPrefix(), template.ID),
+		Fields:      fields,
+	}
+
+	_, err := ctx.Messenger.SendEmbed(ctx.ChannelID, &embed)
+	if err != nil {
+		return fmt.Errorf("send embed settings: %w", err)
+	}
+
+	return nil
+}
+
+func (c EmbedCommand) respond(ctx Context, msg string) error {
+	_, err := ctx.Messenger.SendMessage(ctx.ChannelID, msg)
+	if err != nil {
+		return fmt.Errorf("send response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseEmbed(t *testing.T) {
+	cases := []struct {
+		name   string
+		args   []string
+		exp    EmbedCommand
+		expErr bool
+	}{
+		{"show", []string{"MainRaid"}, EmbedCommand{TemplateID: "MainRaid"}, false},
+		{"set", []string{"MainRaid", "Title", "Castle", "Nathria"}, EmbedCommand{TemplateID: "MainRaid", Change: true, Setting: "title", Value: "Castle Nathria"}, false},
+		{"reset", []string{"MainRaid", "reset", "color"}, EmbedCommand{TemplateID: "MainRaid", Change: true, Setting: "color"}, false},
+		{"missing value", []string{"MainRaid", "title"}, EmbedCommand{}, true},
+		{"unknown setting", []string{"MainRaid", "footer", "text"}, EmbedCommand{}, true},
+		{"empty", []string{}, EmbedCommand{}, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cmd, err := parseEmbed(c.args)
+			var parseErr *util.ParseError
+			if c.expErr {
+				if !errors.As(err, &parseErr) {
+					t.Errorf("expected a parse error got '%v'", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if *cmd.(*EmbedCommand) != c.exp {
+				t.Errorf("expected '%v' got '%v'", c.exp, cmd)
+			}
+		})
+	}
+}
+
+func TestEmbedCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	ctx.GuildID = "guild"
+
+	template := events.RecurringEvent{ID: "raid", Name: "Raid", Weekdays: []time.Weekday{time.Wednesday}}
+	err := events.UpsertRecurringEvent(ctx.Redis, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	set := EmbedCommand{TemplateID: "raid", Change: true, Setting: "title", Value: "Castle Nathria"}
+	err = set.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.LastContent(), "manage the server") {
+		t.Errorf("expected permission to be required got '%s'", fake.LastContent())
+	}
+
+	fake.Permissions[ctx.Sender.ID] = discordgo.PermissionManageServer
+	now := time.Now().UTC()
+	evt := events.Event{
+		ID:                "raid-upcoming",
+		Name:              "Raid",
+		Time:              util.BeginningOfDay(now.AddDate(0, 0, 1)),
+		RecurringEventID:  "raid",
+		AnnounceChannelID: "channel",
+	}
+	err = events.AnnounceEvent(fake, ctx.Redis, ctx.Settings, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	announcement := fake.Sent[len(fake.Sent)-1].ID
+
+	err = set.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	expected := "Changed the title of Raid announcements."
+	if fake.LastContent() != expected {
+		t.Errorf("expected '%s' got '%s'", expected, fake.LastContent())
+	}
+
+	if title := fake.Messages[announcement].Embeds[0].Title; title != "Castle Nathria" {
+		t.Errorf("expected the announcement to be updated got '%s'", title)
+	}
+
+	err = EmbedCommand{TemplateID: "raid", Change: true, Setting: "color", Value: "orange"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.LastContent(), "I couldn't change the color") {
+		t.Errorf("expected the color to be rejected got '%s'", fake.LastContent())
+	}
+
+	err = EmbedCommand{TemplateID: "missing"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.LastContent(), "no recurring event") {
+		t.Errorf("expected an unknown template response got '%s'", fake.LastContent())
+	}
+}
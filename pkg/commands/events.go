@@ -1,47 +1,136 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/discord"
 	"github.com/acastle/esperbot/pkg/events"
 	"github.com/acastle/esperbot/pkg/util"
-	"github.com/bwmarrin/discordgo"
 	log "github.com/sirupsen/logrus"
 )
 
+var EventsSpec = Spec{
+	Name:        "events",
+	Usage:       "[next | <date> | pin]",
+	Description: "list the events of this week, next week or the week of a date with how many members are out or late. If you can manage the server, pin posts a summary of this week that stays up to date",
+	Examples:    []string{"events", "events next", "events Aug 25", "events pin"},
+	Parse:       parseEvents,
+}
+
+func parseEvents(args []string) (Command, error) {
+	if len(args) == 0 {
+		return &EventsCommand{}, nil
+	}
+
+	if len(args) == 1 {
+		switch strings.ToLower(args[0]) {
+		case "pin":
+			return &EventsCommand{Pin: true}, nil
+		case "next":
+			return &EventsCommand{Week: time.Now().UTC().AddDate(0, 0, 7)}, nil
+		}
+	}
+
+	dates, err := util.FlagsToDateRange(args)
+	if err != nil {
+		return nil, fmt.Errorf("parse flags: %w", err)
+	}
+
+	return &EventsCommand{Week: dates.Begin}, nil
+}
+
+// EventsCommand lists the events of a week. When Pin is set the list of the current week is posted as the
+// guild's summary, which is pinned and edited as the events and their attendance change.
 type EventsCommand struct {
+	// Week is any time within the week to list, the current week is listed when it is zero
+	Week time.Time
+	Pin  bool
 }
 
 func (c EventsCommand) Execute(ctx Context) error {
-	now := time.Now().UTC()
-	begin := util.BeginningOfWeek(now)
-	end := util.EndOfWeek(now)
+	if c.Pin {
+		return c.pin(ctx)
+	}
 
-	evts, err := events.GetEventsForWeek(ctx.Redis, now)
-	if err != nil {
-		log.Error(err)
+	week := c.Week
+	if week.IsZero() {
+		week = time.Now().UTC()
 	}
 
-	embed := discordgo.MessageEmbed{
-		Author: &discordgo.MessageEmbedAuthor{
-			Name: "Upcoming events",
-		},
-		Title:       "",
-		Description: fmt.Sprintf("for the week of %s to %s", begin.Format("Monday Jan _2 2006"), end.Format("Monday Jan _2 2006")),
-		Thumbnail: &discordgo.MessageEmbedThumbnail{
-			URL: "https://wow.zamimg.com/images/Icon/large/hilite/default.png",
-		},
-		Fields: []*discordgo.MessageEmbedField{},
+	embed, err := events.GetSummaryEmbed(ctx.Redis, ctx.Settings.Theme, week)
+	if err != nil {
+		return fmt.Errorf("get summary embed: %w", err)
 	}
 
-	for _, evt := range evts {
-		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
-			Name:  evt.Name,
-			Value: evt.Time.Format("Monday Jan _2 2006"),
-		})
+	_, err = ctx.Messenger.SendEmbed(ctx.ChannelID, embed)
+	if err != nil {
+		return fmt.Errorf("send events: %w", err)
 	}
 
-	ctx.Session.ChannelMessageSendEmbed(ctx.ChannelID, &embed)
 	return nil
 }
+
+// pin posts and pins the summary in the channel, the guild's previous summary is unpinned and no longer edited
+func (c EventsCommand) pin(ctx Context) error {
+	admin, err := ctx.CanManageServer()
+	if err != nil {
+		return err
+	}
+
+	if !admin {
+		_, err = ctx.Messenger.SendMessage(ctx.ChannelID, "Only members who can manage the server can pin the summary.")
+		if err != nil {
+			return fmt.Errorf("send response: %w", err)
+		}
+		return nil
+	}
+
+	embed, err := events.GetSummaryEmbed(ctx.Redis, ctx.Settings.Theme, time.Now().UTC())
+	if err != nil {
+		return fmt.Errorf("get summary embed: %w", err)
+	}
+
+	msg, err := ctx.Messenger.SendEmbed(ctx.ChannelID, embed)
+	if err != nil {
+		return fmt.Errorf("send summary: %w", err)
+	}
+
+	err = ctx.Messenger.PinMessage(ctx.ChannelID, msg.ID)
+	if err != nil {
+		return fmt.Errorf("pin summary: %w", err)
+	}
+
+	previous, err := events.GetSummary(ctx.Redis, ctx.GuildID)
+	if err == nil {
+		err = ctx.Messenger.UnpinMessage(previous.ChannelID, previous.MessageID)
+		if err != nil && !discord.IsUnknownMessage(err) {
+			return fmt.Errorf("unpin previous summary: %w", err)
+		}
+	} else if !errors.Is(err, events.ErrNoSummary) {
+		return err
+	}
+
+	err = events.SetSummary(ctx.Redis, events.Summary{
+		GuildID:   ctx.GuildID,
+		ChannelID: ctx.ChannelID,
+		MessageID: msg.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"guild":      ctx.GuildID,
+		"channel_id": ctx.ChannelID,
+		"message_id": msg.ID,
+	}).Info("pin summary")
+	return ctx.record(audit.Entry{
+		Target: ctx.GuildID,
+		Action: audit.SummaryPin,
+		Detail: fmt.Sprintf("in channel %s", ctx.ChannelID),
+	})
+}
@@ -0,0 +1,145 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseEvents(t *testing.T) {
+	thisWeek := util.BeginningOfWeek(time.Now().UTC())
+	cases := []struct {
+		name   string
+		args   []string
+		week   time.Time
+		pin    bool
+		expErr bool
+	}{
+		{"this week", []string{}, thisWeek, false, false},
+		{"next", []string{"next"}, thisWeek.AddDate(0, 0, 7), false, false},
+		{"date", []string{"next", "week"}, thisWeek.AddDate(0, 0, 7), false, false},
+		{"pin", []string{"pin"}, thisWeek, true, false},
+		{"invalid date", []string{"someday"}, time.Time{}, false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cmd, err := parseEvents(c.args)
+			var parseErr *util.ParseError
+			if c.expErr {
+				if !errors.As(err, &parseErr) {
+					t.Errorf("expected a parse error got '%v'", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := cmd.(*EventsCommand)
+			week := got.Week
+			if week.IsZero() {
+				week = time.Now().UTC()
+			}
+
+			if !util.BeginningOfWeek(week).Equal(c.week) || got.Pin != c.pin {
+				t.Errorf("expected the week of '%v' pinned '%v' got '%v'", c.week, c.pin, got)
+			}
+		})
+	}
+}
+
+func TestEventsCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	err := events.ScheduleEvent(ctx.Redis, events.Event{
+		ID:   "raid",
+		Name: "Raid",
+		Time: util.BeginningOfDay(time.Now().UTC()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = EventsCommand{}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Sent) != 1 || len(fake.Sent[0].Embeds) != 1 {
+		t.Fatalf("expected a single embed to be sent, got '%v'", fake.Sent)
+	}
+
+	fields := fake.Sent[0].Embeds[0].Fields
+	if len(fields) != 1 || fields[0].Name != "Raid" {
+		t.Errorf("expected the event to be listed, got '%v'", fields)
+	}
+
+	err = EventsCommand{Week: time.Now().UTC().AddDate(0, 0, 7)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fields := fake.Sent[1].Embeds[0].Fields; len(fields) != 0 {
+		t.Errorf("expected no events next week, got '%v'", fields)
+	}
+}
+
+func TestEventsCommandPin(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	ctx.GuildID = "guild"
+
+	err := EventsCommand{Pin: true}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.LastContent(), "manage the server") {
+		t.Errorf("expected permission to be required got '%s'", fake.LastContent())
+	}
+
+	fake.Permissions[ctx.Sender.ID] = discordgo.PermissionManageServer
+	err = EventsCommand{Pin: true}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := events.GetSummary(ctx.Redis, ctx.GuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !fake.Pinned[first.MessageID] {
+		t.Errorf("expected the summary to be pinned")
+	}
+
+	evt := announceTestEvent(t, ctx, "raid", util.BeginningOfDay(time.Now().UTC()))
+	err = OutCommand{Dates: dayRange(evt.Time)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	fields := fake.Messages[first.MessageID].Embeds[0].Fields
+	if len(fields) != 1 || !strings.Contains(fields[0].Value, "1 out") {
+		t.Errorf("expected the summary to be updated, got '%v'", fields)
+	}
+
+	err = EventsCommand{Pin: true}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := events.GetSummary(ctx.Redis, ctx.GuildID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if second.MessageID == first.MessageID || fake.Pinned[first.MessageID] || !fake.Pinned[second.MessageID] {
+		t.Errorf("expected the new summary to replace '%s' got '%s' pinned '%v'", first.MessageID, second.MessageID, fake.Pinned)
+	}
+}
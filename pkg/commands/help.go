@@ -2,11 +2,36 @@ package commands
 
 import (
 	"fmt"
+	"strings"
+	"unicode"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// HelpSpec returns the help command for a registry. Help is generated from the specs in the registry so that
+// it always lists the commands the parser accepts.
+func HelpSpec(r *Registry) Spec {
+	return Spec{
+		Name:        "help",
+		Usage:       "[command]",
+		Description: "provide a list of available bot commands, or details and examples for a single command",
+		Examples:    []string{"help", "help out"},
+		Parse: func(args []string) (Command, error) {
+			return &HelpCommand{
+				Registry: r,
+				// The topic may be given with a prefix, as in "help !out"
+				Topic: strings.TrimLeftFunc(strings.Join(args, " "), func(r rune) bool {
+					return !unicode.IsLetter(r)
+				}),
+			}, nil
+		},
+	}
+}
+
 type HelpCommand struct {
+	Registry *Registry
+	// Topic is the name of a command to describe in detail, all commands are listed when it is empty
+	Topic string
 }
 
 func (h HelpCommand) Execute(ctx Context) error {
@@ -14,46 +39,98 @@ func (h HelpCommand) Execute(ctx Context) error {
 		Author: &discordgo.MessageEmbedAuthor{
 			Name: "Esperbot help",
 		},
-		Title:       "",
-		Description: "",
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
 			URL: "https://wow.zamimg.com/images/wow/icons/large/inv_misc_questionmark.jpg",
 		},
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name:  "!help",
-				Value: "provide a list of available bot commands",
-			},
-			{
-				Name:  "!events",
-				Value: "list planned events for the upcomming week",
-			},
-			{
-				Name:  "!setname [name]",
-				Value: "sets what name the bot will use for your discord user (ex. !setname RingRingRingBananaPhone)",
-			},
-			{
-				Name:  "!out [<date> to <date>]",
-				Value: "mark yourself absent for all events over a period of time. (ex. !out Dec 10 to Dec 30)",
-			},
-			{
-				Name:  "!in [<date> to <date>]",
-				Value: "mark yourself in for all events over a period of time. (ex. !out Dec 10 to Dec 30)",
-			},
-			{
-				Name:  "!late [<date> to <date>]",
-				Value: "mark yourself late for all events over a period of time. (ex. !out Dec 10 to Dec 30)",
-			},
-			{
-				Name:  "!ontime [<date> to <date>]",
-				Value: "mark yourself on time for all events over a period of time. (ex. !out Dec 10 to Dec 30)",
-			},
-		},
 	}
 
-	_, err := ctx.Session.ChannelMessageSendEmbed(ctx.ChannelID, &embed)
+	if h.Topic == "" {
+		embed.Fields = h.summaryFields(ctx.CommandPrefix())
+	} else {
+		var spec Spec
+		ok := false
+		if h.Registry != nil {
+			spec, ok = h.Registry.Lookup(h.Topic)
+		}
+		if !ok {
+			_, err := ctx.Messenger.SendMessage(ctx.ChannelID, fmt.Sprintf("I don't know the command '%s', try `%shelp` for a list of commands.", h.Topic, ctx.CommandPrefix()))
+			if err != nil {
+				return fmt.Errorf("send response: %w", err)
+			}
+			return nil
+		}
+
+		embed.Title = usageLine(ctx.CommandPrefix(), spec)
+		embed.Description = spec.Description
+		embed.Fields = detailFields(ctx.CommandPrefix(), spec)
+	}
+
+	_, err := ctx.Messenger.SendEmbed(ctx.ChannelID, &embed)
 	if err != nil {
 		return fmt.Errorf("send help message: %w", err)
 	}
 	return nil
 }
+
+func (h HelpCommand) summaryFields(prefix string) []*discordgo.MessageEmbedField {
+	fields := []*discordgo.MessageEmbedField{}
+	if h.Registry == nil {
+		return fields
+	}
+
+	for _, spec := range h.Registry.Specs() {
+		if spec.Hidden {
+			continue
+		}
+
+		value := spec.Description
+		if len(spec.Examples) > 0 {
+			value = value + fmt.Sprintf(" (ex. %s%s)", prefix, spec.Examples[0])
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  usageLine(prefix, spec),
+			Value: value,
+		})
+	}
+
+	return fields
+}
+
+func detailFields(prefix string, spec Spec) []*discordgo.MessageEmbedField {
+	fields := []*discordgo.MessageEmbedField{}
+	if len(spec.Aliases) > 0 {
+		aliases := make([]string, len(spec.Aliases))
+		for i, alias := range spec.Aliases {
+			aliases[i] = prefix + alias
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Aliases",
+			Value: strings.Join(aliases, ", "),
+		})
+	}
+
+	if len(spec.Examples) > 0 {
+		examples := make([]string, len(spec.Examples))
+		for i, example := range spec.Examples {
+			examples[i] = prefix + example
+		}
+
+		fields = append(fields, &discordgo.MessageEmbedField{
+			Name:  "Examples",
+			Value: strings.Join(examples, "\n"),
+		})
+	}
+
+	return fields
+}
+
+// usageLine returns the invocation of a command with its arguments, for example "!out [<date> to <date>]"
+func usageLine(prefix string, spec Spec) string {
+	if spec.Usage == "" {
+		return prefix + spec.Name
+	}
+
+	return fmt.Sprintf("%s%s %s", prefix, spec.Name, spec.Usage)
+}
@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHelpCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	registry := NewDefaultRegistry()
+	err := HelpCommand{Registry: registry}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Sent) != 1 || len(fake.Sent[0].Embeds) != 1 {
+		t.Fatalf("expected a single embed to be sent, got '%v'", fake.Sent)
+	}
+
+	msg := fake.Sent[0]
+	if msg.ChannelID != testChannelID {
+		t.Errorf("expected help in channel '%s' got '%s'", testChannelID, msg.ChannelID)
+	}
+
+	fields := msg.Embeds[0].Fields
+	visible := 0
+	for _, spec := range registry.Specs() {
+		if !spec.Hidden {
+			visible++
+		}
+	}
+	if len(fields) != visible {
+		t.Fatalf("expected '%v' commands got '%v'", visible, len(fields))
+	}
+
+	for _, field := range fields {
+		if strings.HasPrefix(field.Name, "!schedule") || strings.HasPrefix(field.Name, "!announce") {
+			t.Errorf("expected hidden command '%s' to be omitted", field.Name)
+		}
+
+		// Every example must invoke the command it documents
+		idx := strings.Index(field.Value, "(ex. ")
+		if idx == -1 {
+			continue
+		}
+
+		name := strings.Fields(field.Name)[0]
+		example := field.Value[idx+len("(ex. "):]
+		if !strings.HasPrefix(example, name) {
+			t.Errorf("expected example for '%s' got '%s'", name, example)
+		}
+	}
+}
+
+func TestHelpCommandTopic(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	err := HelpCommand{Registry: NewDefaultRegistry(), Topic: "absent"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Sent) != 1 || len(fake.Sent[0].Embeds) != 1 {
+		t.Fatalf("expected a single embed to be sent, got '%v'", fake.Sent)
+	}
+
+	embed := fake.Sent[0].Embeds[0]
+	if !strings.HasPrefix(embed.Title, "!out ") {
+		t.Errorf("expected usage for '!out' got '%s'", embed.Title)
+	}
+
+	if len(embed.Fields) != 2 || !strings.Contains(embed.Fields[1].Value, "!out every wednesday") {
+		t.Errorf("expected aliases and examples got '%v'", embed.Fields)
+	}
+}
+
+func TestHelpCommandUnknownTopic(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	err := HelpCommand{Registry: NewDefaultRegistry(), Topic: "foo"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(fake.LastContent(), "'foo'") {
+		t.Errorf("expected unknown command response got '%s'", fake.LastContent())
+	}
+}
@@ -1,55 +1,74 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/acastle/esperbot/pkg/audit"
 	"github.com/acastle/esperbot/pkg/events"
 	"github.com/acastle/esperbot/pkg/util"
 	log "github.com/sirupsen/logrus"
 )
 
+var InSpec = Spec{
+	Name:        "in",
+	Usage:       attendanceUsage,
+	Description: "mark yourself in for all events over a period of time, confirming any tentative ones, or clear a weekly absence. Officers can mention a member to act for them",
+	Examples:    []string{"in Dec 10 to Dec 30", "in every wednesday"},
+	Parse: parseAttendance(events.Absent, true, func(dates util.DateRange, target string) Command {
+		return &InCommand{Dates: dates, Target: target}
+	}),
+}
+
 type InCommand struct {
 	Dates util.DateRange
+	// Target is the id of the member to act for, the sender is used when it is empty
+	Target string
 }
 
 func (c InCommand) Execute(ctx Context) error {
-	err := events.UserListRemoveForRange(ctx.Redis, c.Dates, ctx.Sender.ID, events.Absent)
-	if err != nil {
-		return fmt.Errorf("mark user absent for day: %w", err)
+	user, err := ctx.Subject(c.Target)
+	if errors.Is(err, ErrNotOfficer) {
+		return respondNotOfficer(ctx)
+	} else if err != nil {
+		return fmt.Errorf("resolve target: %w", err)
 	}
 
-	evts, err := events.GetEventsForDateRange(ctx.Redis, c.Dates)
-	if err != nil {
-		return fmt.Errorf("get events for range: %w", err)
+	// Marking yourself in also confirms any tentative attendance
+	var evts []events.Event
+	for _, list := range []events.UserListType{events.Absent, events.Tentative} {
+		evts, err = events.UserListRemoveForRange(ctx.Redis, c.Dates, user, list)
+		if err != nil {
+			return fmt.Errorf("mark user in for day: %w", err)
+		}
 	}
 
 	log.WithFields(log.Fields{
+		"user":  user,
+		"actor": ctx.Sender.ID,
 		"begin": c.Dates.Begin,
 		"end":   c.Dates.End,
-	}).Info("mark user late for range")
+	}).Info("mark user in for range")
 	for _, evt := range evts {
-		log.WithFields(log.Fields{
-			"user":  ctx.Sender.ID,
-			"list":  events.Absent,
-			"event": evt.ID,
-		}).Info("remove user from event list")
-		err = events.EventUserListRemove(ctx.Redis, evt, ctx.Sender.ID, events.Absent)
-		if err != nil {
-			return fmt.Errorf("remove user from user list: %w", err)
-		}
+		ctx.Refresher.Request(evt)
+	}
 
-		err = events.AnnounceEvent(ctx.Session, ctx.Redis, evt)
-		if err != nil {
-			return fmt.Errorf("announce event: %w", err)
-		}
+	err = ctx.record(audit.Entry{
+		Target: user,
+		Action: audit.AttendanceRemove,
+		Events: eventIDs(evts),
+		Detail: fmt.Sprintf("%s and %s %s", events.Absent, events.Tentative, util.FormatDateRange(c.Dates)),
+	})
+	if err != nil {
+		return err
 	}
 
-	alias, err := events.GetUserAlias(ctx.Redis, ctx.Session, ctx.Sender.ID)
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, user)
 	if err != nil {
 		return fmt.Errorf("fetch user alias: %w", err)
 	}
 
-	_, err = ctx.Session.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("Marked '%s' in for all events between %s and %s", alias, c.Dates.Begin.Format(StandardDateFormat), c.Dates.End.Format(StandardDateFormat)))
+	_, err = ctx.Messenger.SendMessage(ctx.ChannelID, fmt.Sprintf("Marked '%s' in for all events between %s and %s", alias, c.Dates.Begin.Format(StandardDateFormat), c.Dates.End.Format(StandardDateFormat)))
 	if err != nil {
 		return fmt.Errorf("send response: %w", err)
 	}
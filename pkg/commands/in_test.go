@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/audit"
+)
+
+func TestInCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+	thu := announceTestEvent(t, ctx, "thu", wednesday.AddDate(0, 0, 1))
+	err := OutCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = OutCommand{Dates: dayRange(wednesday.AddDate(0, 0, 1))}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = InCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAttendance(t, ctx, wed, false, false)
+	assertAttendance(t, ctx, thu, true, false)
+	expected := "Marked 'Tester' in for all events between Wednesday Aug 25 2021 and Wednesday Aug 25 2021"
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+
+	entries, err := audit.ForEvent(ctx.Redis, wed.ID, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Detail, "absent and tentative ") {
+		t.Errorf("expected the entry to mention both lists got '%v'", entries)
+	}
+}
@@ -0,0 +1,25 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLateCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+	next := announceTestEvent(t, ctx, "next", wednesday.AddDate(0, 0, 7))
+
+	err := LateCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAttendance(t, ctx, wed, false, true)
+	assertAttendance(t, ctx, next, false, false)
+	expected := "Marked 'Tester' late for all events between Wednesday Aug 25 2021 and Wednesday Aug 25 2021"
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+}
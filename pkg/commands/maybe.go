@@ -0,0 +1,74 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+var MaybeSpec = Spec{
+	Name:        "maybe",
+	Aliases:     []string{"tentative"},
+	Usage:       attendanceUsage,
+	Description: "mark yourself tentative for all events over a period of time, or every week on the given days. You will be asked to confirm the day before each event. Officers can mention a member to act for them",
+	Examples:    []string{"maybe friday", "maybe every thursday"},
+	Parse: parseAttendance(events.Tentative, false, func(dates util.DateRange, target string) Command {
+		return &MaybeCommand{Dates: dates, Target: target}
+	}),
+}
+
+type MaybeCommand struct {
+	Dates util.DateRange
+	// Target is the id of the member to act for, the sender is used when it is empty
+	Target string
+}
+
+func (c MaybeCommand) Execute(ctx Context) error {
+	user, err := ctx.Subject(c.Target)
+	if errors.Is(err, ErrNotOfficer) {
+		return respondNotOfficer(ctx)
+	} else if err != nil {
+		return fmt.Errorf("resolve target: %w", err)
+	}
+
+	evts, err := events.UserListAddForRange(ctx.Redis, c.Dates, user, events.Tentative, ctx.Sender.ID)
+	if err != nil {
+		return fmt.Errorf("mark user tentative for day: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"user":  user,
+		"actor": ctx.Sender.ID,
+		"begin": c.Dates.Begin,
+		"end":   c.Dates.End,
+	}).Info("mark user tentative for range")
+	for _, evt := range evts {
+		ctx.Refresher.Request(evt)
+	}
+
+	err = ctx.record(audit.Entry{
+		Target: user,
+		Action: audit.AttendanceAdd,
+		Events: eventIDs(evts),
+		Detail: fmt.Sprintf("%s %s", events.Tentative, util.FormatDateRange(c.Dates)),
+	})
+	if err != nil {
+		return err
+	}
+
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, user)
+	if err != nil {
+		return fmt.Errorf("fetch user alias: %w", err)
+	}
+
+	_, err = ctx.Messenger.SendMessage(ctx.ChannelID, fmt.Sprintf("Marked '%s' tentative for all events between %s and %s", alias, c.Dates.Begin.Format(StandardDateFormat), c.Dates.End.Format(StandardDateFormat)))
+	if err != nil {
+		return fmt.Errorf("send response: %w", err)
+	}
+
+	return nil
+}
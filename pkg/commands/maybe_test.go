@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+)
+
+func TestMaybeCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+
+	err := MaybeCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	expected := "Marked 'Tester' tentative for all events between Wednesday Aug 25 2021 and Wednesday Aug 25 2021"
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+
+	assertTentative(t, ctx, wed, true)
+	embed := fake.Messages[wed.AnnounceMessageID].Embeds[0]
+	if embed.Fields[2].Name != "❔ Tentative" || embed.Fields[2].Value != "Tester\n" {
+		t.Errorf("expected the tentative field in the embed got '%v'", embed.Fields[2])
+	}
+
+	err = InCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	assertTentative(t, ctx, wed, false)
+}
+
+func TestMaybeCommandAfterOut(t *testing.T) {
+	ctx, fake, svc := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+
+	err := OutCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = LateCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = MaybeCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	assertAttendance(t, ctx, wed, false, false)
+	assertTentative(t, ctx, wed, true)
+	for _, list := range []events.UserListType{events.Absent, events.Late} {
+		if ok, _ := svc.SIsMember(events.UserListKeyForDate(wednesday, list), ctx.Sender.ID); ok {
+			t.Errorf("expected the sender to be taken off the %s list for the day", list)
+		}
+	}
+
+	embed := fake.Messages[wed.AnnounceMessageID].Embeds[0]
+	if embed.Fields[0].Value == "Tester\n" || embed.Fields[1].Value == "Tester\n" {
+		t.Errorf("expected the sender only under tentative got '%v'", embed.Fields)
+	}
+}
+
+func assertTentative(t *testing.T, ctx Context, evt events.Event, expected bool) {
+	t.Helper()
+	attendance, err := events.GetAttendanceForEvent(ctx.Redis, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if tentative := contains(attendance.Tentative, ctx.Sender.ID); tentative != expected {
+		t.Errorf("event '%s' expected tentative=%v got %v", evt.ID, expected, tentative)
+	}
+}
@@ -0,0 +1,102 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var ErrNotOfficer = errors.New("only officers can change attendance for other members")
+
+var mentionPattern = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+// ParseMention returns the user id from a mention such as "<@123>" or "<@!123>"
+func ParseMention(s string) (string, bool) {
+	match := mentionPattern.FindStringSubmatch(s)
+	if match == nil {
+		return "", false
+	}
+
+	return match[1], true
+}
+
+// IsOfficer returns true when the sender holds one of the officer roles or administers the server
+func (ctx Context) IsOfficer() (bool, error) {
+	if ctx.GuildID == "" {
+		return false, nil
+	}
+
+	perms, err := ctx.Messenger.UserChannelPermissions(ctx.Sender.ID, ctx.ChannelID)
+	if err != nil {
+		return false, fmt.Errorf("get sender permissions: %w", err)
+	}
+
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return true, nil
+	}
+
+	if len(ctx.OfficerRoles) == 0 {
+		return false, nil
+	}
+
+	member, err := ctx.Messenger.Member(ctx.GuildID, ctx.Sender.ID)
+	if err != nil {
+		return false, fmt.Errorf("get sender member: %w", err)
+	}
+
+	for _, role := range member.Roles {
+		for _, officer := range ctx.OfficerRoles {
+			if role == officer {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// CanManageServer returns true when the sender can manage the server the command was sent in, direct messages
+// are never sent from a server
+func (ctx Context) CanManageServer() (bool, error) {
+	if ctx.GuildID == "" {
+		return false, nil
+	}
+
+	perms, err := ctx.Messenger.UserChannelPermissions(ctx.Sender.ID, ctx.ChannelID)
+	if err != nil {
+		return false, fmt.Errorf("get sender permissions: %w", err)
+	}
+
+	return perms&(discordgo.PermissionManageServer|discordgo.PermissionAdministrator) != 0, nil
+}
+
+// Subject returns the id of the member a command acts on, the target when one was mentioned or otherwise the
+// sender. ErrNotOfficer is returned when the sender may not act on behalf of the target.
+func (ctx Context) Subject(target string) (string, error) {
+	if target == "" || target == ctx.Sender.ID {
+		return ctx.Sender.ID, nil
+	}
+
+	officer, err := ctx.IsOfficer()
+	if err != nil {
+		return "", err
+	}
+
+	if !officer {
+		return "", ErrNotOfficer
+	}
+
+	return target, nil
+}
+
+// respondNotOfficer tells the sender they cannot act for another member
+func respondNotOfficer(ctx Context) error {
+	_, err := ctx.Messenger.SendMessage(ctx.ChannelID, fmt.Sprintf("Sorry, %s.", ErrNotOfficer))
+	if err != nil {
+		return fmt.Errorf("send response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestParseMention(t *testing.T) {
+	cases := []struct {
+		name  string
+		input string
+		expID string
+		expOk bool
+	}{
+		{"user mention", "<@123>", "123", true},
+		{"nickname mention", "<@!123>", "123", true},
+		{"role mention", "<@&123>", "", false},
+		{"plain text", "friday", "", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			id, ok := ParseMention(c.input)
+			if id != c.expID || ok != c.expOk {
+				t.Errorf("expected '%v' '%v' got '%v' '%v'", c.expID, c.expOk, id, ok)
+			}
+		})
+	}
+}
+
+func TestSubject(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	ctx.GuildID = "guild"
+	ctx.OfficerRoles = []string{"officer"}
+	fake.AddMember(&discordgo.Member{GuildID: "guild", User: ctx.Sender, Roles: []string{"raider"}})
+
+	user, err := ctx.Subject("")
+	if err != nil || user != ctx.Sender.ID {
+		t.Errorf("expected the sender got '%v' '%v'", user, err)
+	}
+
+	_, err = ctx.Subject("member")
+	if !errors.Is(err, ErrNotOfficer) {
+		t.Errorf("expected '%v' got '%v'", ErrNotOfficer, err)
+	}
+
+	fake.AddMember(&discordgo.Member{GuildID: "guild", User: ctx.Sender, Roles: []string{"raider", "officer"}})
+	user, err = ctx.Subject("member")
+	if err != nil || user != "member" {
+		t.Errorf("expected officer to act for 'member' got '%v' '%v'", user, err)
+	}
+
+	ctx.OfficerRoles = nil
+	fake.Permissions[ctx.Sender.ID] = discordgo.PermissionAdministrator
+	user, err = ctx.Subject("member")
+	if err != nil || user != "member" {
+		t.Errorf("expected administrator to act for 'member' got '%v' '%v'", user, err)
+	}
+}
+
+func TestOutCommandForMember(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	ctx.GuildID = "guild"
+	ctx.OfficerRoles = []string{"officer"}
+	member := &discordgo.User{ID: "member", Username: "Member"}
+	fake.AddUser(member)
+	fake.AddMember(&discordgo.Member{GuildID: "guild", User: ctx.Sender})
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+
+	err := OutCommand{Dates: dayRange(wednesday), Target: member.ID}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(fake.LastContent(), ErrNotOfficer.Error()) {
+		t.Errorf("expected non officers to be refused got '%s'", fake.LastContent())
+	}
+
+	fake.AddMember(&discordgo.Member{GuildID: "guild", User: ctx.Sender, Roles: []string{"officer"}})
+	err = OutCommand{Dates: dayRange(wednesday), Target: member.ID}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	attendance, err := events.GetAttendanceForEvent(ctx.Redis, wed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !contains(attendance.Absent, member.ID) || contains(attendance.Absent, ctx.Sender.ID) {
+		t.Errorf("expected only the member to be absent got '%v'", attendance.Absent)
+	}
+
+	if attendance.SetByFor(events.Absent, member.ID) != ctx.Sender.ID {
+		t.Errorf("expected the change to be attributed to '%s' got '%v'", ctx.Sender.ID, attendance.SetBy)
+	}
+
+	embed := fake.Edits[len(fake.Edits)-1]
+	if !strings.Contains(embed.Fields[0].Value, "Member (by Tester)") {
+		t.Errorf("expected the embed to note who made the change got '%s'", embed.Fields[0].Value)
+	}
+
+	expected := "Marked 'Member' out for all events between Wednesday Aug 25 2021 and Wednesday Aug 25 2021"
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/util"
+)
+
+func TestOnTimeCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+	next := announceTestEvent(t, ctx, "next", wednesday.AddDate(0, 0, 7))
+	err := LateCommand{Dates: util.DateRange{Begin: wednesday, End: util.EndOfDay(wednesday.AddDate(0, 0, 7))}}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAttendance(t, ctx, next, false, true)
+	err = OnTimeCommand{Dates: dayRange(wednesday.AddDate(0, 0, 7))}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAttendance(t, ctx, wed, false, true)
+	assertAttendance(t, ctx, next, false, false)
+	expected := "Marked 'Tester' on time for all events between Wednesday Sep  1 2021 and Wednesday Sep  1 2021"
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+}
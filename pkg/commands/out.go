@@ -1,55 +1,71 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/acastle/esperbot/pkg/audit"
 	"github.com/acastle/esperbot/pkg/events"
 	"github.com/acastle/esperbot/pkg/util"
 	log "github.com/sirupsen/logrus"
 )
 
+var OutSpec = Spec{
+	Name:        "out",
+	Aliases:     []string{"absent"},
+	Usage:       attendanceUsage,
+	Description: "mark yourself absent for all events over a period of time, or every week on the given days. Dates can be relative such as tomorrow, next wed or next 2 weeks. Officers can mention a member to act for them",
+	Examples:    []string{"out Dec 10 to Dec 30", "out tomorrow", "out every wednesday until Sep 1", "out @member friday"},
+	Parse: parseAttendance(events.Absent, false, func(dates util.DateRange, target string) Command {
+		return &OutCommand{Dates: dates, Target: target}
+	}),
+}
+
 type OutCommand struct {
 	Dates util.DateRange
+	// Target is the id of the member to act for, the sender is used when it is empty
+	Target string
 }
 
 func (c OutCommand) Execute(ctx Context) error {
-	err := events.UserListAddForRange(ctx.Redis, c.Dates, ctx.Sender.ID, events.Absent)
-	if err != nil {
-		return fmt.Errorf("mark user absent for day: %w", err)
+	user, err := ctx.Subject(c.Target)
+	if errors.Is(err, ErrNotOfficer) {
+		return respondNotOfficer(ctx)
+	} else if err != nil {
+		return fmt.Errorf("resolve target: %w", err)
 	}
 
-	evts, err := events.GetEventsForDateRange(ctx.Redis, c.Dates)
+	evts, err := events.UserListAddForRange(ctx.Redis, c.Dates, user, events.Absent, ctx.Sender.ID)
 	if err != nil {
-		return fmt.Errorf("get events for range: %w", err)
+		return fmt.Errorf("mark user absent for day: %w", err)
 	}
 
 	log.WithFields(log.Fields{
+		"user":  user,
+		"actor": ctx.Sender.ID,
 		"begin": c.Dates.Begin,
 		"end":   c.Dates.End,
 	}).Info("mark user out for range")
 	for _, evt := range evts {
-		log.WithFields(log.Fields{
-			"user":  ctx.Sender.ID,
-			"list":  events.Absent,
-			"event": evt.ID,
-		}).Info("add user to event list")
-		err = events.EventUserListAdd(ctx.Redis, evt, ctx.Sender.ID, events.Absent)
-		if err != nil {
-			return fmt.Errorf("add user to user list: %w", err)
-		}
-
-		err = events.AnnounceEvent(ctx.Session, ctx.Redis, evt)
-		if err != nil {
-			return fmt.Errorf("announce event: %w", err)
-		}
+		ctx.Refresher.Request(evt)
+	}
+
+	err = ctx.record(audit.Entry{
+		Target: user,
+		Action: audit.AttendanceAdd,
+		Events: eventIDs(evts),
+		Detail: fmt.Sprintf("%s %s", events.Absent, util.FormatDateRange(c.Dates)),
+	})
+	if err != nil {
+		return err
 	}
 
-	alias, err := events.GetUserAlias(ctx.Redis, ctx.Session, ctx.Sender.ID)
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, user)
 	if err != nil {
 		return fmt.Errorf("fetch user alias: %w", err)
 	}
 
-	_, err = ctx.Session.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("Marked '%s' out for all events between %s and %s", alias, c.Dates.Begin.Format(StandardDateFormat), c.Dates.End.Format(StandardDateFormat)))
+	_, err = ctx.Messenger.SendMessage(ctx.ChannelID, fmt.Sprintf("Marked '%s' out for all events between %s and %s", alias, c.Dates.Begin.Format(StandardDateFormat), c.Dates.End.Format(StandardDateFormat)))
 	if err != nil {
 		return fmt.Errorf("send response: %w", err)
 	}
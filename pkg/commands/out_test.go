@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+	thu := announceTestEvent(t, ctx, "thu", wednesday.AddDate(0, 0, 1))
+	edits := len(fake.Edits)
+
+	err := OutCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx.Refresher.Flush()
+
+	assertAttendance(t, ctx, wed, true, false)
+	assertAttendance(t, ctx, thu, false, false)
+	if len(fake.Edits) != edits+1 {
+		t.Errorf("expected the announcement to be edited once, got %d edits", len(fake.Edits)-edits)
+	}
+
+	expected := "Marked 'Tester' out for all events between Wednesday Aug 25 2021 and Wednesday Aug 25 2021"
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+}
+
+func TestOutCommandRefreshesThroughRefresher(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	announceTestEvent(t, ctx, "wed", wednesday)
+	edits := len(fake.Edits)
+
+	err := OutCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = LateCommand{Dates: dayRange(wednesday)}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Edits) != edits {
+		t.Errorf("expected the commands not to edit the announcement themselves, got %d edits", len(fake.Edits)-edits)
+	}
+
+	ctx.Refresher.Flush()
+	if len(fake.Edits) != edits+1 {
+		t.Errorf("expected both changes to be refreshed with a single edit, got %d edits", len(fake.Edits)-edits)
+	}
+}
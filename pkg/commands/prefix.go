@@ -0,0 +1,140 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultPrefix = "!"
+const MaxPrefixLength = 5
+
+var ErrInvalidPrefix = errors.New("prefixes must be 1 to 5 characters without spaces, letters or digits")
+var ErrPrefixInDirectMessage = errors.New("the prefix can only be changed in a guild")
+
+func GuildPrefixKey(guildID string) string {
+	return fmt.Sprintf("prefix:%s", guildID)
+}
+
+// GetGuildPrefix returns the command prefix configured for a guild, or DefaultPrefix when none is configured.
+// Direct messages have no guild and always use the default prefix.
+func GetGuildPrefix(r *redis.Client, guildID string) (string, error) {
+	if guildID == "" {
+		return DefaultPrefix, nil
+	}
+
+	prefix, err := r.Get(GuildPrefixKey(guildID)).Result()
+	if err == redis.Nil {
+		return DefaultPrefix, nil
+	} else if err != nil {
+		return "", fmt.Errorf("get guild prefix: %w", err)
+	}
+
+	return prefix, nil
+}
+
+// SetGuildPrefix stores the command prefix for a guild, setting the default prefix removes the override
+func SetGuildPrefix(r *redis.Client, guildID string, prefix string) error {
+	err := ValidatePrefix(prefix)
+	if err != nil {
+		return err
+	}
+
+	if prefix == DefaultPrefix {
+		err = r.Del(GuildPrefixKey(guildID)).Err()
+	} else {
+		err = r.Set(GuildPrefixKey(guildID), prefix, 0).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("set guild prefix: %w", err)
+	}
+
+	return nil
+}
+
+// ValidatePrefix rejects prefixes that could not be told apart from ordinary messages or mentions
+func ValidatePrefix(prefix string) error {
+	if prefix == "" || len([]rune(prefix)) > MaxPrefixLength || strings.HasPrefix(prefix, "<") {
+		return ErrInvalidPrefix
+	}
+
+	for _, r := range prefix {
+		if unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return ErrInvalidPrefix
+		}
+	}
+
+	return nil
+}
+
+var PrefixSpec = Spec{
+	Name:        "prefix",
+	Usage:       "[prefix]",
+	Description: "show the command prefix for this server, or change it if you can manage the server. Commands can also be sent by mentioning the bot",
+	Examples:    []string{"prefix ?"},
+	Parse: func(args []string) (Command, error) {
+		return &PrefixCommand{
+			Prefix: strings.Join(args, ""),
+		}, nil
+	},
+}
+
+// PrefixCommand shows the guild's command prefix, or changes it when Prefix is set
+type PrefixCommand struct {
+	Prefix string
+}
+
+func (c PrefixCommand) Execute(ctx Context) error {
+	if c.Prefix == "" {
+		return c.respond(ctx, fmt.Sprintf("Commands in this server start with `%s`, for example `%shelp`.", ctx.CommandPrefix(), ctx.CommandPrefix()))
+	}
+
+	if ctx.GuildID == "" {
+		return c.respond(ctx, fmt.Sprintf("I couldn't change the prefix, %s.", ErrPrefixInDirectMessage))
+	}
+
+	admin, err := ctx.CanManageServer()
+	if err != nil {
+		return err
+	}
+
+	if !admin {
+		return c.respond(ctx, "Only members who can manage the server can change the prefix.")
+	}
+
+	err = SetGuildPrefix(ctx.Redis, ctx.GuildID, c.Prefix)
+	if errors.Is(err, ErrInvalidPrefix) {
+		return c.respond(ctx, fmt.Sprintf("I couldn't change the prefix to '%s', %s.", c.Prefix, err))
+	} else if err != nil {
+		return err
+	}
+
+	err = ctx.record(audit.Entry{
+		Action: audit.PrefixSet,
+		Detail: c.Prefix,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"guild":  ctx.GuildID,
+		"user":   ctx.Sender.ID,
+		"prefix": c.Prefix,
+	}).Info("set guild prefix")
+	return c.respond(ctx, fmt.Sprintf("Commands in this server now start with `%s`, for example `%shelp`.", c.Prefix, c.Prefix))
+}
+
+func (c PrefixCommand) respond(ctx Context, msg string) error {
+	_, err := ctx.Messenger.SendMessage(ctx.ChannelID, msg)
+	if err != nil {
+		return fmt.Errorf("send response: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestValidatePrefix(t *testing.T) {
+	cases := []struct {
+		name   string
+		prefix string
+		expErr error
+	}{
+		{"single character", "?", nil},
+		{"multiple characters", "$$", nil},
+		{"empty", "", ErrInvalidPrefix},
+		{"too long", "!!!!!!", ErrInvalidPrefix},
+		{"letters", "bot", ErrInvalidPrefix},
+		{"whitespace", "! ", ErrInvalidPrefix},
+		{"mention", "<@", ErrInvalidPrefix},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidatePrefix(c.prefix)
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("expected '%v' got '%v'", c.expErr, err)
+			}
+		})
+	}
+}
+
+func TestGuildPrefix(t *testing.T) {
+	ctx, _, svc := newTestContext(t)
+	prefix, err := GetGuildPrefix(ctx.Redis, "guild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != DefaultPrefix {
+		t.Errorf("expected '%s' got '%s'", DefaultPrefix, prefix)
+	}
+
+	err = SetGuildPrefix(ctx.Redis, "guild", "?")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix, err = GetGuildPrefix(ctx.Redis, "guild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "?" {
+		t.Errorf("expected '?' got '%s'", prefix)
+	}
+
+	prefix, err = GetGuildPrefix(ctx.Redis, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != DefaultPrefix {
+		t.Errorf("expected direct messages to use '%s' got '%s'", DefaultPrefix, prefix)
+	}
+
+	err = SetGuildPrefix(ctx.Redis, "guild", DefaultPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Exists(GuildPrefixKey("guild")) {
+		t.Error("expected setting the default prefix to remove the override")
+	}
+}
+
+func TestPrefixCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	ctx.GuildID = "guild"
+
+	err := PrefixCommand{Prefix: "?"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.LastContent(), "manage the server") {
+		t.Errorf("expected permission to be required got '%s'", fake.LastContent())
+	}
+
+	fake.Permissions[ctx.Sender.ID] = discordgo.PermissionManageServer
+	err = PrefixCommand{Prefix: "?"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix, err := GetGuildPrefix(ctx.Redis, "guild")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prefix != "?" {
+		t.Errorf("expected '?' got '%s'", prefix)
+	}
+
+	err = PrefixCommand{Prefix: "abc"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.LastContent(), ErrInvalidPrefix.Error()) {
+		t.Errorf("expected invalid prefix response got '%s'", fake.LastContent())
+	}
+
+	ctx.Prefix = "?"
+	err = PrefixCommand{}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fake.LastContent(), "`?help`") {
+		t.Errorf("expected current prefix got '%s'", fake.LastContent())
+	}
+}
@@ -0,0 +1,148 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/audit"
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// RecurringAttendanceCommand stores a weekly attendance rule for the sender, or clears it when Clear is set.
+// Rules are applied to events as they are scheduled as well as to any matching events already on the
+// calendar.
+type RecurringAttendanceCommand struct {
+	Recurrence util.Recurrence
+	List       events.UserListType
+	Clear      bool
+	// Target is the id of the member to act for, the sender is used when it is empty
+	Target string
+}
+
+func (c RecurringAttendanceCommand) Execute(ctx Context) error {
+	user, err := ctx.Subject(c.Target)
+	if errors.Is(err, ErrNotOfficer) {
+		return respondNotOfficer(ctx)
+	} else if err != nil {
+		return fmt.Errorf("resolve target: %w", err)
+	}
+
+	log.WithFields(log.Fields{
+		"user":     user,
+		"actor":    ctx.Sender.ID,
+		"list":     c.List,
+		"weekdays": c.Recurrence.Weekdays,
+		"until":    c.Recurrence.Until,
+		"clear":    c.Clear,
+	}).Info("update attendance rule")
+
+	// Events are read before the rule is stored so that a failure leaves no rule behind
+	evts, err := events.GetEventsForDateRange(ctx.Redis, ruleRange(c.Recurrence, time.Now().UTC()))
+	if err != nil {
+		return fmt.Errorf("get events for range: %w", err)
+	}
+
+	if c.Clear {
+		err = events.RemoveAttendanceRules(ctx.Redis, user, c.List, c.Recurrence.Weekdays)
+	} else {
+		err = events.AddAttendanceRules(ctx.Redis, user, c.List, c.Recurrence)
+	}
+	if err != nil {
+		return fmt.Errorf("update attendance rules: %w", err)
+	}
+
+	affected := []events.Event{}
+	for _, evt := range evts {
+		if !c.Recurrence.Includes(evt.Time) {
+			continue
+		}
+		affected = append(affected, evt)
+
+		if c.Clear {
+			err = events.EventUserListRemove(ctx.Redis, evt, user, c.List)
+		} else {
+			err = events.EventUserListAddBy(ctx.Redis, evt, user, c.List, ctx.Sender.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("update user list: %w", err)
+		}
+
+		ctx.Refresher.Request(evt)
+	}
+
+	action := audit.RuleAdd
+	if c.Clear {
+		action = audit.RuleRemove
+	}
+	err = ctx.record(audit.Entry{
+		Target: user,
+		Action: action,
+		Events: eventIDs(affected),
+		Detail: c.detail(),
+	})
+	if err != nil {
+		return err
+	}
+
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, user)
+	if err != nil {
+		return fmt.Errorf("fetch user alias: %w", err)
+	}
+
+	_, err = ctx.Messenger.SendMessage(ctx.ChannelID, c.response(alias))
+	if err != nil {
+		return fmt.Errorf("send response: %w", err)
+	}
+
+	return nil
+}
+
+// ruleRange returns the days of the events already on the calendar that a rule change applies to, from today
+// until the rule ends or for at most 51 weeks. Events are read a whole week at a time and at most a year at
+// once, so the range leaves room for the start of the current week.
+func ruleRange(rec util.Recurrence, now time.Time) util.DateRange {
+	begin := util.BeginningOfDay(now)
+	end := util.EndOfDay(begin.AddDate(0, 0, 7*51))
+	if !rec.Until.IsZero() && rec.Until.Before(end) {
+		end = util.EndOfDay(rec.Until)
+	}
+
+	return util.DateRange{Begin: begin, End: end}
+}
+
+func (c RecurringAttendanceCommand) response(alias string) string {
+	days := c.weekdays()
+	if c.Clear {
+		return fmt.Sprintf("Cleared '%s' from the %s list every %s", alias, c.List, days)
+	}
+
+	msg := fmt.Sprintf("Marked '%s' %s every %s", alias, c.List, days)
+	if !c.Recurrence.Until.IsZero() {
+		msg = msg + fmt.Sprintf(" until %s", c.Recurrence.Until.Format(StandardDateFormat))
+	}
+
+	return msg
+}
+
+// detail describes the rule for the audit log, for example "absent every Wednesday until Sep 1 2021"
+func (c RecurringAttendanceCommand) detail() string {
+	detail := fmt.Sprintf("%s every %s", c.List, c.weekdays())
+	if !c.Recurrence.Until.IsZero() {
+		detail = detail + fmt.Sprintf(" until %s", util.FormatDateRange(util.DateRange{Begin: c.Recurrence.Until, End: c.Recurrence.Until}))
+	}
+
+	return detail
+}
+
+func (c RecurringAttendanceCommand) weekdays() string {
+	days := make([]string, len(c.Recurrence.Weekdays))
+	for i, day := range c.Recurrence.Weekdays {
+		days[i] = day.String()
+	}
+
+	return strings.Join(days, ", ")
+}
@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+)
+
+func TestRecurringAttendanceCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	today := util.BeginningOfDay(time.Now().UTC())
+	wednesday := today.AddDate(0, 0, 7+int(time.Wednesday-today.Weekday()))
+	wed := announceTestEvent(t, ctx, "wed", wednesday)
+	thu := announceTestEvent(t, ctx, "thu", wednesday.AddDate(0, 0, 1))
+	rec := util.Recurrence{Weekdays: []time.Weekday{time.Wednesday}}
+
+	err := RecurringAttendanceCommand{Recurrence: rec, List: events.Absent}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAttendance(t, ctx, wed, true, false)
+	assertAttendance(t, ctx, thu, false, false)
+	rules, err := events.GetAttendanceRulesForUser(ctx.Redis, ctx.Sender.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 1 || rules[0].Weekday != time.Wednesday || rules[0].List != events.Absent {
+		t.Errorf("expected a wednesday absence rule, got '%v'", rules)
+	}
+
+	expected := "Marked 'Tester' absent every Wednesday"
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+
+	err = RecurringAttendanceCommand{Recurrence: rec, List: events.Absent, Clear: true}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertAttendance(t, ctx, wed, false, false)
+	rules, err = events.GetAttendanceRulesForUser(ctx.Redis, ctx.Sender.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 0 {
+		t.Errorf("expected rules to be cleared, got '%v'", rules)
+	}
+}
+
+func TestRuleRange(t *testing.T) {
+	ctx, _, _ := newTestContext(t)
+	wednesday := time.Date(2021, 8, 25, 15, 0, 0, 0, time.UTC)
+	until := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		now    time.Time
+		until  time.Time
+		expEnd time.Time
+	}{
+		{"wednesday", wednesday, time.Time{}, util.EndOfDay(time.Date(2022, 8, 17, 0, 0, 0, 0, time.UTC))},
+		{"saturday", wednesday.AddDate(0, 0, 3), time.Time{}, util.EndOfDay(time.Date(2022, 8, 20, 0, 0, 0, 0, time.UTC))},
+		{"until", wednesday, until, util.EndOfDay(until)},
+		{"until after a year", wednesday, until.AddDate(2, 0, 0), util.EndOfDay(time.Date(2022, 8, 17, 0, 0, 0, 0, time.UTC))},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dates := ruleRange(util.Recurrence{Weekdays: []time.Weekday{time.Wednesday}, Until: c.until}, c.now)
+			if !dates.Begin.Equal(util.BeginningOfDay(c.now)) || !dates.End.Equal(c.expEnd) {
+				t.Errorf("expected '%v' to '%v' got '%v' to '%v'", util.BeginningOfDay(c.now), c.expEnd, dates.Begin, dates.End)
+			}
+
+			_, err := events.GetEventsForDateRange(ctx.Redis, dates)
+			if err != nil {
+				t.Errorf("expected the range to be accepted got '%v'", err)
+			}
+		})
+	}
+}
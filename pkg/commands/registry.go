@@ -0,0 +1,153 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+)
+
+var ErrDuplicateCommand = errors.New("command name or alias is already registered")
+
+// Spec describes a command so that it can be parsed and documented
+type Spec struct {
+	// Name is the word that invokes the command, without the prefix
+	Name    string
+	Aliases []string
+	// Usage describes the arguments accepted by the command, for example "[<date> to <date>]"
+	Usage       string
+	Description string
+	// Examples are complete invocations without the prefix, for example "out Dec 10 to Dec 30"
+	Examples []string
+	// Hidden commands are not listed by help
+	Hidden bool
+	// Parse builds the command from the arguments following the name
+	Parse func(args []string) (Command, error)
+}
+
+// Registry holds the commands known to the bot in the order they were registered
+type Registry struct {
+	specs  []Spec
+	byName map[string]int
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		byName: map[string]int{},
+	}
+}
+
+// Register adds a command to the registry. Names and aliases are case insensitive and must be unique.
+func (r *Registry) Register(spec Spec) error {
+	names := append([]string{spec.Name}, spec.Aliases...)
+	for _, name := range names {
+		if _, ok := r.byName[strings.ToLower(name)]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateCommand, name)
+		}
+	}
+
+	r.specs = append(r.specs, spec)
+	for _, name := range names {
+		r.byName[strings.ToLower(name)] = len(r.specs) - 1
+	}
+
+	return nil
+}
+
+// Lookup returns the command registered under the name or alias
+func (r *Registry) Lookup(name string) (Spec, bool) {
+	idx, ok := r.byName[strings.ToLower(name)]
+	if !ok {
+		return Spec{}, false
+	}
+
+	return r.specs[idx], true
+}
+
+// Specs returns every registered command in registration order
+func (r *Registry) Specs() []Spec {
+	return append([]Spec{}, r.specs...)
+}
+
+// NewDefaultRegistry returns a registry containing all of the bot's commands
+func NewDefaultRegistry() *Registry {
+	r := NewRegistry()
+	specs := []Spec{
+		HelpSpec(r),
+		PrefixSpec,
+		EventsSpec,
+		SetNameSpec,
+		ResetNameSpec,
+		CharSpec,
+		CharsSpec,
+		OutSpec,
+		InSpec,
+		LateSpec,
+		OnTimeSpec,
+		MaybeSpec,
+		AuditSpec,
+		EmbedSpec,
+		ScheduleSpec,
+		AnnounceSpec,
+	}
+
+	for _, spec := range specs {
+		err := r.Register(spec)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return r
+}
+
+// parseAttendance returns a parse function for commands that accept a date range or a weekly recurrence,
+// optionally preceded by a mention of the member to act for. Recurrences such as "every wednesday" are parsed
+// into a RecurringAttendanceCommand for the list.
+func parseAttendance(t events.UserListType, clear bool, build func(dates util.DateRange, target string) Command) func([]string) (Command, error) {
+	return func(args []string) (Command, error) {
+		target := ""
+		if len(args) > 0 {
+			if id, ok := ParseMention(args[0]); ok {
+				target = id
+				args = args[1:]
+			}
+		}
+
+		if len(args) > 0 && strings.ToLower(args[0]) == "every" {
+			rec, err := util.FlagsToRecurrence(args[1:])
+			var parseErr *util.ParseError
+			if errors.As(err, &parseErr) && parseErr.Suggestion != "" {
+				parseErr.Suggestion = "every " + parseErr.Suggestion
+				if target != "" {
+					parseErr.Suggestion = fmt.Sprintf("<@%s> %s", target, parseErr.Suggestion)
+				}
+			}
+			if err != nil {
+				return nil, fmt.Errorf("parse flags: %w", err)
+			}
+
+			return &RecurringAttendanceCommand{
+				Recurrence: rec,
+				List:       t,
+				Clear:      clear,
+				Target:     target,
+			}, nil
+		}
+
+		dates, err := util.FlagsToDateRange(args)
+		var parseErr *util.ParseError
+		if errors.As(err, &parseErr) && parseErr.Suggestion != "" && target != "" {
+			parseErr.Suggestion = fmt.Sprintf("<@%s> %s", target, parseErr.Suggestion)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse flags: %w", err)
+		}
+
+		return build(dates, target), nil
+	}
+}
+
+const attendanceUsage = "[@member] [<date> | <date> to <date> | every <weekday> [until <date>]]"
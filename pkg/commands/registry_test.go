@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	err := r.Register(Spec{Name: "out", Aliases: []string{"absent"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		spec   Spec
+		expErr error
+	}{
+		{"new command", Spec{Name: "late"}, nil},
+		{"duplicate name", Spec{Name: "OUT"}, ErrDuplicateCommand},
+		{"alias of existing name", Spec{Name: "away", Aliases: []string{"out"}}, ErrDuplicateCommand},
+		{"name of existing alias", Spec{Name: "absent"}, ErrDuplicateCommand},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := r.Register(c.spec)
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("expected '%v' got '%v'", c.expErr, err)
+			}
+		})
+	}
+
+	spec, ok := r.Lookup("Absent")
+	if !ok || spec.Name != "out" {
+		t.Errorf("expected alias to resolve to 'out' got '%v'", spec.Name)
+	}
+
+	if _, ok := r.Lookup("away"); ok {
+		t.Error("expected rejected command not to be registered")
+	}
+
+	if len(r.Specs()) != 2 {
+		t.Errorf("expected '2' commands got '%v'", len(r.Specs()))
+	}
+}
+
+func TestDefaultRegistry(t *testing.T) {
+	r := NewDefaultRegistry()
+	for _, spec := range r.Specs() {
+		if spec.Parse == nil {
+			t.Errorf("expected command '%s' to have a parser", spec.Name)
+		}
+	}
+}
@@ -3,10 +3,20 @@ package commands
 import (
 	"time"
 
+	"github.com/acastle/esperbot/pkg/audit"
 	"github.com/acastle/esperbot/pkg/events"
 	log "github.com/sirupsen/logrus"
 )
 
+var ScheduleSpec = Spec{
+	Name:        "schedule",
+	Description: "schedule the recurring events for the current week",
+	Hidden:      true,
+	Parse: func(args []string) (Command, error) {
+		return &ScheduleCommand{}, nil
+	},
+}
+
 type ScheduleCommand struct {
 }
 
@@ -14,6 +24,11 @@ func (c ScheduleCommand) Execute(ctx Context) error {
 	err := events.ScheduleEventsForWeek(ctx.Redis, time.Now())
 	if err != nil {
 		log.Error(err)
+		return nil
 	}
-	return nil
+
+	return ctx.record(audit.Entry{
+		Action: audit.EventSchedule,
+		Detail: "current week",
+	})
 }
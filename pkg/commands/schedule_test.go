@@ -0,0 +1,36 @@
+package commands
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+)
+
+func TestScheduleCommand(t *testing.T) {
+	ctx, _, _ := newTestContext(t)
+	err := events.UpsertRecurringEvent(ctx.Redis, events.RecurringEvent{
+		ID:       "raid",
+		Name:     "Raid",
+		Weekdays: []time.Weekday{time.Monday, time.Friday},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		err = ScheduleCommand{}.Execute(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evts, err := events.GetEventsForWeek(ctx.Redis, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evts) != 2 {
+		t.Errorf("expected 2 events for the week got %d", len(evts))
+	}
+}
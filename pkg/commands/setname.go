@@ -1,27 +1,141 @@
 package commands
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/acastle/esperbot/pkg/audit"
 	"github.com/acastle/esperbot/pkg/events"
 	log "github.com/sirupsen/logrus"
 )
 
+var SetNameSpec = Spec{
+	Name:        "setname",
+	Aliases:     []string{"name"},
+	Usage:       "[name]",
+	Description: "sets what name the bot will use for your discord user instead of your server nickname, or shows your current and previous names",
+	Examples:    []string{"setname RingRingRingBananaPhone", "setname"},
+	Parse: func(args []string) (Command, error) {
+		return &SetNameCommand{
+			Name: strings.Join(args, " "),
+		}, nil
+	},
+}
+
+var ResetNameSpec = Spec{
+	Name:        "resetname",
+	Description: "go back to using your server nickname",
+	Examples:    []string{"resetname"},
+	Parse: func(args []string) (Command, error) {
+		return &ResetNameCommand{}, nil
+	},
+}
+
+// SetNameCommand overrides the sender's nickname with Name, or shows their names when Name is empty
 type SetNameCommand struct {
 	Name string
 }
 
 func (c SetNameCommand) Execute(ctx Context) error {
+	if c.Name == "" {
+		return c.showNames(ctx)
+	}
+
 	log.WithField("id", ctx.Sender.ID).Info("set user alias")
 	err := events.SetUserAlias(ctx.Redis, ctx.Sender.ID, c.Name)
-	if err != nil {
+	if errors.Is(err, events.ErrInvalidAlias) {
+		return c.respond(ctx, fmt.Sprintf("I couldn't call you '%s', %s.", c.Name, err))
+	} else if err != nil {
 		return fmt.Errorf("set user name: %w", err)
 	}
 
-	_, err = ctx.Session.ChannelMessageSend(ctx.ChannelID, fmt.Sprintf("From this day forward we call you '%s'... I hope you are happy.", c.Name))
+	err = ctx.record(audit.Entry{
+		Target: ctx.Sender.ID,
+		Action: audit.AliasSet,
+		Detail: c.Name,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.respond(ctx, fmt.Sprintf("From this day forward we call you '%s'... I hope you are happy.", c.Name))
+}
+
+// showNames replies with the sender's current name and the names they have had before
+func (c SetNameCommand) showNames(ctx Context) error {
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, ctx.Sender.ID)
+	if err != nil {
+		return fmt.Errorf("fetch user alias: %w", err)
+	}
+
+	history, err := events.GetAliasHistory(ctx.Redis, ctx.Sender.ID)
+	if err != nil {
+		return fmt.Errorf("fetch alias history: %w", err)
+	}
+
+	previous := []string{}
+	for _, change := range history {
+		if change.Alias != "" && change.Alias != alias && !containsString(previous, change.Alias) {
+			previous = append(previous, change.Alias)
+		}
+	}
+
+	msg := fmt.Sprintf("We call you '%s'.", alias)
+	if len(previous) > 0 {
+		msg = msg + fmt.Sprintf(" You have also been known as %s.", strings.Join(previous, ", "))
+	}
+
+	return c.respond(ctx, msg)
+}
+
+func (c SetNameCommand) respond(ctx Context, msg string) error {
+	_, err := ctx.Messenger.SendMessage(ctx.ChannelID, msg)
+	if err != nil {
+		return fmt.Errorf("send response: %w", err)
+	}
+
+	return nil
+}
+
+// ResetNameCommand removes the sender's setname override so that their nickname is used again
+type ResetNameCommand struct {
+}
+
+func (c ResetNameCommand) Execute(ctx Context) error {
+	log.WithField("id", ctx.Sender.ID).Info("reset user alias")
+	err := events.ResetUserAlias(ctx.Redis, ctx.Sender.ID)
+	if err != nil {
+		return fmt.Errorf("reset user name: %w", err)
+	}
+
+	err = ctx.record(audit.Entry{
+		Target: ctx.Sender.ID,
+		Action: audit.AliasReset,
+	})
+	if err != nil {
+		return err
+	}
+
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, ctx.Sender.ID)
+	if err != nil {
+		return fmt.Errorf("fetch user alias: %w", err)
+	}
+
+	_, err = ctx.Messenger.SendMessage(ctx.ChannelID, fmt.Sprintf("Back to basics, we call you '%s' again.", alias))
 	if err != nil {
 		return fmt.Errorf("send response: %w", err)
 	}
 
 	return nil
 }
+
+func containsString(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+
+	return false
+}
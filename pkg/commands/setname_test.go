@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/acastle/esperbot/pkg/events"
+)
+
+func TestSetNameCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	err := SetNameCommand{Name: "Banana-Phone"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, ctx.Sender.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if alias != "Banana-Phone" {
+		t.Errorf("expected alias 'Banana-Phone' got '%s'", alias)
+	}
+
+	expected := "From this day forward we call you 'Banana-Phone'... I hope you are happy."
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+}
+
+func TestSetNameCommandInvalid(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	err := SetNameCommand{Name: "<@everyone>"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(fake.LastContent(), events.ErrInvalidAlias.Error()) {
+		t.Errorf("expected the name to be rejected got '%s'", fake.LastContent())
+	}
+
+	alias, err := events.GetUserAlias(ctx.Redis, ctx.Messenger, ctx.Settings.GuildID, ctx.Sender.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if alias != "Tester" {
+		t.Errorf("expected alias 'Tester' got '%s'", alias)
+	}
+}
+
+func TestResetNameCommand(t *testing.T) {
+	ctx, fake, _ := newTestContext(t)
+	err := SetNameCommand{Name: "Banana-Phone"}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ResetNameCommand{}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "Back to basics, we call you 'Tester' again."
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+
+	err = SetNameCommand{}.Execute(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = "We call you 'Tester'. You have also been known as Banana-Phone."
+	if fake.LastContent() != expected {
+		t.Errorf("expected response '%s' got '%s'", expected, fake.LastContent())
+	}
+}
@@ -0,0 +1,372 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+	"github.com/acastle/esperbot/pkg/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Config holds the settings for a running bot. It is loaded from an optional JSON file with individual
+// values overridden by environment variables, see Load.
+type Config struct {
+	Discord   DiscordConfig    `json:"discord"`
+	Redis     RedisConfig      `json:"redis"`
+	Log       LogConfig        `json:"log"`
+	Templates []TemplateConfig `json:"templates"`
+	Embed     EmbedConfig      `json:"embed"`
+	HTTP      HTTPConfig       `json:"http"`
+}
+
+// DiscordConfig identifies the bot and the guild it serves. The bot subscribes to the privileged guild members
+// intent to cache nicknames and track who is in the roster, so the Server Members Intent must be enabled for the
+// application under Bot > Privileged Gateway Intents in the Discord developer portal. Without it Discord closes
+// the connection when the bot identifies.
+type DiscordConfig struct {
+	Token string `json:"token"`
+	// GuildID is the guild whose member nicknames are shown and whose members are counted in compositions
+	GuildID string `json:"guild_id"`
+	// ChannelID is where announcements are posted
+	ChannelID string `json:"channel_id"`
+	// OfficerRoles lists the ids of roles allowed to change attendance on behalf of other members
+	OfficerRoles []string `json:"officer_roles"`
+}
+
+type RedisConfig struct {
+	Addr     string `json:"addr"`
+	Password string `json:"password"`
+	DB       int    `json:"db"`
+}
+
+type LogConfig struct {
+	Level  string `json:"level"`
+	Format string `json:"format"`
+}
+
+// HTTPConfig sets where the health and metrics server listens, the server is disabled when Addr is empty
+type HTTPConfig struct {
+	Addr string `json:"addr"`
+}
+
+// TemplateConfig describes a recurring event that is created at startup
+type TemplateConfig struct {
+	ID                 string   `json:"id"`
+	Name               string   `json:"name"`
+	Weekdays           []string `json:"weekdays"`
+	WeeksAhead         int      `json:"weeks_ahead"`
+	AnnounceDaysBefore int      `json:"announce_days_before"`
+	// Reactions map reactions to announcements to attendance lists, the defaults are used when it is empty
+	Reactions []ReactionConfig `json:"reactions"`
+}
+
+// ReactionConfig maps a reaction to an attendance list. Emoji is a unicode emoji or a custom guild emoji given
+// as "name:id" or "<:name:id>", List is the name of an attendance list such as "absent" or "late".
+type ReactionConfig struct {
+	Emoji string `json:"emoji"`
+	List  string `json:"list"`
+}
+
+// EmbedConfig sets the appearance of announcement embeds
+type EmbedConfig struct {
+	Title        string `json:"title"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	Color        int    `json:"color"`
+}
+
+// Default returns the configuration used when no file or environment overrides are provided
+func Default() Config {
+	return Config{
+		Redis: RedisConfig{
+			Addr: "localhost:6379",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "text",
+		},
+		Templates: []TemplateConfig{{
+			ID:                 "MainRaid",
+			Name:               "Main Raid",
+			Weekdays:           []string{"wednesday"},
+			WeeksAhead:         events.DefaultWeeksAhead,
+			AnnounceDaysBefore: events.DefaultAnnounceDaysBefore,
+		}},
+		Embed: EmbedConfig{
+			Title:        events.DefaultEmbedTheme.Title,
+			ThumbnailURL: events.DefaultEmbedTheme.ThumbnailURL,
+			Color:        events.DefaultEmbedTheme.Color,
+		},
+	}
+}
+
+// Load returns the default configuration overlaid with the JSON file at path, if path is not empty, and then
+// with any of the following environment variables that are set: BOT_TOKEN, GUILD_ID, CHANNEL_ID,
+// REDIS_ADDR, REDIS_PASSWORD, REDIS_DB, LOG_LEVEL, LOG_FORMAT and HTTP_ADDR. The result is validated before it is
+// returned.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return Config{}, fmt.Errorf("read config file: %w", err)
+		}
+
+		// Templates from the file replace the defaults rather than being merged into them
+		cfg.Templates = nil
+		err = json.Unmarshal(data, &cfg)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse config file: %w", err)
+		}
+
+		if cfg.Templates == nil {
+			cfg.Templates = Default().Templates
+		}
+	}
+
+	err := cfg.applyEnv(os.LookupEnv)
+	if err != nil {
+		return Config{}, err
+	}
+
+	err = cfg.Validate()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	strs := map[string]*string{
+		"BOT_TOKEN":      &c.Discord.Token,
+		"GUILD_ID":       &c.Discord.GuildID,
+		"CHANNEL_ID":     &c.Discord.ChannelID,
+		"REDIS_ADDR":     &c.Redis.Addr,
+		"REDIS_PASSWORD": &c.Redis.Password,
+		"LOG_LEVEL":      &c.Log.Level,
+		"LOG_FORMAT":     &c.Log.Format,
+		"HTTP_ADDR":      &c.HTTP.Addr,
+	}
+	for name, field := range strs {
+		if v, ok := lookup(name); ok {
+			*field = v
+		}
+	}
+
+	if v, ok := lookup("REDIS_DB"); ok {
+		db, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("parse REDIS_DB: %w", err)
+		}
+
+		c.Redis.DB = db
+	}
+
+	return nil
+}
+
+// ValidationError lists every problem found with a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid configuration: %s", strings.Join(e.Problems, "; "))
+}
+
+// Validate checks the configuration for missing or malformed values, returning a ValidationError that
+// describes all of them
+func (c Config) Validate() error {
+	problems := []string{}
+	if c.Discord.Token == "" {
+		problems = append(problems, "discord.token (BOT_TOKEN) is required")
+	}
+
+	if c.Discord.GuildID == "" {
+		problems = append(problems, "discord.guild_id (GUILD_ID) is required")
+	} else if !isSnowflake(c.Discord.GuildID) {
+		problems = append(problems, fmt.Sprintf("discord.guild_id '%s' must be a discord id", c.Discord.GuildID))
+	}
+
+	if c.Discord.ChannelID == "" {
+		problems = append(problems, "discord.channel_id (CHANNEL_ID) is required")
+	} else if !isSnowflake(c.Discord.ChannelID) {
+		problems = append(problems, fmt.Sprintf("discord.channel_id '%s' must be a discord id", c.Discord.ChannelID))
+	}
+
+	for _, role := range c.Discord.OfficerRoles {
+		if !isSnowflake(role) {
+			problems = append(problems, fmt.Sprintf("discord.officer_roles '%s' must be a discord id", role))
+		}
+	}
+
+	if c.Redis.Addr == "" {
+		problems = append(problems, "redis.addr (REDIS_ADDR) is required")
+	}
+
+	if c.Redis.DB < 0 {
+		problems = append(problems, "redis.db must not be negative")
+	}
+
+	if _, err := log.ParseLevel(c.Log.Level); err != nil {
+		problems = append(problems, fmt.Sprintf("log.level '%s' is not a valid level", c.Log.Level))
+	}
+
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		problems = append(problems, fmt.Sprintf("log.format '%s' must be 'text' or 'json'", c.Log.Format))
+	}
+
+	ids := map[string]bool{}
+	for i, t := range c.Templates {
+		prefix := fmt.Sprintf("templates[%d]", i)
+		if t.ID == "" {
+			problems = append(problems, prefix+".id is required")
+		} else if ids[t.ID] {
+			problems = append(problems, fmt.Sprintf("%s.id '%s' is used by another template", prefix, t.ID))
+		}
+		ids[t.ID] = true
+
+		if t.Name == "" {
+			problems = append(problems, prefix+".name is required")
+		}
+
+		if len(t.Weekdays) == 0 {
+			problems = append(problems, prefix+".weekdays must list at least one day")
+		}
+
+		for _, day := range t.Weekdays {
+			if _, err := util.ParseWeekday(day); err != nil {
+				problems = append(problems, fmt.Sprintf("%s.weekdays '%s' is not a weekday", prefix, day))
+			}
+		}
+
+		if t.WeeksAhead < 0 || t.AnnounceDaysBefore < 0 {
+			problems = append(problems, prefix+".weeks_ahead and announce_days_before must not be negative")
+		}
+
+		if _, err := t.reactionMappings(); err != nil {
+			problems = append(problems, fmt.Sprintf("%s.reactions %v", prefix, err))
+		}
+	}
+
+	if c.Embed.Color < 0 || c.Embed.Color > 0xFFFFFF {
+		problems = append(problems, "embed.color must be between 0 and 0xFFFFFF")
+	}
+
+	if c.Embed.ThumbnailURL != "" {
+		u, err := url.Parse(c.Embed.ThumbnailURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			problems = append(problems, fmt.Sprintf("embed.thumbnail_url '%s' must be an http(s) url", c.Embed.ThumbnailURL))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+func isSnowflake(id string) bool {
+	_, err := strconv.ParseUint(id, 10, 64)
+	return err == nil
+}
+
+var ErrInvalidTemplate = errors.New("invalid template")
+
+// RecurringEvents converts the configured templates to recurring events
+func (c Config) RecurringEvents() ([]events.RecurringEvent, error) {
+	evts := make([]events.RecurringEvent, len(c.Templates))
+	for i, t := range c.Templates {
+		days := make([]time.Weekday, len(t.Weekdays))
+		for j, name := range t.Weekdays {
+			day, err := util.ParseWeekday(name)
+			if err != nil {
+				return nil, fmt.Errorf("%w '%s': %v", ErrInvalidTemplate, t.ID, err)
+			}
+
+			days[j] = day
+		}
+
+		reactions, err := t.reactionMappings()
+		if err != nil {
+			return nil, fmt.Errorf("%w '%s': %v", ErrInvalidTemplate, t.ID, err)
+		}
+
+		evts[i] = events.RecurringEvent{
+			ID:                 t.ID,
+			Name:               t.Name,
+			Weekdays:           days,
+			WeeksAhead:         t.WeeksAhead,
+			AnnounceDaysBefore: t.AnnounceDaysBefore,
+			Reactions:          reactions,
+		}
+	}
+
+	return evts, nil
+}
+
+func (t TemplateConfig) reactionMappings() ([]events.ReactionMapping, error) {
+	if len(t.Reactions) == 0 {
+		return nil, nil
+	}
+
+	mappings := make([]events.ReactionMapping, len(t.Reactions))
+	for i, r := range t.Reactions {
+		emoji, err := events.ParseEmoji(r.Emoji)
+		if err != nil {
+			return nil, err
+		}
+
+		list, err := events.ParseUserListType(r.List)
+		if err != nil {
+			return nil, err
+		}
+
+		mappings[i] = events.ReactionMapping{Emoji: emoji, List: list}
+	}
+
+	err := events.ValidateReactions(mappings)
+	if err != nil {
+		return nil, err
+	}
+
+	return mappings, nil
+}
+
+// Theme returns the configured embed appearance
+func (c Config) Theme() events.EmbedTheme {
+	return events.EmbedTheme{
+		Title:        c.Embed.Title,
+		ThumbnailURL: c.Embed.ThumbnailURL,
+		Color:        c.Embed.Color,
+	}
+}
+
+// ConfigureLogger applies the configured log level and format to the standard logger
+func (c Config) ConfigureLogger() error {
+	level, err := log.ParseLevel(c.Log.Level)
+	if err != nil {
+		return fmt.Errorf("parse log level: %w", err)
+	}
+
+	log.SetLevel(level)
+	if c.Log.Format == "json" {
+		log.SetFormatter(&log.JSONFormatter{})
+	} else {
+		log.SetFormatter(&log.TextFormatter{
+			DisableColors: true,
+			FullTimestamp: true,
+		})
+	}
+
+	return nil
+}
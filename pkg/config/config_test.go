@@ -0,0 +1,123 @@
+package config
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/events"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esperbot")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.json")
+	err = ioutil.WriteFile(path, []byte(`{
+		"discord": {"token": "from-file", "guild_id": "456", "channel_id": "123"},
+		"redis": {"addr": "redis:6379", "db": 2},
+		"templates": [{"id": "Alt", "name": "Alt Raid", "weekdays": ["mon", "thursday"], "weeks_ahead": 4}]
+	}`), 0600)
+	if err != nil {
+		panic(err)
+	}
+
+	os.Setenv("BOT_TOKEN", "from-env")
+	os.Setenv("REDIS_DB", "3")
+	defer os.Unsetenv("BOT_TOKEN")
+	defer os.Unsetenv("REDIS_DB")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Discord.Token != "from-env" {
+		t.Errorf("expected env to override token, got '%s'", cfg.Discord.Token)
+	}
+
+	if cfg.Discord.GuildID != "456" || cfg.Discord.ChannelID != "123" || cfg.Redis.Addr != "redis:6379" || cfg.Redis.DB != 3 {
+		t.Errorf("file values were not loaded, got '%v'", cfg)
+	}
+
+	if cfg.Redis.Password != "" || cfg.Log.Level != "info" {
+		t.Errorf("defaults were not kept for unset values, got '%v'", cfg)
+	}
+
+	templates, err := cfg.RecurringEvents()
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []events.RecurringEvent{{
+		ID:         "Alt",
+		Name:       "Alt Raid",
+		Weekdays:   []time.Weekday{time.Monday, time.Thursday},
+		WeeksAhead: 4,
+	}}
+	if !reflect.DeepEqual(expected, templates) {
+		t.Errorf("expected '%v' got '%v'", expected, templates)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := Default()
+	valid.Discord.Token = "token"
+	valid.Discord.GuildID = "456"
+	valid.Discord.ChannelID = "123"
+
+	cases := []struct {
+		name        string
+		modify      func(*Config)
+		expProblems int
+	}{
+		{"default with required values", func(c *Config) {}, 0},
+		{"missing token", func(c *Config) { c.Discord.Token = "" }, 1},
+		{"missing ids", func(c *Config) { c.Discord.GuildID = ""; c.Discord.ChannelID = "" }, 2},
+		{"invalid ids", func(c *Config) { c.Discord.GuildID = "abc"; c.Discord.ChannelID = "" }, 2},
+		{"invalid officer role", func(c *Config) { c.Discord.OfficerRoles = []string{"123", "officers"} }, 1},
+		{"invalid redis", func(c *Config) { c.Redis.Addr = ""; c.Redis.DB = -1 }, 2},
+		{"invalid log", func(c *Config) { c.Log.Level = "loud"; c.Log.Format = "xml" }, 2},
+		{"invalid template", func(c *Config) {
+			c.Templates = append(c.Templates, TemplateConfig{ID: "MainRaid", Weekdays: []string{"someday"}})
+		}, 3},
+		{"custom reactions", func(c *Config) {
+			c.Templates[0].Reactions = []ReactionConfig{{Emoji: "<:tentative:123456>", List: "late"}, {Emoji: "❌", List: "out"}}
+		}, 0},
+		{"invalid reactions", func(c *Config) {
+			c.Templates[0].Reactions = []ReactionConfig{{Emoji: "❌", List: "absent"}, {Emoji: "❌", List: "late"}}
+		}, 1},
+		{"invalid embed", func(c *Config) { c.Embed.Color = 0x1000000; c.Embed.ThumbnailURL = "ftp://x" }, 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := valid
+			cfg.Templates = append([]TemplateConfig{}, valid.Templates...)
+			c.modify(&cfg)
+			err := cfg.Validate()
+			if c.expProblems == 0 {
+				if err != nil {
+					t.Errorf("expected no error got '%v'", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("expected ValidationError got '%v'", err)
+			}
+
+			if len(validationErr.Problems) != c.expProblems {
+				t.Errorf("expected %d problems got '%v'", c.expProblems, validationErr.Problems)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package discord
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// The version of discordgo in use predates message components and interactions, so they are sent and received
+// as raw JSON against a newer version of the API than the rest of the session uses.
+const componentsAPI = "https://discord.com/api/v9/"
+
+// InteractionCreateEvent is the type of the raw gateway event sent when a member presses a button
+const InteractionCreateEvent = "INTERACTION_CREATE"
+
+const (
+	ComponentActionRow = 1
+	ComponentButton    = 2
+)
+
+const (
+	ButtonPrimary   = 1
+	ButtonSecondary = 2
+	ButtonSuccess   = 3
+	ButtonDanger    = 4
+)
+
+// InteractionMessageComponent is the type of interactions created by pressing a button
+const InteractionMessageComponent = 3
+
+const (
+	interactionResponseMessage = 4
+	messageFlagEphemeral       = 64
+)
+
+// Component is a message component, either a row or a button within a row
+type Component struct {
+	Type       int             `json:"type"`
+	Style      int             `json:"style,omitempty"`
+	Label      string          `json:"label,omitempty"`
+	Emoji      *ComponentEmoji `json:"emoji,omitempty"`
+	CustomID   string          `json:"custom_id,omitempty"`
+	Components []Component     `json:"components,omitempty"`
+}
+
+type ComponentEmoji struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+}
+
+// ActionRow returns a row holding the buttons
+func ActionRow(buttons ...Component) Component {
+	return Component{
+		Type:       ComponentActionRow,
+		Components: buttons,
+	}
+}
+
+// Button returns a button that sends an interaction with the custom id when pressed. The emoji is optional and is
+// either a unicode emoji or a custom guild emoji in the form "name:id".
+func Button(style int, label string, emoji string, customID string) Component {
+	b := Component{
+		Type:     ComponentButton,
+		Style:    style,
+		Label:    label,
+		CustomID: customID,
+	}
+
+	if emoji != "" {
+		parts := strings.SplitN(emoji, ":", 2)
+		b.Emoji = &ComponentEmoji{Name: parts[0]}
+		if len(parts) == 2 {
+			b.Emoji.ID = parts[1]
+		}
+	}
+
+	return b
+}
+
+// Interaction is sent when a member presses a button on one of the bot's messages
+type Interaction struct {
+	ID      string             `json:"id"`
+	Type    int                `json:"type"`
+	Token   string             `json:"token"`
+	GuildID string             `json:"guild_id"`
+	Member  *discordgo.Member  `json:"member"`
+	User    *discordgo.User    `json:"user"`
+	Message *discordgo.Message `json:"message"`
+	Data    struct {
+		CustomID      string `json:"custom_id"`
+		ComponentType int    `json:"component_type"`
+	} `json:"data"`
+}
+
+// ParseInteraction decodes the data of a raw INTERACTION_CREATE event
+func ParseInteraction(data []byte) (Interaction, error) {
+	i := Interaction{}
+	err := json.Unmarshal(data, &i)
+	if err != nil {
+		return Interaction{}, fmt.Errorf("decode interaction: %w", err)
+	}
+
+	return i, nil
+}
+
+// UserID returns the id of the member who pressed the button, interactions in guilds carry a member and those
+// in direct messages a user
+func (i Interaction) UserID() string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+
+	if i.User != nil {
+		return i.User.ID
+	}
+
+	return ""
+}
+
+type messageWithComponents struct {
+	Embed      *discordgo.MessageEmbed `json:"embed,omitempty"`
+	Components []Component             `json:"components"`
+}
+
+type interactionResponse struct {
+	Type int                     `json:"type"`
+	Data interactionResponseData `json:"data"`
+}
+
+type interactionResponseData struct {
+	Content string `json:"content"`
+	Flags   int    `json:"flags,omitempty"`
+}
+
+func (s *Session) SendEmbedWithComponents(channelID string, embed *discordgo.MessageEmbed, components []Component) (*discordgo.Message, error) {
+	endpoint := componentsAPI + "channels/" + channelID + "/messages"
+	return s.requestMessage("POST", endpoint, discordgo.EndpointChannelMessages(channelID), messageWithComponents{
+		Embed:      embed,
+		Components: components,
+	})
+}
+
+func (s *Session) EditEmbedWithComponents(channelID string, messageID string, embed *discordgo.MessageEmbed, components []Component) (*discordgo.Message, error) {
+	endpoint := componentsAPI + "channels/" + channelID + "/messages/" + messageID
+	return s.requestMessage("PATCH", endpoint, discordgo.EndpointChannelMessage(channelID, ""), messageWithComponents{
+		Embed:      embed,
+		Components: components,
+	})
+}
+
+func (s *Session) requestMessage(method string, endpoint string, bucket string, data interface{}) (*discordgo.Message, error) {
+	response, err := s.session.RequestWithBucketID(method, endpoint, data, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &discordgo.Message{}
+	err = json.Unmarshal(response, msg)
+	if err != nil {
+		return nil, fmt.Errorf("decode message: %w", err)
+	}
+
+	return msg, nil
+}
+
+// RespondToInteraction replies to the member who pressed a button, ephemeral replies are only shown to them
+func (s *Session) RespondToInteraction(interaction Interaction, content string, ephemeral bool) error {
+	response := interactionResponse{
+		Type: interactionResponseMessage,
+		Data: interactionResponseData{Content: content},
+	}
+	if ephemeral {
+		response.Data.Flags = messageFlagEphemeral
+	}
+
+	endpoint := componentsAPI + "interactions/" + interaction.ID + "/" + interaction.Token + "/callback"
+	_, err := s.session.RequestWithBucketID("POST", endpoint, response, componentsAPI+"interactions/")
+	return err
+}
@@ -0,0 +1,366 @@
+package discord
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+var ErrUnknownMessage = errors.New("unknown message")
+var ErrUnknownUser = errors.New("unknown user")
+var ErrUnknownMember = errors.New("unknown member")
+
+// Reaction is a reaction added or removed through a Fake
+type Reaction struct {
+	ChannelID string
+	MessageID string
+	Emoji     string
+	UserID    string
+	Removed   bool
+}
+
+// InteractionResponse is a response to an interaction made through a Fake
+type InteractionResponse struct {
+	InteractionID string
+	Content       string
+	Ephemeral     bool
+}
+
+// Fake is an in-memory Messenger that records every call made to it
+type Fake struct {
+	mu     sync.Mutex
+	nextID int
+
+	// Messages holds the current state of every message sent, keyed by message ID
+	Messages map[string]*discordgo.Message
+	// Sent lists messages in the order they were sent
+	Sent []*discordgo.Message
+	// Edits lists the embeds of every edit in the order they were made
+	Edits     []*discordgo.MessageEmbed
+	Reactions []Reaction
+	// Reactors holds the users who reacted to each message with each emoji, see AddUserReaction
+	Reactors map[string][]string
+	// Components holds the components of each message, keyed by message ID
+	Components map[string][]Component
+	// Pinned holds the ids of the pinned messages
+	Pinned map[string]bool
+	// Responses lists the responses to interactions in the order they were made
+	Responses []InteractionResponse
+	Users     map[string]*discordgo.User
+	Members   map[string]*discordgo.Member
+	// Permissions holds the permission bits of each user ID, the same bits apply in every channel
+	Permissions map[string]int
+
+	// Err is returned from every call when set
+	Err error
+}
+
+func NewFake() *Fake {
+	return &Fake{
+		Messages:    map[string]*discordgo.Message{},
+		Components:  map[string][]Component{},
+		Reactors:    map[string][]string{},
+		Pinned:      map[string]bool{},
+		Users:       map[string]*discordgo.User{},
+		Members:     map[string]*discordgo.Member{},
+		Permissions: map[string]int{},
+	}
+}
+
+// AddUser registers a user so that it can be looked up
+func (f *Fake) AddUser(user *discordgo.User) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Users[user.ID] = user
+}
+
+// AddMember registers a guild member, along with its user, so that it can be looked up
+func (f *Fake) AddMember(member *discordgo.Member) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Members[memberKey(member.GuildID, member.User.ID)] = member
+	f.Users[member.User.ID] = member.User
+}
+
+func memberKey(guildID string, userID string) string {
+	return fmt.Sprintf("%s:%s", guildID, userID)
+}
+
+func (f *Fake) send(msg *discordgo.Message) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	f.nextID++
+	msg.ID = fmt.Sprintf("message-%d", f.nextID)
+	f.Messages[msg.ID] = msg
+	f.Sent = append(f.Sent, msg)
+	return msg, nil
+}
+
+func (f *Fake) SendMessage(channelID string, content string) (*discordgo.Message, error) {
+	return f.send(&discordgo.Message{
+		ChannelID: channelID,
+		Content:   content,
+	})
+}
+
+// SendDirectMessage sends the message to the channel returned by DirectChannelID
+func (f *Fake) SendDirectMessage(userID string, content string) (*discordgo.Message, error) {
+	return f.SendMessage(DirectChannelID(userID), content)
+}
+
+// DirectChannelID is the id of the channel that the Fake sends direct messages to the user in
+func DirectChannelID(userID string) string {
+	return fmt.Sprintf("dm:%s", userID)
+}
+
+func (f *Fake) SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return f.send(&discordgo.Message{
+		ChannelID: channelID,
+		Embeds:    []*discordgo.MessageEmbed{embed},
+	})
+}
+
+func (f *Fake) EditEmbed(channelID string, messageID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	msg, ok := f.Messages[messageID]
+	if !ok || msg.ChannelID != channelID {
+		return nil, ErrUnknownMessage
+	}
+
+	msg.Embeds = []*discordgo.MessageEmbed{embed}
+	f.Edits = append(f.Edits, embed)
+	return msg, nil
+}
+
+// DeleteMessage removes a message as if it were deleted by a member, later edits fail with ErrUnknownMessage
+func (f *Fake) DeleteMessage(messageID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.Messages, messageID)
+	delete(f.Components, messageID)
+	delete(f.Pinned, messageID)
+}
+
+func (f *Fake) SendEmbedWithComponents(channelID string, embed *discordgo.MessageEmbed, components []Component) (*discordgo.Message, error) {
+	msg, err := f.SendEmbed(channelID, embed)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Components[msg.ID] = components
+	return msg, nil
+}
+
+func (f *Fake) EditEmbedWithComponents(channelID string, messageID string, embed *discordgo.MessageEmbed, components []Component) (*discordgo.Message, error) {
+	msg, err := f.EditEmbed(channelID, messageID, embed)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Components[msg.ID] = components
+	return msg, nil
+}
+
+func (f *Fake) RespondToInteraction(interaction Interaction, content string, ephemeral bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+
+	f.Responses = append(f.Responses, InteractionResponse{
+		InteractionID: interaction.ID,
+		Content:       content,
+		Ephemeral:     ephemeral,
+	})
+	return nil
+}
+
+func (f *Fake) AddReaction(channelID string, messageID string, emoji string) error {
+	return f.react(Reaction{
+		ChannelID: channelID,
+		MessageID: messageID,
+		Emoji:     emoji,
+	})
+}
+
+func (f *Fake) RemoveReaction(channelID string, messageID string, emoji string, userID string) error {
+	return f.react(Reaction{
+		ChannelID: channelID,
+		MessageID: messageID,
+		Emoji:     emoji,
+		UserID:    userID,
+		Removed:   true,
+	})
+}
+
+func (f *Fake) react(r Reaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+
+	msg, ok := f.Messages[r.MessageID]
+	if !ok {
+		return ErrUnknownMessage
+	}
+
+	f.Reactions = append(f.Reactions, r)
+	if r.Removed {
+		return nil
+	}
+
+	// Reactions added through the messenger are made by the bot
+	for _, existing := range msg.Reactions {
+		if existing.Emoji.APIName() == r.Emoji {
+			if !existing.Me {
+				existing.Me = true
+				existing.Count++
+			}
+			return nil
+		}
+	}
+
+	msg.Reactions = append(msg.Reactions, &discordgo.MessageReactions{
+		Count: 1,
+		Me:    true,
+		Emoji: &discordgo.Emoji{Name: r.Emoji},
+	})
+	return nil
+}
+
+func reactorKey(messageID string, emoji string) string {
+	return fmt.Sprintf("%s:%s", messageID, emoji)
+}
+
+// AddUserReaction records a reaction made by a member, without delivering an event for it, as happens when
+// the bot is not connected
+func (f *Fake) AddUserReaction(messageID string, emoji string, userID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := reactorKey(messageID, emoji)
+	f.Reactors[key] = append(f.Reactors[key], userID)
+}
+
+// RemoveUserReaction removes a reaction made by a member, without delivering an event for it
+func (f *Fake) RemoveUserReaction(messageID string, emoji string, userID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := reactorKey(messageID, emoji)
+	for i, id := range f.Reactors[key] {
+		if id == userID {
+			f.Reactors[key] = append(f.Reactors[key][:i], f.Reactors[key][i+1:]...)
+			return
+		}
+	}
+}
+
+func (f *Fake) ReactionUsers(channelID string, messageID string, emoji string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	msg, ok := f.Messages[messageID]
+	if !ok || msg.ChannelID != channelID {
+		return nil, ErrUnknownMessage
+	}
+
+	return append([]string{}, f.Reactors[reactorKey(messageID, emoji)]...), nil
+}
+
+func (f *Fake) PinMessage(channelID string, messageID string) error {
+	return f.pin(channelID, messageID, true)
+}
+
+func (f *Fake) UnpinMessage(channelID string, messageID string) error {
+	return f.pin(channelID, messageID, false)
+}
+
+func (f *Fake) pin(channelID string, messageID string, pinned bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return f.Err
+	}
+
+	msg, ok := f.Messages[messageID]
+	if !ok || msg.ChannelID != channelID {
+		return ErrUnknownMessage
+	}
+
+	if pinned {
+		f.Pinned[messageID] = true
+	} else {
+		delete(f.Pinned, messageID)
+	}
+	return nil
+}
+
+func (f *Fake) User(userID string) (*discordgo.User, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	user, ok := f.Users[userID]
+	if !ok {
+		return nil, ErrUnknownUser
+	}
+
+	return user, nil
+}
+
+func (f *Fake) Member(guildID string, userID string) (*discordgo.Member, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return nil, f.Err
+	}
+
+	member, ok := f.Members[memberKey(guildID, userID)]
+	if !ok {
+		return nil, ErrUnknownMember
+	}
+
+	return member, nil
+}
+
+func (f *Fake) UserChannelPermissions(userID string, channelID string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.Err != nil {
+		return 0, f.Err
+	}
+
+	return f.Permissions[userID], nil
+}
+
+// LastContent returns the content of the most recently sent message, or an empty string if none were sent
+func (f *Fake) LastContent() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.Sent) == 0 {
+		return ""
+	}
+
+	return f.Sent[len(f.Sent)-1].Content
+}
@@ -0,0 +1,140 @@
+package discord
+
+import (
+	"errors"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Messenger is the subset of the Discord API used by commands and announcements. It allows them to be
+// exercised against a Fake in tests.
+type Messenger interface {
+	SendMessage(channelID string, content string) (*discordgo.Message, error)
+	SendDirectMessage(userID string, content string) (*discordgo.Message, error)
+	SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	EditEmbed(channelID string, messageID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error)
+	SendEmbedWithComponents(channelID string, embed *discordgo.MessageEmbed, components []Component) (*discordgo.Message, error)
+	EditEmbedWithComponents(channelID string, messageID string, embed *discordgo.MessageEmbed, components []Component) (*discordgo.Message, error)
+	RespondToInteraction(interaction Interaction, content string, ephemeral bool) error
+	AddReaction(channelID string, messageID string, emoji string) error
+	RemoveReaction(channelID string, messageID string, emoji string, userID string) error
+	ReactionUsers(channelID string, messageID string, emoji string) ([]string, error)
+	PinMessage(channelID string, messageID string) error
+	UnpinMessage(channelID string, messageID string) error
+	User(userID string) (*discordgo.User, error)
+	Member(guildID string, userID string) (*discordgo.Member, error)
+	UserChannelPermissions(userID string, channelID string) (int, error)
+}
+
+// IsUnknownMessage reports whether the error is Discord's, or the Fake's, response to a request for a message
+// that does not exist, usually because it was deleted
+func IsUnknownMessage(err error) bool {
+	if errors.Is(err, ErrUnknownMessage) {
+		return true
+	}
+
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Message != nil && restErr.Message.Code == discordgo.ErrCodeUnknownMessage
+}
+
+// Session implements Messenger over a discordgo session
+type Session struct {
+	session *discordgo.Session
+}
+
+func NewSession(session *discordgo.Session) *Session {
+	return &Session{
+		session: session,
+	}
+}
+
+func (s *Session) SendMessage(channelID string, content string) (*discordgo.Message, error) {
+	return s.session.ChannelMessageSend(channelID, content)
+}
+
+// SendDirectMessage sends a message in the private channel with the user, creating the channel if needed
+func (s *Session) SendDirectMessage(userID string, content string) (*discordgo.Message, error) {
+	channel, err := s.session.UserChannelCreate(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.session.ChannelMessageSend(channel.ID, content)
+}
+
+func (s *Session) SendEmbed(channelID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.session.ChannelMessageSendEmbed(channelID, embed)
+}
+
+func (s *Session) EditEmbed(channelID string, messageID string, embed *discordgo.MessageEmbed) (*discordgo.Message, error) {
+	return s.session.ChannelMessageEditEmbed(channelID, messageID, embed)
+}
+
+func (s *Session) AddReaction(channelID string, messageID string, emoji string) error {
+	return s.session.MessageReactionAdd(channelID, messageID, emoji)
+}
+
+func (s *Session) RemoveReaction(channelID string, messageID string, emoji string, userID string) error {
+	return s.session.MessageReactionRemove(channelID, messageID, emoji, userID)
+}
+
+// reactionPageSize is the most users Discord returns for a reaction in one request
+const reactionPageSize = 100
+
+// ReactionUsers returns the ids of every user who reacted to the message with the emoji, including the bot
+func (s *Session) ReactionUsers(channelID string, messageID string, emoji string) ([]string, error) {
+	ids := []string{}
+	after := ""
+	for {
+		users, err := s.session.MessageReactions(channelID, messageID, emoji, reactionPageSize, "", after)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range users {
+			ids = append(ids, user.ID)
+		}
+
+		if len(users) < reactionPageSize {
+			return ids, nil
+		}
+		after = users[len(users)-1].ID
+	}
+}
+
+func (s *Session) PinMessage(channelID string, messageID string) error {
+	return s.session.ChannelMessagePin(channelID, messageID)
+}
+
+func (s *Session) UnpinMessage(channelID string, messageID string) error {
+	return s.session.ChannelMessageUnpin(channelID, messageID)
+}
+
+func (s *Session) User(userID string) (*discordgo.User, error) {
+	return s.session.User(userID)
+}
+
+// Member returns the guild member from the session state when available, falling back to the API
+func (s *Session) Member(guildID string, userID string) (*discordgo.Member, error) {
+	if s.session.State != nil {
+		member, err := s.session.State.Member(guildID, userID)
+		if err == nil {
+			return member, nil
+		}
+	}
+
+	return s.session.GuildMember(guildID, userID)
+}
+
+// UserChannelPermissions returns the permission bits a user has in a channel, computed from the session state
+// when available and falling back to the API
+func (s *Session) UserChannelPermissions(userID string, channelID string) (int, error) {
+	if s.session.State != nil {
+		perms, err := s.session.State.UserChannelPermissions(userID, channelID)
+		if err == nil {
+			return perms, nil
+		}
+	}
+
+	return s.session.UserChannelPermissions(userID, channelID)
+}
@@ -0,0 +1,396 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+)
+
+// NicknameTTL is how long the name of a user who is not a member of the configured guild is cached before it is looked
+// up again. The nicknames of guild members are kept until Discord reports a change.
+const NicknameTTL = 24 * time.Hour
+
+// MaxAliasLength matches the longest nickname Discord allows
+const MaxAliasLength = 32
+
+// AliasHistoryLength is the number of previous aliases kept for each user
+const AliasHistoryLength = 20
+
+var ErrInvalidAlias = errors.New("names must be 1 to 32 letters, digits, spaces or the characters - _ . '")
+
+// AliasSource describes where an alias came from
+type AliasSource string
+
+const (
+	AliasOverride AliasSource = "setname"
+	AliasReset    AliasSource = "resetname"
+	AliasNickname AliasSource = "nickname"
+)
+
+// AliasChange is an entry in a user's alias history
+type AliasChange struct {
+	Time   time.Time
+	Source AliasSource
+	Alias  string
+}
+
+// UserAliasKey holds the alias a user chose with setname, it takes precedence over their nickname
+func UserAliasKey(userID string) string {
+	return fmt.Sprintf("alias:override:%s", userID)
+}
+
+// LegacyAliasKey held both the aliases chosen with setname and the usernames cached when showing a user, they
+// are moved or removed by MigrateLegacyAliases
+func LegacyAliasKey(userID string) string {
+	return fmt.Sprintf("alias:%s", userID)
+}
+
+// UserNicknameKey caches the guild nickname, or username, of a user. It is primed from the guild's members
+// when the bot connects and refreshed when Discord reports a member update.
+func UserNicknameKey(userID string) string {
+	return fmt.Sprintf("nickname:%s", userID)
+}
+
+// AliasHistoryKey holds the previous aliases of a user, newest first
+func AliasHistoryKey(userID string) string {
+	return fmt.Sprintf("alias:history:%s", userID)
+}
+
+// ValidateAlias rejects names that are too long, start or end with a space or contain characters that Discord
+// would format
+func ValidateAlias(alias string) error {
+	if alias == "" || len([]rune(alias)) > MaxAliasLength || strings.TrimSpace(alias) != alias {
+		return ErrInvalidAlias
+	}
+
+	for _, r := range alias {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(" -_.'", r) {
+			continue
+		}
+
+		return ErrInvalidAlias
+	}
+
+	return nil
+}
+
+// SetUserAlias sets the alias a user chose, overriding their nickname
+func SetUserAlias(r *redis.Client, userID string, alias string) error {
+	err := ValidateAlias(alias)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Set(UserAliasKey(userID), alias, 0)
+		pushAliasHistory(pipe, userID, AliasOverride, alias)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis write: %w", err)
+	}
+
+	return nil
+}
+
+// ResetUserAlias removes the alias a user chose so that their nickname is used again
+func ResetUserAlias(r *redis.Client, userID string) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(UserAliasKey(userID))
+		pushAliasHistory(pipe, userID, AliasReset, "")
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis write: %w", err)
+	}
+
+	return nil
+}
+
+// GetUserAlias returns the name to show for a user: the alias they chose with setname, otherwise their
+// nickname in the guild and finally their username. Nicknames are not looked up when guildID is empty.
+func GetUserAlias(r *redis.Client, messenger discord.Messenger, guildID string, userID string) (string, error) {
+	aliases, err := GetUserAliases(r, messenger, guildID, []string{userID})
+	if err != nil {
+		return "", err
+	}
+
+	return aliases[userID], nil
+}
+
+// GetUserAliases returns the names to show for several users keyed by user id, see GetUserAlias. Stored
+// aliases and nicknames are read in a single round trip, Discord is only queried for users whose nickname has
+// not been cached.
+func GetUserAliases(r *redis.Client, messenger discord.Messenger, guildID string, userIDs []string) (map[string]string, error) {
+	aliases := map[string]string{}
+	if len(userIDs) == 0 {
+		return aliases, nil
+	}
+
+	keys := make([]string, 0, len(userIDs)*2)
+	for _, id := range userIDs {
+		keys = append(keys, UserAliasKey(id), UserNicknameKey(id))
+	}
+
+	values, err := r.MGet(keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get user aliases: %w", err)
+	}
+
+	members := []*discordgo.Member{}
+	strangers := []*discordgo.Member{}
+	for i, id := range userIDs {
+		if alias, ok := values[i*2].(string); ok {
+			aliases[id] = alias
+		} else if nickname, ok := values[i*2+1].(string); ok {
+			aliases[id] = nickname
+		} else if _, ok := aliases[id]; !ok {
+			member, inGuild, err := lookupMember(messenger, guildID, id)
+			if err != nil {
+				return nil, err
+			}
+
+			aliases[id] = DisplayName(member)
+			if inGuild {
+				members = append(members, member)
+			} else {
+				strangers = append(strangers, member)
+			}
+		}
+	}
+
+	_, err = cacheNicknames(r, members, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = cacheNicknames(r, strangers, NicknameTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	return aliases, nil
+}
+
+// lookupMember returns the user as a member of the guild, or a member holding only the user when they are
+// not in the guild. inGuild is false in that case.
+func lookupMember(messenger discord.Messenger, guildID string, userID string) (member *discordgo.Member, inGuild bool, err error) {
+	if guildID != "" {
+		member, err := messenger.Member(guildID, userID)
+		if err == nil {
+			return member, true, nil
+		}
+	}
+
+	user, err := messenger.User(userID)
+	if err != nil {
+		return nil, false, fmt.Errorf("query user from discord: %w", err)
+	}
+
+	return &discordgo.Member{User: user}, false, nil
+}
+
+// DisplayName returns the member's guild nickname, or their username when they have no nickname
+func DisplayName(member *discordgo.Member) string {
+	if member.Nick != "" {
+		return member.Nick
+	}
+
+	return member.User.Username
+}
+
+// RefreshMemberNickname caches the member's display name, recording it in their alias history when it has
+// changed. It returns true when the name changed.
+func RefreshMemberNickname(r *redis.Client, member *discordgo.Member) (bool, error) {
+	changed, err := RefreshMemberNicknames(r, []*discordgo.Member{member})
+	if err != nil {
+		return false, err
+	}
+
+	return len(changed) > 0, nil
+}
+
+// RefreshMemberNicknames caches the display names of several guild members in two round trips, recording
+// changed names in their alias history. It returns the ids of the members whose names changed.
+func RefreshMemberNicknames(r *redis.Client, members []*discordgo.Member) ([]string, error) {
+	return cacheNicknames(r, members, 0)
+}
+
+// cacheNicknames caches the display names for the ttl, or until they change when it is zero
+func cacheNicknames(r *redis.Client, members []*discordgo.Member, ttl time.Duration) ([]string, error) {
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, len(members))
+	for i, member := range members {
+		keys[i] = UserNicknameKey(member.User.ID)
+	}
+
+	previous, err := r.MGet(keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get cached nicknames: %w", err)
+	}
+
+	changed := []string{}
+	_, err = r.TxPipelined(func(pipe redis.Pipeliner) error {
+		for i, member := range members {
+			name := DisplayName(member)
+			if cached, ok := previous[i].(string); ok && cached == name {
+				if ttl > 0 {
+					pipe.Expire(keys[i], ttl)
+				} else {
+					pipe.Persist(keys[i])
+				}
+				continue
+			}
+
+			pipe.Set(keys[i], name, ttl)
+			pushAliasHistory(pipe, member.User.ID, AliasNickname, name)
+			changed = append(changed, member.User.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cache nicknames: %w", err)
+	}
+
+	return changed, nil
+}
+
+// MigrateLegacyAliases moves the aliases users chose with setname from LegacyAliasKey to UserAliasKey. Every
+// legacy key is migrated, including those of users who are no longer in the guild. Legacy values equal to the
+// user's username were cached rather than chosen and are removed so that the nickname is used instead. Usernames
+// are taken from members when listed there and looked up on Discord otherwise, the values of users Discord no
+// longer knows are kept as their alias. It returns the number of legacy aliases moved or removed.
+func MigrateLegacyAliases(r *redis.Client, messenger discord.Messenger, members []*discordgo.Member) (int, error) {
+	keys, err := legacyAliasKeys(r)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	legacy, err := r.MGet(keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("get legacy aliases: %w", err)
+	}
+
+	usernames := map[string]string{}
+	for _, member := range members {
+		if member.User != nil {
+			usernames[member.User.ID] = member.User.Username
+		}
+	}
+
+	chosen := map[string]string{}
+	for i, key := range keys {
+		alias, ok := legacy[i].(string)
+		if !ok {
+			continue
+		}
+
+		userID := strings.TrimPrefix(key, LegacyAliasKey(""))
+		username, ok := usernames[userID]
+		if !ok {
+			user, err := messenger.User(userID)
+			if err == nil {
+				username = user.Username
+			}
+		}
+
+		if alias != username {
+			chosen[userID] = alias
+		}
+	}
+
+	migrated := 0
+	_, err = r.TxPipelined(func(pipe redis.Pipeliner) error {
+		for i, key := range keys {
+			if _, ok := legacy[i].(string); !ok {
+				continue
+			}
+
+			userID := strings.TrimPrefix(key, LegacyAliasKey(""))
+			if alias, ok := chosen[userID]; ok {
+				pipe.SetNX(UserAliasKey(userID), alias, 0)
+			}
+			pipe.Del(key)
+			migrated++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("migrate legacy aliases: %w", err)
+	}
+
+	return migrated, nil
+}
+
+// legacyAliasKeys scans for the keys written with LegacyAliasKey, skipping the override and history keys that
+// share their prefix
+func legacyAliasKeys(r *redis.Client) ([]string, error) {
+	keys := []string{}
+	var cursor uint64
+	for {
+		batch, next, err := r.Scan(cursor, LegacyAliasKey("*"), 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("scan legacy aliases: %w", err)
+		}
+
+		for _, key := range batch {
+			if !strings.Contains(strings.TrimPrefix(key, LegacyAliasKey("")), ":") {
+				keys = append(keys, key)
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return keys, nil
+		}
+	}
+}
+
+func pushAliasHistory(pipe redis.Pipeliner, userID string, source AliasSource, alias string) {
+	key := AliasHistoryKey(userID)
+	pipe.LPush(key, fmt.Sprintf("%d|%s|%s", time.Now().UTC().Unix(), source, alias))
+	pipe.LTrim(key, 0, AliasHistoryLength-1)
+}
+
+// GetAliasHistory returns the previous aliases of a user, newest first
+func GetAliasHistory(r *redis.Client, userID string) ([]AliasChange, error) {
+	values, err := r.LRange(AliasHistoryKey(userID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get alias history: %w", err)
+	}
+
+	history := []AliasChange{}
+	for _, v := range values {
+		parts := strings.SplitN(v, "|", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		unix, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		history = append(history, AliasChange{
+			Time:   time.Unix(unix, 0).UTC(),
+			Source: AliasSource(parts[1]),
+			Alias:  parts[2],
+		})
+	}
+
+	return history, nil
+}
@@ -0,0 +1,231 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+)
+
+func TestValidateAlias(t *testing.T) {
+	cases := []struct {
+		name   string
+		alias  string
+		expErr error
+	}{
+		{"letters", "Banana", nil},
+		{"punctuation", "Ring-Ring_Banana.Phone's", nil},
+		{"spaces", "Big Banana", nil},
+		{"accents", "Ælfríc", nil},
+		{"empty", "", ErrInvalidAlias},
+		{"too long", "abcdefghijklmnopqrstuvwxyzabcdefg", ErrInvalidAlias},
+		{"mention", "<@123>", ErrInvalidAlias},
+		{"markdown", "**bold**", ErrInvalidAlias},
+		{"surrounding space", " Banana", ErrInvalidAlias},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ValidateAlias(c.alias)
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("expected '%v' got '%v'", c.expErr, err)
+			}
+		})
+	}
+}
+
+func TestGetUserAlias(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	member := &discordgo.Member{
+		GuildID: "guild",
+		Nick:    "Nick",
+		User:    &discordgo.User{ID: "user", Username: "Username"},
+	}
+	fake.AddMember(member)
+	fake.AddUser(&discordgo.User{ID: "stranger", Username: "Stranger"})
+
+	assertAlias := func(userID string, expected string) {
+		t.Helper()
+		alias, err := GetUserAlias(client, fake, "guild", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if alias != expected {
+			t.Errorf("expected '%s' got '%s'", expected, alias)
+		}
+	}
+
+	assertAlias("user", "Nick")
+	assertAlias("stranger", "Stranger")
+
+	// Names of users outside the guild are looked up again once they expire
+	if ttl := svc.TTL(UserNicknameKey("stranger")); ttl != NicknameTTL {
+		t.Errorf("expected the stranger's name to expire after '%v' got '%v'", NicknameTTL, ttl)
+	}
+
+	if ttl := svc.TTL(UserNicknameKey("user")); ttl != 0 {
+		t.Errorf("expected the member's nickname to be kept got '%v'", ttl)
+	}
+
+	err = SetUserAlias(client, "user", "Override")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAlias("user", "Override")
+
+	changed, err := RefreshMemberNickname(client, &discordgo.Member{GuildID: "guild", Nick: "Renamed", User: member.User})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("expected the nickname change to be detected")
+	}
+	assertAlias("user", "Override")
+
+	err = ResetUserAlias(client, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertAlias("user", "Renamed")
+
+	changed, err = RefreshMemberNickname(client, &discordgo.Member{GuildID: "guild", Nick: "Renamed", User: member.User})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("expected an unchanged nickname not to be recorded")
+	}
+
+	history, err := GetAliasHistory(client, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []AliasChange{
+		{Source: AliasReset},
+		{Source: AliasNickname, Alias: "Renamed"},
+		{Source: AliasOverride, Alias: "Override"},
+		{Source: AliasNickname, Alias: "Nick"},
+	}
+	if len(history) != len(expected) {
+		t.Fatalf("expected '%v' got '%v'", expected, history)
+	}
+
+	for i, change := range history {
+		if change.Source != expected[i].Source || change.Alias != expected[i].Alias || change.Time.IsZero() {
+			t.Errorf("expected '%v' got '%v'", expected[i], change)
+		}
+	}
+}
+
+func TestMigrateLegacyAliases(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	members := []*discordgo.Member{
+		{GuildID: "guild", Nick: "Nick", User: &discordgo.User{ID: "cached", Username: "Username"}},
+		{GuildID: "guild", Nick: "Nick", User: &discordgo.User{ID: "chosen", Username: "Username"}},
+		{GuildID: "guild", Nick: "Nick", User: &discordgo.User{ID: "none", Username: "Username"}},
+	}
+	for _, member := range members {
+		fake.AddMember(member)
+	}
+	fake.AddUser(&discordgo.User{ID: "left", Username: "Left"})
+	fake.AddUser(&discordgo.User{ID: "stranger", Username: "Stranger"})
+
+	// Before overrides had their own key the username of every member shown was cached with them
+	svc.Set(LegacyAliasKey("cached"), "Username")
+	svc.Set(LegacyAliasKey("chosen"), "Chosen")
+	svc.Set(LegacyAliasKey("left"), "Left")
+	svc.Set(LegacyAliasKey("stranger"), "Strange One")
+	svc.Set(LegacyAliasKey("deleted"), "Deleted")
+	svc.Lpush(AliasHistoryKey("chosen"), "0|setname|Chosen")
+
+	cases := []struct {
+		userID string
+		exp    string
+	}{
+		{"cached", "Nick"},
+		{"chosen", "Nick"},
+		{"none", "Nick"},
+	}
+
+	for _, c := range cases {
+		t.Run("before "+c.userID, func(t *testing.T) {
+			alias, err := GetUserAlias(client, fake, "guild", c.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if alias != c.exp {
+				t.Errorf("expected '%s' got '%s'", c.exp, alias)
+			}
+		})
+	}
+
+	// Only the guild members are listed, the others have left or were never members
+	migrated, err := MigrateLegacyAliases(client, fake, members)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if migrated != 5 {
+		t.Errorf("expected 5 legacy aliases migrated got '%d'", migrated)
+	}
+
+	if !svc.Exists(AliasHistoryKey("chosen")) {
+		t.Errorf("expected the alias history to be kept")
+	}
+
+	if svc.Exists(UserAliasKey("left")) {
+		t.Errorf("expected the cached username of a former member to be removed")
+	}
+
+	cases[1].exp = "Chosen"
+	cases = append(cases, []struct {
+		userID string
+		exp    string
+	}{
+		{"left", "Left"},
+		{"stranger", "Strange One"},
+		{"deleted", "Deleted"},
+	}...)
+	for _, c := range cases {
+		t.Run("after "+c.userID, func(t *testing.T) {
+			alias, err := GetUserAlias(client, fake, "guild", c.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if alias != c.exp {
+				t.Errorf("expected '%s' got '%s'", c.exp, alias)
+			}
+
+			if svc.Exists(LegacyAliasKey(c.userID)) {
+				t.Errorf("expected the legacy alias to be removed")
+			}
+		})
+	}
+}
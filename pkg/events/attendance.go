@@ -9,8 +9,11 @@ import (
 )
 
 type Attendance struct {
-	Absent []string
-	Late   []string
+	Absent    []string
+	Late      []string
+	Tentative []string
+	// SetBy maps "<list>:<user id>" to the id of the member who put the user on the list on their behalf
+	SetBy map[string]string
 }
 
 type UserListType string
@@ -18,6 +21,33 @@ type UserListType string
 var Absent UserListType = "absent"
 var Late UserListType = "late"
 
+// Tentative members do not know yet whether they can attend, they are asked to confirm before the event
+var Tentative UserListType = "tentative"
+
+// UserListTypes lists every attendance list in the order they are displayed
+var UserListTypes = []UserListType{Absent, Late, Tentative}
+
+var userListTitles = map[UserListType]string{
+	Absent:    "Out",
+	Late:      "Late",
+	Tentative: "Tentative",
+}
+
+// Excludes returns the lists a member is taken off when they are put on the list. Being out or late settles
+// whether a tentative member is attending, and going tentative undoes being out or late.
+func (t UserListType) Excludes() []UserListType {
+	if t == Tentative {
+		return []UserListType{Absent, Late}
+	}
+
+	return []UserListType{Tentative}
+}
+
+// Title returns the name of the list shown to members
+func (t UserListType) Title() string {
+	return userListTitles[t]
+}
+
 func UserListKeyForDate(date time.Time, t UserListType) string {
 	return fmt.Sprintf("%s:%d", t, util.BeginningOfDay(date.UTC()).Unix())
 }
@@ -26,125 +56,271 @@ func UserListKeyForEventId(id string, t UserListType) string {
 	return fmt.Sprintf("event:%s:%s", id, t)
 }
 
-func GetAttendanceForDay(redis *redis.Client, date time.Time) (Attendance, error) {
-	result := redis.SMembers(UserListKeyForDate(date, Absent))
-	if result.Err() != nil {
-		return Attendance{}, fmt.Errorf("lookup absent: %w", result.Err())
+// SetByKeyForEventId returns the hash recording who changed a member's attendance on their behalf
+func SetByKeyForEventId(id string) string {
+	return fmt.Sprintf("event:%s:setby", id)
+}
+
+func setByField(t UserListType, userID string) string {
+	return fmt.Sprintf("%s:%s", t, userID)
+}
+
+func GetAttendanceForDay(r *redis.Client, date time.Time) (Attendance, error) {
+	lists := map[UserListType]*redis.StringSliceCmd{}
+	_, err := r.Pipelined(func(pipe redis.Pipeliner) error {
+		for _, t := range UserListTypes {
+			lists[t] = pipe.SMembers(UserListKeyForDate(date, t))
+		}
+		return nil
+	})
+	if err != nil {
+		return Attendance{}, fmt.Errorf("lookup attendance: %w", err)
 	}
 
-	absent := result.Val()
-	result = redis.SMembers(UserListKeyForDate(date, Late))
-	if result.Err() != nil {
-		return Attendance{}, fmt.Errorf("lookup late: %w", result.Err())
+	return Attendance{
+		Absent:    lists[Absent].Val(),
+		Late:      lists[Late].Val(),
+		Tentative: lists[Tentative].Val(),
+	}, nil
+}
+
+// GetAttendanceForEvent reads every list of the event in a single round trip
+func GetAttendanceForEvent(r *redis.Client, evt Event) (Attendance, error) {
+	lists := map[UserListType]*redis.StringSliceCmd{}
+	var setBy *redis.StringStringMapCmd
+	_, err := r.Pipelined(func(pipe redis.Pipeliner) error {
+		for _, t := range UserListTypes {
+			lists[t] = pipe.SMembers(UserListKeyForEventId(evt.ID, t))
+		}
+		setBy = pipe.HGetAll(SetByKeyForEventId(evt.ID))
+		return nil
+	})
+	if err != nil {
+		return Attendance{}, fmt.Errorf("lookup attendance: %w", err)
 	}
 
-	late := result.Val()
 	return Attendance{
-		Absent: absent,
-		Late:   late,
+		Absent:    lists[Absent].Val(),
+		Late:      lists[Late].Val(),
+		Tentative: lists[Tentative].Val(),
+		SetBy:     setBy.Val(),
 	}, nil
 }
 
-func GetAttendanceForEvent(redis *redis.Client, evt Event) (Attendance, error) {
-	result := redis.SMembers(UserListKeyForEventId(evt.ID, Absent))
-	if result.Err() != nil {
-		return Attendance{}, fmt.Errorf("lookup absent: %w", result.Err())
+// List returns the ids of the users on the list
+func (a Attendance) List(t UserListType) []string {
+	switch t {
+	case Absent:
+		return a.Absent
+	case Late:
+		return a.Late
+	case Tentative:
+		return a.Tentative
+	default:
+		return nil
 	}
+}
 
-	absent := result.Val()
-	result = redis.SMembers(UserListKeyForEventId(evt.ID, Late))
-	if result.Err() != nil {
-		return Attendance{}, fmt.Errorf("lookup late: %w", result.Err())
+// UserIDs returns the ids of every user on a list or who set another user's attendance
+func (a Attendance) UserIDs() []string {
+	ids := []string{}
+	ids = append(ids, a.Absent...)
+	ids = append(ids, a.Late...)
+	ids = append(ids, a.Tentative...)
+	for _, actor := range a.SetBy {
+		ids = append(ids, actor)
 	}
 
-	late := result.Val()
-	return Attendance{
-		Absent: absent,
-		Late:   late,
-	}, nil
+	return ids
+}
+
+// Includes returns true when the user is on any list, or set any other member's attendance
+func (a Attendance) Includes(userID string) bool {
+	for _, id := range a.UserIDs() {
+		if id == userID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetByFor returns the id of the member who put the user on the list on their behalf, or an empty string if
+// the user changed their own attendance
+func (a Attendance) SetByFor(t UserListType, userID string) string {
+	return a.SetBy[setByField(t, userID)]
 }
 
-func UserListAddForRange(redis *redis.Client, r util.DateRange, id string, t UserListType) error {
-	return util.ForEachDay(r, func(d time.Time) error {
-		err := UserListAdd(redis, d, id, t)
+// UserListAddForRange adds the user to the list for every day in the range on behalf of actor, see
+// UserListAddBy. The events of the range are read once and returned.
+func UserListAddForRange(redis *redis.Client, r util.DateRange, id string, t UserListType, actor string) ([]Event, error) {
+	evts, byDay, err := getEventsByDay(redis, r)
+	if err != nil {
+		return nil, fmt.Errorf("get events for range: %w", err)
+	}
+
+	err = util.ForEachDay(r, func(d time.Time) error {
+		err := userListAdd(redis, d, byDay[dayKey(d)], id, t, actor)
 		if err != nil {
 			return fmt.Errorf("add to user list: %w", err)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return evts, nil
 }
 
-func UserListRemoveForRange(redis *redis.Client, r util.DateRange, id string, t UserListType) error {
-	return util.ForEachDay(r, func(d time.Time) error {
-		err := UserListRemove(redis, d, id, t)
+// UserListRemoveForRange removes the user from the list for every day in the range, see UserListRemove. The
+// events of the range are read once and returned.
+func UserListRemoveForRange(redis *redis.Client, r util.DateRange, id string, t UserListType) ([]Event, error) {
+	evts, byDay, err := getEventsByDay(redis, r)
+	if err != nil {
+		return nil, fmt.Errorf("get events for range: %w", err)
+	}
+
+	err = util.ForEachDay(r, func(d time.Time) error {
+		err := userListRemove(redis, d, byDay[dayKey(d)], id, t)
 		if err != nil {
 			return fmt.Errorf("remove from user list: %w", err)
 		}
 
 		return nil
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	return evts, nil
+}
+
+// UserListAdd adds the user to the list for a day, including every event already scheduled on that day
+func UserListAdd(r *redis.Client, date time.Time, id string, t UserListType) error {
+	return UserListAddBy(r, date, id, t, id)
+}
+
+// UserListAddBy adds the user to the list for a day on behalf of actor, the actor is recorded on every event
+// already scheduled on that day as with EventUserListAddBy
+func UserListAddBy(r *redis.Client, date time.Time, id string, t UserListType, actor string) error {
+	_, byDay, err := getEventsByDay(r, util.DateRange{Begin: date, End: date})
+	if err != nil {
+		return fmt.Errorf("get events for day: %w", err)
+	}
+
+	return userListAdd(r, date, byDay[dayKey(date)], id, t, actor)
 }
 
-func UserListAdd(redis *redis.Client, date time.Time, id string, t UserListType) error {
-	key := UserListKeyForDate(date, t)
-	result := redis.SAdd(key, id)
-	if result.Err() != nil {
-		return fmt.Errorf("add id to set: %w", result.Err())
+// UserListRemove removes the user from the list for a day, including every event already scheduled on that
+// day
+func UserListRemove(redis *redis.Client, date time.Time, id string, t UserListType) error {
+	_, byDay, err := getEventsByDay(redis, util.DateRange{Begin: date, End: date})
+	if err != nil {
+		return fmt.Errorf("get events for day: %w", err)
 	}
 
-	evts, err := GetEventsForWeek(redis, date)
+	return userListRemove(redis, date, byDay[dayKey(date)], id, t)
+}
+
+func userListAdd(r *redis.Client, date time.Time, evts []Event, id string, t UserListType, actor string) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SAdd(UserListKeyForDate(date, t), id)
+		for _, excluded := range t.Excludes() {
+			pipe.SRem(UserListKeyForDate(date, excluded), id)
+		}
+		return nil
+	})
 	if err != nil {
-		return fmt.Errorf("get events for week: %w", err)
+		return fmt.Errorf("add id to set: %w", err)
 	}
 
 	for _, evt := range evts {
-		err := EventUserListAdd(redis, evt, id, t)
+		err := EventUserListAddBy(r, evt, id, t, actor)
 		if err != nil {
-			return fmt.Errorf("mark absent for event: %w", err)
+			return fmt.Errorf("add user to event list: %w", err)
 		}
 	}
 
 	return nil
 }
 
-func UserListRemove(redis *redis.Client, date time.Time, id string, t UserListType) error {
-	key := UserListKeyForDate(date, t)
-	result := redis.SAdd(key, id)
-	if result.Err() != nil {
-		return fmt.Errorf("add id to set: %w", result.Err())
-	}
-
-	evts, err := GetEventsForWeek(redis, date)
+func userListRemove(r *redis.Client, date time.Time, evts []Event, id string, t UserListType) error {
+	err := r.SRem(UserListKeyForDate(date, t), id).Err()
 	if err != nil {
-		return fmt.Errorf("get events for week: %w", err)
+		return fmt.Errorf("remove id from set: %w", err)
 	}
 
 	for _, evt := range evts {
-		err := EventUserListAdd(redis, evt, id, t)
+		err := EventUserListRemove(r, evt, id, t)
 		if err != nil {
-			return fmt.Errorf("mark absent for event: %w", err)
+			return fmt.Errorf("remove user from event list: %w", err)
 		}
 	}
 
 	return nil
 }
 
+// getEventsByDay returns the events on the days of the range, both as a list and grouped by dayKey
+func getEventsByDay(r *redis.Client, dates util.DateRange) ([]Event, map[string][]Event, error) {
+	evts, err := GetEventsForDateRange(r, util.DateRange{
+		Begin: util.BeginningOfDay(dates.Begin.UTC()),
+		End:   util.EndOfDay(dates.End.UTC()),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byDay := map[string][]Event{}
+	for _, evt := range evts {
+		byDay[dayKey(evt.Time)] = append(byDay[dayKey(evt.Time)], evt)
+	}
+
+	return evts, byDay, nil
+}
+
+func dayKey(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+// EventUserListAdd adds the user to the event's list as a change made by the user themselves
 func EventUserListAdd(redis *redis.Client, evt Event, id string, t UserListType) error {
-	key := UserListKeyForEventId(evt.ID, t)
-	result := redis.SAdd(key, id)
-	if result.Err() != nil {
-		return fmt.Errorf("add id to set: %w", result.Err())
+	return EventUserListAddBy(redis, evt, id, t, id)
+}
+
+// EventUserListAddBy adds the user to the event's list on behalf of actor. The actor is recorded so that it
+// can be shown on the announcement when it is not the user.
+func EventUserListAddBy(r *redis.Client, evt Event, id string, t UserListType, actor string) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SAdd(UserListKeyForEventId(evt.ID, t), id)
+		for _, excluded := range t.Excludes() {
+			pipe.SRem(UserListKeyForEventId(evt.ID, excluded), id)
+			pipe.HDel(SetByKeyForEventId(evt.ID), setByField(excluded, id))
+		}
+		if actor == "" || actor == id {
+			pipe.HDel(SetByKeyForEventId(evt.ID), setByField(t, id))
+		} else {
+			pipe.HSet(SetByKeyForEventId(evt.ID), setByField(t, id), actor)
+			pipe.Expire(SetByKeyForEventId(evt.ID), EventTTL)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("add id to set: %w", err)
 	}
 
 	return nil
 }
 
-func EventUserListRemove(redis *redis.Client, evt Event, id string, t UserListType) error {
-	key := UserListKeyForEventId(evt.ID, t)
-	result := redis.SRem(key, id)
-	if result.Err() != nil {
-		return fmt.Errorf("remove id from set: %w", result.Err())
+func EventUserListRemove(r *redis.Client, evt Event, id string, t UserListType) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SRem(UserListKeyForEventId(evt.ID, t), id)
+		pipe.HDel(SetByKeyForEventId(evt.ID), setByField(t, id))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("remove id from set: %w", err)
 	}
 
 	return nil
@@ -2,9 +2,11 @@ package events
 
 import (
 	"reflect"
+	"sort"
 	"testing"
 	"time"
 
+	"github.com/acastle/esperbot/pkg/util"
 	"github.com/alicebob/miniredis/v2"
 	"github.com/go-redis/redis"
 )
@@ -22,9 +24,11 @@ func TestGetAttendanceForDay(t *testing.T) {
 	day := time.Date(2010, 12, 20, 12, 30, 0, 0, time.UTC)
 	svc.SAdd(UserListKeyForDate(day, Absent), "abc123")
 	svc.SAdd(UserListKeyForDate(day, Late), "321asd")
+	svc.SAdd(UserListKeyForDate(day, Tentative), "xyz789")
 	expected := Attendance{
-		Absent: []string{"abc123"},
-		Late:   []string{"321asd"},
+		Absent:    []string{"abc123"},
+		Late:      []string{"321asd"},
+		Tentative: []string{"xyz789"},
 	}
 
 	result, err := GetAttendanceForDay(client, day)
@@ -36,3 +40,167 @@ func TestGetAttendanceForDay(t *testing.T) {
 		t.Errorf("expected '%v' got '%v'", expected, result)
 	}
 }
+
+func TestUserListRemove(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	day := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	evt := Event{ID: "raid", Name: "Raid", Time: day.Add(19 * time.Hour)}
+	err = ScheduleEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UserListAdd(client, day, "user", Absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UserListRemove(client, day, "user", Absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := svc.SIsMember(UserListKeyForDate(day, Absent), "user"); ok {
+		t.Error("expected the user to be removed from the day")
+	}
+
+	attendance, err := GetAttendanceForEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attendance.Absent) != 0 {
+		t.Errorf("expected the user to be removed from the event got '%v'", attendance.Absent)
+	}
+}
+
+func TestUserListAddOnlyAffectsTheDay(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	evts := []Event{
+		{ID: "wed", Name: "Raid", Time: wednesday.Add(19 * time.Hour)},
+		{ID: "fri", Name: "Raid", Time: wednesday.AddDate(0, 0, 2).Add(19 * time.Hour)},
+	}
+	for _, evt := range evts {
+		err = ScheduleEvent(client, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = UserListAdd(client, wednesday, "user", Absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		evt    Event
+		absent bool
+	}{
+		{evts[0], true},
+		{evts[1], false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.evt.ID, func(t *testing.T) {
+			attendance, err := GetAttendanceForEvent(client, c.evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if absent := len(attendance.Absent) == 1; absent != c.absent {
+				t.Errorf("expected absent '%v' got '%v'", c.absent, attendance.Absent)
+			}
+		})
+	}
+}
+
+func TestUserListAddForRange(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	wednesday := time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)
+	evts := []Event{
+		{ID: "before", Name: "Raid", Time: wednesday.AddDate(0, 0, -1).Add(19 * time.Hour)},
+		{ID: "wed", Name: "Raid", Time: wednesday.Add(19 * time.Hour)},
+		{ID: "wed-late", Name: "Raid", Time: wednesday.Add(22 * time.Hour)},
+		{ID: "next-week", Name: "Raid", Time: wednesday.AddDate(0, 0, 6).Add(19 * time.Hour)},
+		{ID: "after", Name: "Raid", Time: wednesday.AddDate(0, 0, 7).Add(19 * time.Hour)},
+	}
+	for _, evt := range evts {
+		err = ScheduleEvent(client, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dates := util.DateRange{Begin: wednesday, End: util.EndOfDay(wednesday.AddDate(0, 0, 6))}
+	changed, err := UserListAddForRange(client, dates, "user", Absent, "officer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := eventIDsOf(changed); !reflect.DeepEqual(ids, []string{"next-week", "wed", "wed-late"}) {
+		t.Errorf("expected the events of the range got '%v'", ids)
+	}
+
+	cases := []struct {
+		evt    Event
+		absent bool
+	}{
+		{evts[0], false},
+		{evts[1], true},
+		{evts[2], true},
+		{evts[3], true},
+		{evts[4], false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.evt.ID, func(t *testing.T) {
+			attendance, err := GetAttendanceForEvent(client, c.evt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if absent := len(attendance.Absent) == 1; absent != c.absent {
+				t.Errorf("expected absent '%v' got '%v'", c.absent, attendance.Absent)
+			}
+
+			if c.absent && attendance.SetByFor(Absent, "user") != "officer" {
+				t.Errorf("expected the change to be attributed to 'officer' got '%v'", attendance.SetBy)
+			}
+		})
+	}
+}
+
+func eventIDsOf(evts []Event) []string {
+	ids := make([]string, len(evts))
+	for i, evt := range evts {
+		ids[i] = evt.ID
+	}
+
+	sort.Strings(ids)
+	return ids
+}
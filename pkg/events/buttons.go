@@ -0,0 +1,77 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/go-redis/redis"
+)
+
+var ErrUnknownButton = errors.New("unknown attendance button")
+
+// ButtonPrefix starts the custom id of every attendance button, it is followed by the list or "clear"
+const ButtonPrefix = "attendance:"
+
+const clearButton = "clear"
+
+var buttonStyles = map[UserListType]int{
+	Absent:    discord.ButtonDanger,
+	Late:      discord.ButtonPrimary,
+	Tentative: discord.ButtonSecondary,
+}
+
+// AnnouncementComponents returns a row with a button for each attendance list and one to clear attendance. The
+// buttons show the emoji of the reaction that has the same effect.
+func AnnouncementComponents(mappings []ReactionMapping) []discord.Component {
+	buttons := []discord.Component{}
+	for _, t := range UserListTypes {
+		emoji, _ := ReactionForList(mappings, t)
+		buttons = append(buttons, discord.Button(buttonStyles[t], t.Title(), emoji, ButtonPrefix+string(t)))
+	}
+	buttons = append(buttons, discord.Button(discord.ButtonSuccess, "Clear", "", ButtonPrefix+clearButton))
+
+	return []discord.Component{discord.ActionRow(buttons...)}
+}
+
+// ParseButton returns the list set by an attendance button, the clear button returns an empty list
+func ParseButton(customID string) (UserListType, error) {
+	if !strings.HasPrefix(customID, ButtonPrefix) {
+		return "", ErrUnknownButton
+	}
+
+	name := strings.TrimPrefix(customID, ButtonPrefix)
+	if name == clearButton {
+		return "", nil
+	}
+
+	for _, t := range UserListTypes {
+		if string(t) == name {
+			return t, nil
+		}
+	}
+
+	return "", ErrUnknownButton
+}
+
+// EventUserListSet puts the user on exactly one of the event's lists, removing them from every other. An empty
+// list removes the user from all of them.
+func EventUserListSet(r *redis.Client, evt Event, id string, t UserListType) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		for _, list := range UserListTypes {
+			if list == t {
+				pipe.SAdd(UserListKeyForEventId(evt.ID, list), id)
+			} else {
+				pipe.SRem(UserListKeyForEventId(evt.ID, list), id)
+			}
+			pipe.HDel(SetByKeyForEventId(evt.ID), setByField(list, id))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("set user list: %w", err)
+	}
+
+	return nil
+}
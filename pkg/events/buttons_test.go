@@ -0,0 +1,61 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/acastle/esperbot/pkg/discord"
+)
+
+func TestParseButton(t *testing.T) {
+	cases := []struct {
+		name     string
+		customID string
+		exp      UserListType
+		expErr   error
+	}{
+		{"list", "attendance:tentative", Tentative, nil},
+		{"clear", "attendance:clear", "", nil},
+		{"unknown list", "attendance:early", "", ErrUnknownButton},
+		{"other button", "poll:yes", "", ErrUnknownButton},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			list, err := ParseButton(c.customID)
+			if err != c.expErr {
+				t.Fatalf("expected '%v' got '%v'", c.expErr, err)
+			}
+
+			if list != c.exp {
+				t.Errorf("expected '%v' got '%v'", c.exp, list)
+			}
+		})
+	}
+}
+
+func TestAnnouncementComponents(t *testing.T) {
+	components := AnnouncementComponents([]ReactionMapping{{Emoji: "maybe:123", List: Tentative}})
+	if len(components) != 1 || components[0].Type != discord.ComponentActionRow {
+		t.Fatalf("expected a single row got '%v'", components)
+	}
+
+	buttons := components[0].Components
+	if len(buttons) != len(UserListTypes)+1 {
+		t.Fatalf("expected a button per list and clear got '%v'", buttons)
+	}
+
+	for i, button := range buttons[:len(UserListTypes)] {
+		list, err := ParseButton(button.CustomID)
+		if err != nil || list != UserListTypes[i] {
+			t.Errorf("expected a button for '%v' got '%v'", UserListTypes[i], button)
+		}
+	}
+
+	if emoji := buttons[2].Emoji; emoji == nil || emoji.Name != "maybe" || emoji.ID != "123" {
+		t.Errorf("expected the custom emoji on the tentative button got '%v'", emoji)
+	}
+
+	if buttons[0].Emoji != nil {
+		t.Errorf("expected no emoji for an unmapped list got '%v'", buttons[0].Emoji)
+	}
+}
@@ -0,0 +1,295 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrUnknownEmbedSetting = errors.New("unknown embed setting")
+var ErrInvalidEmbedSetting = errors.New("invalid embed setting")
+
+const DefaultEmbedDescription = "{{.Date}}"
+
+// MaxEmbedTextLength bounds titles and labels, Discord rejects embeds with longer ones
+const MaxEmbedTextLength = 256
+
+// MaxEmbedDescriptionLength bounds both the description template and the rendered description, Discord
+// rejects embeds with longer descriptions
+const MaxEmbedDescriptionLength = 2048
+
+// EmbedSettings customize the announcements of a recurring event. Settings that are empty fall back to the
+// theme and the defaults above. Description is a text/template executed with EmbedData.
+type EmbedSettings struct {
+	Title        string
+	Description  string
+	ThumbnailURL string
+	Color        int
+	// InstanceName replaces the event name shown at the top of the embed
+	InstanceName   string
+	OutLabel       string
+	LateLabel      string
+	TentativeLabel string
+}
+
+// EmbedData is available to description templates, for example "{{.Name}} on {{.Time.Format "Jan 2"}}"
+type EmbedData struct {
+	ID   string
+	Name string
+	Time time.Time
+	// Date is the time in the standard announcement format, for example "Wednesday Aug 25 2021"
+	Date      string
+	Out       int
+	Late      int
+	Tentative int
+}
+
+// EmbedSettingNames lists the settings accepted by Set in the order they are displayed
+var EmbedSettingNames = []string{"title", "description", "thumbnail", "color", "name", "out", "late", "tentative"}
+
+// Get returns a setting formatted as it would be given to Set, or an empty string when it is not set
+func (s EmbedSettings) Get(name string) string {
+	switch name {
+	case "title":
+		return s.Title
+	case "description":
+		return s.Description
+	case "thumbnail":
+		return s.ThumbnailURL
+	case "color":
+		if s.Color == 0 {
+			return ""
+		}
+		return fmt.Sprintf("#%06X", s.Color)
+	case "name":
+		return s.InstanceName
+	case "out":
+		return s.OutLabel
+	case "late":
+		return s.LateLabel
+	case "tentative":
+		return s.TentativeLabel
+	}
+
+	return ""
+}
+
+// Set validates and changes one of the settings named in EmbedSettingNames, an empty value resets it
+func (s *EmbedSettings) Set(name string, value string) error {
+	value = strings.TrimSpace(value)
+	switch name {
+	case "title":
+		return setText(&s.Title, value, MaxEmbedTextLength)
+	case "name":
+		return setText(&s.InstanceName, value, MaxEmbedTextLength)
+	case "out":
+		return setText(&s.OutLabel, value, MaxEmbedTextLength)
+	case "late":
+		return setText(&s.LateLabel, value, MaxEmbedTextLength)
+	case "tentative":
+		return setText(&s.TentativeLabel, value, MaxEmbedTextLength)
+	case "description":
+		if len([]rune(value)) > MaxEmbedDescriptionLength {
+			return fmt.Errorf("%w: the description must be at most %d characters", ErrInvalidEmbedSetting, MaxEmbedDescriptionLength)
+		}
+
+		if value != "" {
+			_, err := RenderDescription(value, EmbedData{Time: time.Now().UTC()})
+			if err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidEmbedSetting, err)
+			}
+		}
+
+		s.Description = value
+	case "thumbnail":
+		if value != "" {
+			u, err := url.Parse(value)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("%w: the thumbnail must be an http(s) url", ErrInvalidEmbedSetting)
+			}
+		}
+
+		s.ThumbnailURL = value
+	case "color":
+		color, err := ParseColor(value)
+		if err != nil {
+			return err
+		}
+
+		s.Color = color
+	default:
+		return ErrUnknownEmbedSetting
+	}
+
+	return nil
+}
+
+func setText(field *string, value string, max int) error {
+	if len([]rune(value)) > max {
+		return fmt.Errorf("%w: it must be at most %d characters", ErrInvalidEmbedSetting, max)
+	}
+
+	*field = value
+	return nil
+}
+
+// ParseColor parses a color given as "#RRGGBB", "0xRRGGBB" or a decimal number. An empty string is parsed as 0,
+// which stands for the theme's color.
+func ParseColor(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	var color int64
+	var err error
+	if hex := strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(s), "#"), "0x"); hex != strings.ToLower(s) {
+		color, err = strconv.ParseInt(hex, 16, 32)
+	} else {
+		color, err = strconv.ParseInt(s, 10, 32)
+	}
+	if err != nil || color < 0 || color > 0xFFFFFF {
+		return 0, fmt.Errorf("%w: colors are given as #RRGGBB", ErrInvalidEmbedSetting)
+	}
+
+	return int(color), nil
+}
+
+// Resolve returns the settings with every empty setting replaced by the theme or the default, labels are
+// resolved by Label
+func (s EmbedSettings) Resolve(theme EmbedTheme) EmbedSettings {
+	if s.Title == "" {
+		s.Title = theme.Title
+	}
+
+	if s.Description == "" {
+		s.Description = DefaultEmbedDescription
+	}
+
+	if s.ThumbnailURL == "" {
+		s.ThumbnailURL = theme.ThumbnailURL
+	}
+
+	if s.Color == 0 {
+		s.Color = theme.Color
+	}
+
+	return s
+}
+
+// Label returns the name of the embed field for the list. Lists without a label are named after the list and
+// the first reaction that puts members on it, for example "❌ Out".
+func (s EmbedSettings) Label(t UserListType, mappings []ReactionMapping) string {
+	switch {
+	case t == Absent && s.OutLabel != "":
+		return s.OutLabel
+	case t == Late && s.LateLabel != "":
+		return s.LateLabel
+	case t == Tentative && s.TentativeLabel != "":
+		return s.TentativeLabel
+	}
+
+	if emoji, ok := ReactionForList(mappings, t); ok {
+		return fmt.Sprintf("%s %s", DisplayEmoji(emoji), t.Title())
+	}
+
+	return t.Title()
+}
+
+// RenderDescription executes a description template
+func RenderDescription(tmpl string, data EmbedData) (string, error) {
+	t, err := template.New("description").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("parse description: %w", err)
+	}
+
+	var b strings.Builder
+	err = t.Execute(&b, data)
+	if err != nil {
+		return "", fmt.Errorf("execute description: %w", err)
+	}
+
+	return b.String(), nil
+}
+
+// renderDescriptionOrDefault renders the description template, falling back to DefaultEmbedDescription when
+// it fails. Templates are only checked against empty data when they are set, so they can still fail with the
+// data of a particular event. The rendered description is cut to MaxEmbedDescriptionLength.
+func renderDescriptionOrDefault(tmpl string, data EmbedData) (string, error) {
+	description, err := RenderDescription(tmpl, data)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"id":    data.ID,
+			"error": err,
+		}).Warn("description template failed, using the default")
+		description, err = RenderDescription(DefaultEmbedDescription, data)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if runes := []rune(description); len(runes) > MaxEmbedDescriptionLength {
+		description = string(runes[:MaxEmbedDescriptionLength-1]) + "…"
+	}
+
+	return description, nil
+}
+
+// embedFields maps each setting to its field in the recurring event's hash
+var embedFields = map[string]string{
+	"title":       "embed_title",
+	"description": "embed_description",
+	"thumbnail":   "embed_thumbnail_url",
+	"color":       "embed_color",
+	"name":        "embed_instance_name",
+	"out":         "embed_out_label",
+	"late":        "embed_late_label",
+	"tentative":   "embed_tentative_label",
+}
+
+// embedSettingsFromHash decodes the settings stored by SetEmbedSettings. They were validated by Set when they
+// were changed, so only the color is parsed here and the description template is not executed.
+func embedSettingsFromHash(data map[string]string) (EmbedSettings, error) {
+	color, err := ParseColor(data[embedFields["color"]])
+	if err != nil {
+		return EmbedSettings{}, fmt.Errorf("parse embed color: %w", err)
+	}
+
+	return EmbedSettings{
+		Title:          data[embedFields["title"]],
+		Description:    data[embedFields["description"]],
+		ThumbnailURL:   data[embedFields["thumbnail"]],
+		Color:          color,
+		InstanceName:   data[embedFields["name"]],
+		OutLabel:       data[embedFields["out"]],
+		LateLabel:      data[embedFields["late"]],
+		TentativeLabel: data[embedFields["tentative"]],
+	}, nil
+}
+
+// SetEmbedSettings stores the embed settings of a recurring event. They are kept apart from UpsertRecurringEvent
+// so that templates created from configuration at startup keep the settings changed by admins.
+func SetEmbedSettings(r *redis.Client, templateID string, s EmbedSettings) error {
+	key := RecurringEventKeyForId(templateID)
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		for _, name := range EmbedSettingNames {
+			if value := s.Get(name); value != "" {
+				pipe.HSet(key, embedFields[name], value)
+			} else {
+				pipe.HDel(key, embedFields[name])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("store embed settings: %w", err)
+	}
+
+	return nil
+}
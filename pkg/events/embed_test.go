@@ -0,0 +1,177 @@
+package events
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+)
+
+func TestEmbedSettingsSet(t *testing.T) {
+	cases := []struct {
+		name    string
+		setting string
+		value   string
+		expErr  error
+		exp     string
+	}{
+		{"title", "title", "Castle Nathria", nil, "Castle Nathria"},
+		{"description", "description", "{{.Name}} on {{.Date}}", nil, "{{.Name}} on {{.Date}}"},
+		{"unknown template field", "description", "{{.Boss}}", ErrInvalidEmbedSetting, ""},
+		{"malformed template", "description", "{{.Name", ErrInvalidEmbedSetting, ""},
+		{"long accented description", "description", strings.Repeat("é", MaxEmbedDescriptionLength), nil, strings.Repeat("é", MaxEmbedDescriptionLength)},
+		{"too long description", "description", strings.Repeat("é", MaxEmbedDescriptionLength+1), ErrInvalidEmbedSetting, ""},
+		{"hex color", "color", "#ff8800", nil, "#FF8800"},
+		{"decimal color", "color", "255", nil, "#0000FF"},
+		{"invalid color", "color", "orange", ErrInvalidEmbedSetting, ""},
+		{"thumbnail", "thumbnail", "https://example.com/icon.png", nil, "https://example.com/icon.png"},
+		{"invalid thumbnail", "thumbnail", "example.com/icon.png", ErrInvalidEmbedSetting, ""},
+		{"reset", "out", "", nil, ""},
+		{"unknown setting", "footer", "text", ErrUnknownEmbedSetting, ""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := EmbedSettings{OutLabel: "Out"}
+			err := s.Set(c.setting, c.value)
+			if !errors.Is(err, c.expErr) {
+				t.Fatalf("expected '%v' got '%v'", c.expErr, err)
+			}
+
+			if err == nil && s.Get(c.setting) != c.exp {
+				t.Errorf("expected '%v' got '%v'", c.exp, s.Get(c.setting))
+			}
+		})
+	}
+}
+
+func TestGetEmbedForEventSettings(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	template := RecurringEvent{ID: "raid", Name: "Raid", Weekdays: []time.Weekday{time.Wednesday}}
+	err = UpsertRecurringEvent(client, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt := Event{
+		ID:               "raid-2021-08-25",
+		Name:             "Raid",
+		Time:             time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		RecurringEventID: "raid",
+	}
+	err = CreateEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = EventUserListAdd(client, evt, "user", Absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := discord.NewFake()
+	fake.AddUser(&discordgo.User{ID: "user", Username: "User"})
+	embed, err := GetEmbedForEvent(fake, client, testSettings, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if embed.Title != DefaultEmbedTheme.Title || embed.Description != "Wednesday Aug 25 2021" || embed.Fields[0].Name != "❌ Out" {
+		t.Errorf("expected the theme and defaults got '%v'", embed)
+	}
+
+	settings := EmbedSettings{
+		Title:        "Castle Nathria",
+		Description:  "{{.Name}}: {{.Out}} out, {{.Late}} late",
+		Color:        0xFF8800,
+		InstanceName: "Mythic Progression",
+		OutLabel:     "Benched",
+	}
+	err = SetEmbedSettings(client, template.ID, settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Upserting the template from configuration keeps its embed settings
+	err = UpsertRecurringEvent(client, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := GetRecurringEventById(client, template.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Embed != settings {
+		t.Errorf("expected '%v' got '%v'", settings, stored.Embed)
+	}
+
+	embed, err = GetEmbedForEvent(fake, client, testSettings, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if embed.Title != "Castle Nathria" || embed.Color != 0xFF8800 || embed.Author.Name != "Mythic Progression" {
+		t.Errorf("expected the settings to be applied got '%v'", embed)
+	}
+
+	if embed.Description != "Mythic Progression: 1 out, 0 late" {
+		t.Errorf("expected 'Mythic Progression: 1 out, 0 late' got '%s'", embed.Description)
+	}
+
+	if embed.Fields[0].Name != "Benched" || embed.Fields[1].Name != "🕘 Late" {
+		t.Errorf("expected the field labels to be applied got '%s' and '%s'", embed.Fields[0].Name, embed.Fields[1].Name)
+	}
+}
+
+func TestRenderDescriptionOrDefault(t *testing.T) {
+	data := EmbedData{
+		ID:   "raid-2021-08-25",
+		Name: "Raid",
+		Time: time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		Date: "Wednesday Aug 25 2021",
+		Out:  1,
+	}
+
+	cases := []struct {
+		name string
+		tmpl string
+		exp  string
+	}{
+		{"renders", "{{.Name}}: {{.Out}} out", "Raid: 1 out"},
+		{"fails with the event's data", "{{if .Out}}{{index .Name 40}}{{end}}", "Wednesday Aug 25 2021"},
+		{"too long", `{{printf "%3000s" .Name}}`, strings.Repeat(" ", MaxEmbedDescriptionLength-1) + "…"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := EmbedSettings{}
+			err := s.Set("description", c.tmpl)
+			if err != nil {
+				t.Fatalf("expected the template to be accepted got '%v'", err)
+			}
+
+			got, err := renderDescriptionOrDefault(s.Description, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got != c.exp {
+				t.Errorf("expected '%v' got '%v'", c.exp, got)
+			}
+		})
+	}
+}
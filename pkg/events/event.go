@@ -4,8 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/metrics"
+	"github.com/acastle/esperbot/pkg/roster"
 	"github.com/acastle/esperbot/pkg/util"
 	"github.com/bwmarrin/discordgo"
 	"github.com/go-redis/redis"
@@ -26,6 +30,29 @@ func EventIndexKeyForMessageId(channelID string, messageID string) string {
 	return fmt.Sprintf("index:event_by_message:%s:%s", channelID, messageID)
 }
 
+// EventTTL is how long events and their indexes are kept in storage
+const EventTTL = 365 * 24 * time.Hour
+
+// EmbedTheme sets the appearance of announcement embeds
+type EmbedTheme struct {
+	Title        string
+	ThumbnailURL string
+	Color        int
+}
+
+var DefaultEmbedTheme = EmbedTheme{
+	Title:        "Sanctum of Domination",
+	ThumbnailURL: "https://wow.zamimg.com/images/wow/icons/large/achievement_raid_torghastraid.jpg",
+}
+
+// Settings are the configured values announcements are rendered with
+type Settings struct {
+	// GuildID is the guild whose member nicknames are used as aliases, usernames are used when it is empty
+	GuildID string
+	// Theme is the appearance of announcement and summary embeds
+	Theme EmbedTheme
+}
+
 type RaidStatus string
 
 const (
@@ -58,13 +85,13 @@ func ScheduleEvent(redis *redis.Client, event Event) error {
 
 	if event.AnnounceMessageID != "" && event.AnnounceChannelID != "" {
 		messageIndexKey := EventIndexKeyForMessageId(event.AnnounceChannelID, event.AnnounceMessageID)
-		pipe.Set(messageIndexKey, event.ID, time.Duration(365*24*time.Hour))
+		pipe.Set(messageIndexKey, event.ID, EventTTL)
 	}
 
 	indexKey := EventIndexKeyForDate(event.Time)
 	pipe.SAdd(indexKey, event.ID)
-	pipe.Expire(key, time.Duration(365*24*time.Hour))
-	pipe.Expire(indexKey, time.Duration(365*24*time.Hour))
+	pipe.Expire(key, EventTTL)
+	pipe.Expire(indexKey, EventTTL)
 	_, err := pipe.Exec()
 	if err != nil {
 		return fmt.Errorf("upsert event: %w", err)
@@ -73,6 +100,47 @@ func ScheduleEvent(redis *redis.Client, event Event) error {
 	return nil
 }
 
+var ErrEventExists = errors.New("event already exists")
+
+// createEventScript stores a new event and adds it to the weekly index only if no event with the same key
+// exists, making concurrent creation of the same instance safe.
+var createEventScript = redis.NewScript(`
+if redis.call("EXISTS", KEYS[1]) == 1 then
+	return 0
+end
+redis.call("HSET", KEYS[1], "id", ARGV[2], "name", ARGV[3], "time", ARGV[4], "status", ARGV[5], "recurring_event_id", ARGV[6], "announce_message_id", ARGV[7], "announce_channel_id", ARGV[8])
+redis.call("EXPIRE", KEYS[1], ARGV[1])
+redis.call("SADD", KEYS[2], ARGV[2])
+redis.call("EXPIRE", KEYS[2], ARGV[1])
+return 1
+`)
+
+// CreateEvent atomically stores a new event, returning ErrEventExists if an event with the same ID has
+// already been stored. Use ScheduleEvent to update an existing event.
+func CreateEvent(r *redis.Client, event Event) error {
+	keys := []string{EventKeyForID(event.ID), EventIndexKeyForDate(event.Time)}
+	result := createEventScript.Run(r, keys,
+		int64(EventTTL.Seconds()),
+		event.ID,
+		event.Name,
+		event.Time.Unix(),
+		string(Scheduled),
+		event.RecurringEventID,
+		event.AnnounceMessageID,
+		event.AnnounceChannelID,
+	)
+	created, err := result.Int64()
+	if err != nil {
+		return fmt.Errorf("create event: %w", err)
+	}
+
+	if created == 0 {
+		return ErrEventExists
+	}
+
+	return nil
+}
+
 func GetEventById(redis *redis.Client, id string) (Event, error) {
 	key := EventKeyForID(id)
 	result := redis.HGetAll(key)
@@ -80,7 +148,10 @@ func GetEventById(redis *redis.Client, id string) (Event, error) {
 		return Event{}, fmt.Errorf("get properties for event: %w", result.Err())
 	}
 
-	data := result.Val()
+	return parseEvent(result.Val())
+}
+
+func parseEvent(data map[string]string) (Event, error) {
 	timestamp, err := strconv.ParseInt(data["time"], 10, 64)
 	if err != nil {
 		return Event{}, fmt.Errorf("parse date from time: %w", err)
@@ -97,6 +168,37 @@ func GetEventById(redis *redis.Client, id string) (Event, error) {
 	}, nil
 }
 
+// GetEventsByIds reads several events in a single round trip. Events that no longer exist, for example
+// because they expired while still listed in an index, are skipped.
+func GetEventsByIds(r *redis.Client, ids []string) ([]Event, error) {
+	cmds := make([]*redis.StringStringMapCmd, len(ids))
+	_, err := r.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.HGetAll(EventKeyForID(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get properties for events: %w", err)
+	}
+
+	evts := []Event{}
+	for _, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue
+		}
+
+		evt, err := parseEvent(cmd.Val())
+		if err != nil {
+			return nil, err
+		}
+
+		evts = append(evts, evt)
+	}
+
+	return evts, nil
+}
+
 var ErrEventNotFound = errors.New("event could not be found")
 
 func GetEventByMessage(r *redis.Client, channelID string, messageID string) (Event, error) {
@@ -122,45 +224,96 @@ func GetEventsForWeek(redis *redis.Client, date time.Time) ([]Event, error) {
 		return nil, fmt.Errorf("get events from index: %w", result.Err())
 	}
 
-	evts := []Event{}
-	for _, id := range result.Val() {
-		evt, err := GetEventById(redis, id)
-		if err != nil {
-			return nil, fmt.Errorf("get event by id: %w", err)
-		}
-
-		evts = append(evts, evt)
+	evts, err := GetEventsByIds(redis, result.Val())
+	if err != nil {
+		return nil, fmt.Errorf("get events by id: %w", err)
 	}
 
 	return evts, nil
 }
 
-func GetEventsForDateRange(redis *redis.Client, r util.DateRange) ([]Event, error) {
+// GetEventsForDateRange returns the events that start within the range, inclusive of both ends. The weekly
+// indexes and the events are each read in a single round trip.
+func GetEventsForDateRange(r *redis.Client, dates util.DateRange) ([]Event, error) {
+	weeks := util.DateRange{
+		Begin: util.BeginningOfWeek(dates.Begin.UTC()),
+		End:   dates.End,
+	}
+
+	var cmds []*redis.StringSliceCmd
+	pipe := r.Pipeline()
+	err := util.ForEachWeek(weeks, func(d time.Time) error {
+		cmds = append(cmds, pipe.SMembers(EventIndexKeyForDate(d)))
+		return nil
+	})
+	if err != nil {
+		pipe.Close()
+		return nil, err
+	}
+
+	_, err = pipe.Exec()
+	if err != nil {
+		return nil, fmt.Errorf("get events from index: %w", err)
+	}
+
+	ids := []string{}
+	for _, cmd := range cmds {
+		ids = append(ids, cmd.Val()...)
+	}
+
+	evts, err := GetEventsByIds(r, ids)
+	if err != nil {
+		return nil, fmt.Errorf("get events by id: %w", err)
+	}
+
 	ret := []Event{}
-	err := util.ForEachWeek(r, func(d time.Time) error {
-		evts, err := GetEventsForWeek(redis, d)
-		if err != nil {
-			return fmt.Errorf("get events for week: %w", err)
+	for _, evt := range evts {
+		if !evt.Time.Before(dates.Begin) && !evt.Time.After(dates.End) {
+			ret = append(ret, evt)
 		}
+	}
 
-		for _, evt := range evts {
-			if evt.Time.After(r.Begin) && evt.Time.Before(r.End) {
-				ret = append(ret, evt)
-			}
-		}
+	return ret, nil
+}
 
-		return nil
+// GetAnnouncedEvents returns the events from the start of today to the end of the scheduling horizon that
+// have an announcement posted
+func GetAnnouncedEvents(r *redis.Client, now time.Time) ([]Event, error) {
+	now = now.UTC()
+	evts, err := GetEventsForDateRange(r, util.DateRange{
+		Begin: util.BeginningOfDay(now),
+		End:   util.EndOfWeek(now.AddDate(0, 0, 7*DefaultWeeksAhead)),
 	})
+	if err != nil {
+		return nil, fmt.Errorf("get upcoming events: %w", err)
+	}
+
+	announced := []Event{}
+	for _, evt := range evts {
+		if evt.AnnounceChannelID != "" && evt.AnnounceMessageID != "" {
+			announced = append(announced, evt)
+		}
+	}
 
-	return ret, err
+	return announced, nil
 }
 
-func AnnounceEvent(session *discordgo.Session, redis *redis.Client, evt Event) error {
-	embed, err := GetEmbedForEvent(session, redis, evt)
+// AnnounceEvent posts an announcement for the event, or updates the existing announcement. Announcements carry
+// buttons to change attendance, reactions are kept as a fallback and only added to the message when the bot has
+// not already added them. When the announcement has been deleted a new one is posted in its place.
+func AnnounceEvent(messenger discord.Messenger, redis *redis.Client, settings Settings, evt Event) error {
+	embed, err := GetEmbedForEvent(messenger, redis, settings, evt)
 	if err != nil {
-		log.Error(err)
+		return fmt.Errorf("get embed: %w", err)
 	}
 
+	template, err := GetRecurringEventForEvent(redis, evt)
+	if err != nil {
+		return fmt.Errorf("get reactions: %w", err)
+	}
+
+	components := AnnouncementComponents(template.ReactionMappings())
+	var msg *discordgo.Message
 	if evt.AnnounceChannelID != "" && evt.AnnounceMessageID != "" {
 		log.WithFields(log.Fields{
 			"id":         evt.ID,
@@ -168,19 +321,33 @@ func AnnounceEvent(session *discordgo.Session, redis *redis.Client, evt Event) e
 			"channel_id": evt.AnnounceChannelID,
 		}).Debug("update announce message for event")
 
-		_, err = session.ChannelMessageEditEmbed(evt.AnnounceChannelID, evt.AnnounceMessageID, embed)
-		if err != nil {
-			return fmt.Errorf("update announce message embed")
+		msg, err = messenger.EditEmbedWithComponents(evt.AnnounceChannelID, evt.AnnounceMessageID, embed, components)
+		if discord.IsUnknownMessage(err) {
+			log.WithFields(log.Fields{
+				"id":         evt.ID,
+				"message_id": evt.AnnounceMessageID,
+			}).Warn("announce message was deleted, posting a new one")
+			evt, err = ClearAnnouncement(redis, evt)
+			if err != nil {
+				return fmt.Errorf("clear deleted announcement: %w", err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("update announce message embed: %w", err)
+		} else {
+			metrics.AnnouncementsTotal.Inc("edit")
 		}
-	} else {
+	}
+
+	if evt.AnnounceMessageID == "" {
 		log.WithFields(log.Fields{
 			"id": evt.ID,
 		}).Info("create new announcement for event")
-		msg, err := session.ChannelMessageSendEmbed(evt.AnnounceChannelID, embed)
+		msg, err = messenger.SendEmbedWithComponents(evt.AnnounceChannelID, embed, components)
 		if err != nil {
 			return fmt.Errorf("create announcement message: %w", err)
 		}
 
+		metrics.AnnouncementsTotal.Inc("create")
 		evt.AnnounceMessageID = msg.ID
 		err = ScheduleEvent(redis, evt)
 		if err != nil {
@@ -188,115 +355,183 @@ func AnnounceEvent(session *discordgo.Session, redis *redis.Client, evt Event) e
 		}
 	}
 
-	err = session.MessageReactionAdd(evt.AnnounceChannelID, evt.AnnounceMessageID, "❌")
-	if err != nil {
-		return fmt.Errorf("add reaction: %w", err)
+	emojis := []string{}
+	for _, m := range template.ReactionMappings() {
+		emojis = append(emojis, m.Emoji)
 	}
 
-	err = session.MessageReactionAdd(evt.AnnounceChannelID, evt.AnnounceMessageID, "🕘")
-	if err != nil {
-		return fmt.Errorf("add reaction: %w", err)
+	for _, emoji := range MissingReactions(msg, emojis) {
+		err = messenger.AddReaction(evt.AnnounceChannelID, evt.AnnounceMessageID, emoji)
+		if err != nil {
+			return fmt.Errorf("add reaction: %w", err)
+		}
 	}
 
 	return nil
 }
 
-func FormattedUserList(session *discordgo.Session, redis *redis.Client, ids []string) (string, error) {
+// ClearAnnouncement forgets the event's announcement after it was deleted, so that the next call to
+// AnnounceEvent posts a new one. Attendance is kept with the event and carries over to the new announcement.
+func ClearAnnouncement(r *redis.Client, evt Event) (Event, error) {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HSet(EventKeyForID(evt.ID), "announce_message_id", "")
+		pipe.Del(ReactionsKeyForEventId(evt.ID))
+		if evt.AnnounceChannelID != "" && evt.AnnounceMessageID != "" {
+			pipe.Del(EventIndexKeyForMessageId(evt.AnnounceChannelID, evt.AnnounceMessageID))
+		}
+		return nil
+	})
+	if err != nil {
+		return Event{}, fmt.Errorf("clear announcement: %w", err)
+	}
+
+	evt.AnnounceMessageID = ""
+	return evt, nil
+}
+
+// MissingReactions returns the emoji that the bot has not yet reacted to the message with
+func MissingReactions(msg *discordgo.Message, emojis []string) []string {
+	missing := []string{}
+	for _, emoji := range emojis {
+		found := false
+		if msg != nil {
+			for _, r := range msg.Reactions {
+				if r.Me && r.Emoji != nil && r.Emoji.APIName() == emoji {
+					found = true
+					break
+				}
+			}
+		}
+
+		if !found {
+			missing = append(missing, emoji)
+		}
+	}
+
+	return missing
+}
+
+// FormattedUserList returns the aliases of the users on one of the attendance lists, one per line. Users put on
+// the list by another member are annotated with that member's alias and users with a main character with its
+// specialization.
+func FormattedUserList(aliases map[string]string, attendance Attendance, t UserListType, mains map[string]roster.Character) string {
+	ids := attendance.List(t)
 	if len(ids) == 0 {
-		return "No one 👍", nil
+		return "No one 👍"
 	}
 
 	result := ""
 	for _, id := range ids {
-		alias, err := GetUserAlias(redis, session, id)
-		if err != nil {
-			return "", fmt.Errorf("get user alias: %w", err)
+		alias := aliases[id]
+		if main, ok := mains[id]; ok {
+			alias = fmt.Sprintf("%s · %s", alias, main.Description())
+		}
+
+		if actor := attendance.SetByFor(t, id); actor != "" {
+			alias = fmt.Sprintf("%s (by %s)", alias, aliases[actor])
 		}
 		result = result + alias + "\n"
 	}
 
-	return result, nil
+	return result
 }
 
-func GetEmbedForEvent(session *discordgo.Session, redis *redis.Client, evt Event) (*discordgo.MessageEmbed, error) {
+func GetEmbedForEvent(messenger discord.Messenger, redis *redis.Client, settings Settings, evt Event) (*discordgo.MessageEmbed, error) {
 	attendance, err := GetAttendanceForEvent(redis, evt)
 	if err != nil {
 		return nil, fmt.Errorf("get attendance for event: %w", err)
 	}
 
-	out, err := FormattedUserList(session, redis, attendance.Absent)
+	mains, err := roster.GetMainsFor(redis, attendance.UserIDs())
+	if err != nil {
+		return nil, fmt.Errorf("get main characters: %w", err)
+	}
+
+	composition, err := roster.GetComposition(redis)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases, err := GetUserAliases(redis, messenger, settings.GuildID, attendance.UserIDs())
+	if err != nil {
+		return nil, fmt.Errorf("get user aliases: %w", err)
+	}
+
+	template, err := GetRecurringEventForEvent(redis, evt)
 	if err != nil {
-		return nil, fmt.Errorf("format absent user list: %w", err)
+		return nil, fmt.Errorf("get embed settings: %w", err)
 	}
 
-	late, err := FormattedUserList(session, redis, attendance.Late)
+	mappings := template.ReactionMappings()
+	resolved := template.Embed.Resolve(settings.Theme)
+	name := evt.Name
+	if resolved.InstanceName != "" {
+		name = resolved.InstanceName
+	}
+
+	description, err := renderDescriptionOrDefault(resolved.Description, EmbedData{
+		ID:        evt.ID,
+		Name:      name,
+		Time:      evt.Time,
+		Date:      evt.Time.Format("Monday Jan _2 2006"),
+		Out:       len(attendance.Absent),
+		Late:      len(attendance.Late),
+		Tentative: len(attendance.Tentative),
+	})
 	if err != nil {
-		return nil, fmt.Errorf("format late user list: %w", err)
+		return nil, fmt.Errorf("render description: %w", err)
 	}
 
 	embed := discordgo.MessageEmbed{
 		Author: &discordgo.MessageEmbedAuthor{
-			Name: evt.Name,
+			Name: name,
 		},
-		Title:       "Sanctum of Domination",
-		Description: evt.Time.Format("Monday Jan _2 2006"),
+		Title:       resolved.Title,
+		Description: description,
+		Color:       resolved.Color,
 		Thumbnail: &discordgo.MessageEmbedThumbnail{
-			URL: "https://wow.zamimg.com/images/wow/icons/large/achievement_raid_torghastraid.jpg",
-		},
-		Fields: []*discordgo.MessageEmbedField{
-			{
-				Name:   "❌ Out",
-				Value:  out,
-				Inline: true,
-			},
-			{
-				Name:   "🕘 Late",
-				Value:  late,
-				Inline: true,
-			},
-			{
-				Name:  "Instructions",
-				Value: "React with 🕘 to mark yourself late or ❌ for out",
-			},
+			URL: resolved.ThumbnailURL,
 		},
 		Footer: &discordgo.MessageEmbedFooter{
 			Text: fmt.Sprintf("id: %s", evt.ID),
 		},
 	}
 
-	return &embed, nil
-}
-
-func UserAliasKey(userID string) string {
-	return fmt.Sprintf("alias:%s", userID)
-}
+	for _, t := range UserListTypes {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:   resolved.Label(t, mappings),
+			Value:  FormattedUserList(aliases, attendance, t, mains),
+			Inline: true,
+		})
+	}
 
-func SetUserAlias(redis *redis.Client, userID string, alias string) error {
-	key := UserAliasKey(userID)
-	result := redis.Set(key, alias, 0)
-	if result.Err() != nil {
-		return fmt.Errorf("redis write: %w", result.Err())
+	// The composition counts the main characters of everyone who is not out
+	if len(composition) > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "Composition",
+			Value: composition.Without(mains, attendance.Absent).String(),
+		})
 	}
 
-	return nil
-}
+	embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+		Name:  "Instructions",
+		Value: fmt.Sprintf("Use the buttons below. %s", ReactionInstructions(mappings)),
+	})
 
-func GetUserAlias(r *redis.Client, session *discordgo.Session, userID string) (string, error) {
-	key := UserAliasKey(userID)
-	result := r.Get(key)
-	if result.Err() == nil {
-		return result.Val(), nil
-	}
+	return &embed, nil
+}
 
-	user, err := session.User(userID)
-	if err != nil {
-		return "", fmt.Errorf("query user from discord: %w", err)
+// ReactionInstructions explains which reactions change attendance, for example "React with ❌ for out or 🕘 for
+// late"
+func ReactionInstructions(mappings []ReactionMapping) string {
+	parts := make([]string, len(mappings))
+	for i, m := range mappings {
+		parts[i] = fmt.Sprintf("%s for %s", DisplayEmoji(m.Emoji), strings.ToLower(m.List.Title()))
 	}
 
-	err = SetUserAlias(r, userID, user.Username)
-	if err != nil {
-		return "", fmt.Errorf("set user alias: %w", err)
+	if len(parts) < 2 {
+		return "React with " + strings.Join(parts, "")
 	}
 
-	return user.Username, nil
+	return fmt.Sprintf("React with %s or %s", strings.Join(parts[:len(parts)-1], ", "), parts[len(parts)-1])
 }
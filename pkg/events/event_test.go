@@ -1,7 +1,315 @@
 package events
 
-import "testing"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+)
+
+// testSettings renders announcements with the default theme and without guild nicknames
+var testSettings = Settings{Theme: DefaultEmbedTheme}
 
 func TestUpsertEvent(t *testing.T) {
 
 }
+
+func TestCreateEvent(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	evt := Event{
+		ID:               "raid-2021-08-25",
+		Name:             "Raid",
+		Time:             time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		RecurringEventID: "raid",
+	}
+	err = CreateEvent(client, evt)
+	if err != nil {
+		t.Error(err)
+	}
+
+	evt.Name = "Changed"
+	err = CreateEvent(client, evt)
+	if !errors.Is(err, ErrEventExists) {
+		t.Errorf("expected ErrEventExists got '%v'", err)
+	}
+
+	stored, err := GetEventById(client, evt.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if stored.Name != "Raid" || stored.Status != Scheduled || !stored.Time.Equal(evt.Time) {
+		t.Errorf("event was not stored correctly, got '%v'", stored)
+	}
+
+	isMember, err := svc.SIsMember(EventIndexKeyForDate(evt.Time), evt.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !isMember {
+		t.Error("did not add event to index")
+	}
+}
+
+func TestScheduleEventsForWeekConcurrently(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	err = UpsertRecurringEvent(client, RecurringEvent{
+		ID:       "raid",
+		Name:     "Raid",
+		Weekdays: []time.Weekday{time.Wednesday},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	week := time.Date(2021, 8, 22, 0, 0, 0, 0, time.UTC)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := ScheduleEventsForWeek(client, week)
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	evts, err := GetEventsForWeek(client, week)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(evts) != 1 {
+		t.Fatalf("expected 1 event got %d", len(evts))
+	}
+
+	if evts[0].ID != "raid-2021-08-25" {
+		t.Errorf("expected deterministic id got '%s'", evts[0].ID)
+	}
+}
+
+func TestGetEmbedForEventRoundTrips(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	trips := 0
+	client.WrapProcess(func(old func(redis.Cmder) error) func(redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			trips++
+			return old(cmd)
+		}
+	})
+	client.WrapProcessPipeline(func(old func([]redis.Cmder) error) func([]redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			trips++
+			return old(cmds)
+		}
+	})
+
+	fake := discord.NewFake()
+	render := func(size int) int {
+		t.Helper()
+		evt := Event{
+			ID:   fmt.Sprintf("raid-%d", size),
+			Name: "Raid",
+			Time: time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		}
+		err := CreateEvent(client, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		members := make([]*discordgo.Member, size)
+		for i := range members {
+			members[i] = &discordgo.Member{User: &discordgo.User{ID: fmt.Sprintf("user-%d-%d", size, i)}, Nick: fmt.Sprintf("Member %d", i)}
+			list := Absent
+			if i%2 == 0 {
+				list = Late
+			}
+			err = EventUserListAdd(client, evt, members[i].User.ID, list)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		_, err = RefreshMemberNicknames(client, members)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		trips = 0
+		embed, err := GetEmbedForEvent(fake, client, testSettings, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !strings.Contains(embed.Fields[0].Value+embed.Fields[1].Value, fmt.Sprintf("Member %d", size-1)) {
+			t.Errorf("expected every member to be listed got '%v'", embed.Fields)
+		}
+
+		return trips
+	}
+
+	small := render(5)
+	large := render(25)
+	if small != large {
+		t.Errorf("expected a constant number of round trips, got %d for 5 members and %d for 25", small, large)
+	}
+}
+
+func TestAnnounceEventRepostsDeletedAnnouncement(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	fake.AddUser(&discordgo.User{ID: "user", Username: "User"})
+	evt := Event{
+		ID:                "raid",
+		Name:              "Raid",
+		Time:              time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		AnnounceChannelID: "announcements",
+	}
+	err = ScheduleEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = AnnounceEvent(fake, client, testSettings, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := GetEventById(client, evt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = EventUserListAdd(client, deleted, "user", Absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.DeleteMessage(deleted.AnnounceMessageID)
+	err = AnnounceEvent(fake, client, testSettings, deleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reposted, err := GetEventById(client, evt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reposted.AnnounceMessageID == "" || reposted.AnnounceMessageID == deleted.AnnounceMessageID {
+		t.Fatalf("expected a new announcement got '%v'", reposted.AnnounceMessageID)
+	}
+
+	_, err = GetEventByMessage(client, deleted.AnnounceChannelID, deleted.AnnounceMessageID)
+	if !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("expected ErrEventNotFound for the deleted message got '%v'", err)
+	}
+
+	found, err := GetEventByMessage(client, reposted.AnnounceChannelID, reposted.AnnounceMessageID)
+	if err != nil || found.ID != evt.ID {
+		t.Errorf("expected '%v' for the new message got '%v' '%v'", evt.ID, found.ID, err)
+	}
+
+	embed := fake.Messages[reposted.AnnounceMessageID].Embeds[0]
+	if !strings.Contains(embed.Fields[0].Value, "User") {
+		t.Errorf("expected attendance to carry over got '%v'", embed.Fields[0].Value)
+	}
+}
+
+func TestGetEventsForDateRange(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	saturday := time.Date(2021, 8, 28, 0, 0, 0, 0, time.UTC)
+	monday := time.Date(2021, 8, 30, 0, 0, 0, 0, time.UTC)
+	for _, evt := range []Event{
+		{ID: "before", Time: saturday.Add(-time.Second)},
+		{ID: "begin", Time: saturday},
+		{ID: "next-week", Time: monday.Add(19 * time.Hour)},
+		{ID: "end", Time: util.EndOfDay(monday)},
+		{ID: "after", Time: monday.AddDate(0, 0, 1)},
+	} {
+		err = ScheduleEvent(client, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	evts, err := GetEventsForDateRange(client, util.DateRange{Begin: saturday, End: util.EndOfDay(monday)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]bool{}
+	for _, evt := range evts {
+		found[evt.ID] = true
+	}
+
+	cases := []struct {
+		id  string
+		exp bool
+	}{
+		{"before", false},
+		{"begin", true},
+		{"next-week", true},
+		{"end", true},
+		{"after", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.id, func(t *testing.T) {
+			if found[c.id] != c.exp {
+				t.Errorf("expected found '%v' got '%v'", c.exp, found[c.id])
+			}
+		})
+	}
+}
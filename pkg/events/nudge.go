@@ -0,0 +1,76 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/go-redis/redis"
+)
+
+// NudgeLead is how long before an event that its tentative members are asked to confirm
+const NudgeLead = 24 * time.Hour
+
+// NudgeKeyForEventId holds the members who have been asked to confirm whether they can attend the event
+func NudgeKeyForEventId(id string) string {
+	return fmt.Sprintf("event:%s:nudged", id)
+}
+
+// EventsDueForNudge returns the scheduled events starting within NudgeLead of now
+func EventsDueForNudge(r *redis.Client, now time.Time) ([]Event, error) {
+	now = now.UTC()
+	evts, err := GetEventsForDateRange(r, util.DateRange{
+		Begin: now,
+		End:   now.Add(NudgeLead),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get upcoming events: %w", err)
+	}
+
+	due := []Event{}
+	for _, evt := range evts {
+		if evt.Status != Canceled {
+			due = append(due, evt)
+		}
+	}
+
+	return due, nil
+}
+
+// ClaimNudges records that the members are being asked to confirm whether they can attend the event. It returns
+// the members who had not been asked yet, so that each member is only asked once however many times the job runs.
+func ClaimNudges(r *redis.Client, evt Event, userIDs []string) ([]string, error) {
+	if len(userIDs) == 0 {
+		return []string{}, nil
+	}
+
+	key := NudgeKeyForEventId(evt.ID)
+	var kind *redis.StatusCmd
+	added := make([]*redis.IntCmd, len(userIDs))
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		kind = pipe.Type(key)
+		for i, id := range userIDs {
+			added[i] = pipe.SAdd(key, id)
+		}
+		pipe.Expire(key, EventTTL)
+		return nil
+	})
+
+	// Before members were claimed individually the key held the time every tentative member was asked
+	if kind.Val() == "string" {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("claim nudges: %w", err)
+	}
+
+	claimed := []string{}
+	for i, id := range userIDs {
+		if added[i].Val() == 1 {
+			claimed = append(claimed, id)
+		}
+	}
+
+	return claimed, nil
+}
@@ -0,0 +1,108 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestEventsDueForNudge(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	now := time.Date(2021, 8, 24, 6, 0, 0, 0, time.UTC)
+	for _, evt := range []Event{
+		{ID: "past", Name: "Raid", Time: time.Date(2021, 8, 24, 0, 0, 0, 0, time.UTC)},
+		{ID: "tomorrow", Name: "Raid", Time: time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)},
+		{ID: "later", Name: "Raid", Time: time.Date(2021, 8, 26, 0, 0, 0, 0, time.UTC)},
+	} {
+		err = CreateEvent(client, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	due, err := EventsDueForNudge(client, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(due) != 1 || due[0].ID != "tomorrow" {
+		t.Fatalf("expected only 'tomorrow' to be due got '%v'", due)
+	}
+
+	claimed, err := ClaimNudges(client, due[0], []string{"first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(claimed, []string{"first"}) {
+		t.Errorf("expected the first claim to succeed got '%v'", claimed)
+	}
+
+	claimed, err = ClaimNudges(client, due[0], []string{"first", "second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(claimed, []string{"second"}) {
+		t.Errorf("expected only the member who became tentative later to be nudged got '%v'", claimed)
+	}
+
+	svc.Set(NudgeKeyForEventId("later"), "1629784800")
+	claimed, err = ClaimNudges(client, Event{ID: "later"}, []string{"first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(claimed) != 0 {
+		t.Errorf("expected events nudged before members were claimed individually to be skipped got '%v'", claimed)
+	}
+}
+
+func TestConfirmTentative(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	evt := Event{ID: "raid", Name: "Raid", Time: time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC)}
+	err = CreateEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UserListAdd(client, evt.Time, "user", Tentative)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = UserListAdd(client, evt.Time, "user", Late)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, attendance := range []func() (Attendance, error){
+		func() (Attendance, error) { return GetAttendanceForDay(client, evt.Time) },
+		func() (Attendance, error) { return GetAttendanceForEvent(client, evt) },
+	} {
+		a, err := attendance()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(a.Tentative) != 0 || len(a.Late) != 1 {
+			t.Errorf("expected marking late to confirm a tentative member got '%v'", a)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package events
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+// Plan lists the work needed to bring the schedule up to date. Schedule holds instances of recurring events
+// that have not been created yet and Announce holds instances that are due to be announced but have no
+// announcement message.
+type Plan struct {
+	Schedule []Event
+	Announce []Event
+}
+
+// PlanSchedule computes the instances and announcements missing for every recurring event within its
+// scheduling horizon. Instances on days before now are never planned. Planning does not modify storage so it
+// is safe to run repeatedly, an up to date schedule produces an empty plan.
+func PlanSchedule(redis *redis.Client, now time.Time) (Plan, error) {
+	now = now.UTC()
+	today := util.BeginningOfDay(now)
+	templates, err := GetRecurringEvents(redis)
+	if err != nil {
+		return Plan{}, fmt.Errorf("get recurring events: %w", err)
+	}
+
+	plan := Plan{}
+	existingByWeek := map[int64][]Event{}
+	for _, template := range templates {
+		for i := 0; i < template.Horizon(); i++ {
+			week := util.BeginningOfWeek(now).AddDate(0, 0, 7*i)
+			existing, ok := existingByWeek[week.Unix()]
+			if !ok {
+				existing, err = GetEventsForWeek(redis, week)
+				if err != nil {
+					return Plan{}, fmt.Errorf("get existing events: %w", err)
+				}
+
+				existingByWeek[week.Unix()] = existing
+			}
+
+			evts, err := WeeklyEventsForRecurringEvent(template, week)
+			if err != nil {
+				return Plan{}, fmt.Errorf("get weekly events: %w", err)
+			}
+
+			for _, evt := range evts {
+				if evt.Time.Before(today) {
+					continue
+				}
+
+				instance, ok := FindEventForRecurringEvent(existing, template.ID, evt.Time)
+				if !ok {
+					plan.Schedule = append(plan.Schedule, evt)
+					instance = evt
+				}
+
+				if instance.AnnounceMessageID == "" && !now.Before(instance.Time.Add(-template.AnnounceLead())) {
+					plan.Announce = append(plan.Announce, instance)
+				}
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// ExecutePlan schedules the missing instances of a plan. Announcements are left to the caller as they require
+// a Discord session.
+func ExecutePlan(redis *redis.Client, plan Plan) error {
+	if len(plan.Schedule) == 0 {
+		return nil
+	}
+
+	rules, err := GetAttendanceRules(redis)
+	if err != nil {
+		return fmt.Errorf("get attendance rules: %w", err)
+	}
+
+	for _, evt := range plan.Schedule {
+		err := ScheduleInstance(redis, evt, rules)
+		if err != nil {
+			return fmt.Errorf("schedule instance: %w", err)
+		}
+	}
+
+	log.WithField("count", len(plan.Schedule)).Info("scheduled missing instances")
+	return nil
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestPlanSchedule(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	err = UpsertRecurringEvent(client, RecurringEvent{
+		ID:                 "raid",
+		Name:               "Raid",
+		Weekdays:           []time.Weekday{time.Monday, time.Wednesday},
+		WeeksAhead:         2,
+		AnnounceDaysBefore: 3,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// Tuesday, the Monday instance of the current week has already passed
+	now := time.Date(2021, 8, 24, 12, 0, 0, 0, time.UTC)
+	plan, err := PlanSchedule(client, now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []time.Time{
+		time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		time.Date(2021, 8, 30, 0, 0, 0, 0, time.UTC),
+		time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC),
+	}
+	if len(plan.Schedule) != len(expected) {
+		t.Fatalf("expected %d instances to be planned, got %d", len(expected), len(plan.Schedule))
+	}
+
+	for i, evt := range plan.Schedule {
+		if !evt.Time.Equal(expected[i]) {
+			t.Errorf("expected instance at '%s' got '%s'", expected[i], evt.Time)
+		}
+	}
+
+	if len(plan.Announce) != 1 || !plan.Announce[0].Time.Equal(expected[0]) {
+		t.Errorf("expected only the instance within 3 days to be announced, got '%v'", plan.Announce)
+	}
+
+	err = ExecutePlan(client, plan)
+	if err != nil {
+		t.Error(err)
+	}
+
+	plan, err = PlanSchedule(client, now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(plan.Schedule) != 0 {
+		t.Errorf("expected no instances to be planned after executing, got '%v'", plan.Schedule)
+	}
+
+	if len(plan.Announce) != 1 {
+		t.Fatalf("expected the scheduled instance to still need an announcement, got '%v'", plan.Announce)
+	}
+
+	evt := plan.Announce[0]
+	evt.AnnounceChannelID = "channel"
+	evt.AnnounceMessageID = "message"
+	err = ScheduleEvent(client, evt)
+	if err != nil {
+		t.Error(err)
+	}
+
+	plan, err = PlanSchedule(client, now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(plan.Schedule) != 0 || len(plan.Announce) != 0 {
+		t.Errorf("expected an empty plan, got '%v'", plan)
+	}
+}
+
+func TestPlanScheduleDefaults(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	err = UpsertRecurringEvent(client, RecurringEvent{
+		ID:       "raid",
+		Name:     "Raid",
+		Weekdays: []time.Weekday{time.Sunday},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	now := time.Date(2021, 8, 22, 0, 0, 0, 0, time.UTC)
+	plan, err := PlanSchedule(client, now)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(plan.Schedule) != DefaultWeeksAhead {
+		t.Errorf("expected %d instances, got %d", DefaultWeeksAhead, len(plan.Schedule))
+	}
+
+	// Instances exactly DefaultAnnounceDaysBefore days away are due
+	if len(plan.Announce) != 2 {
+		t.Errorf("expected 2 announcements, got %d", len(plan.Announce))
+	}
+}
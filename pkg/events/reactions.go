@@ -0,0 +1,181 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-redis/redis"
+)
+
+var ErrUnknownUserList = errors.New("unknown attendance list")
+var ErrInvalidReaction = errors.New("invalid reaction")
+
+// ReactionMapping puts members who react to an announcement with Emoji on the List. Emoji is either a unicode
+// emoji or a custom guild emoji in the form "name:id".
+type ReactionMapping struct {
+	Emoji string
+	List  UserListType
+}
+
+// DefaultReactions are used for events whose recurring event does not define its own
+var DefaultReactions = []ReactionMapping{
+	{Emoji: "❌", List: Absent},
+	{Emoji: "🕘", List: Late},
+	{Emoji: "❔", List: Tentative},
+}
+
+// ParseUserListType returns the list with the name, "out" is accepted for Absent and "maybe" for Tentative
+func ParseUserListType(s string) (UserListType, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "out":
+		return Absent, nil
+	case "maybe":
+		return Tentative, nil
+	}
+
+	for _, t := range UserListTypes {
+		if string(t) == s {
+			return t, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w '%s'", ErrUnknownUserList, s)
+}
+
+// ParseEmoji normalizes an emoji to the form used by reactions. Custom guild emoji may be given as they appear
+// in messages, "<:name:id>" or "<a:name:id>", or as "name:id".
+func ParseEmoji(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">") {
+		s = strings.TrimPrefix(strings.TrimPrefix(strings.Trim(s, "<>"), "a:"), ":")
+	}
+
+	if s == "" || strings.ContainsAny(s, " ,=<>") {
+		return "", fmt.Errorf("%w '%s'", ErrInvalidReaction, s)
+	}
+
+	if parts := strings.Split(s, ":"); len(parts) > 1 && (len(parts) != 2 || parts[0] == "" || !isSnowflake(parts[1])) {
+		return "", fmt.Errorf("%w '%s', custom emoji are given as name:id", ErrInvalidReaction, s)
+	}
+
+	return s, nil
+}
+
+func isSnowflake(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DisplayEmoji returns the emoji as it is written in a message, custom emoji are written as "<:name:id>"
+func DisplayEmoji(emoji string) string {
+	if strings.Contains(emoji, ":") {
+		return fmt.Sprintf("<:%s>", emoji)
+	}
+
+	return emoji
+}
+
+// ListForReaction returns the list the reaction puts members on
+func ListForReaction(mappings []ReactionMapping, emoji string) (UserListType, bool) {
+	for _, m := range mappings {
+		if m.Emoji == emoji {
+			return m.List, true
+		}
+	}
+
+	return "", false
+}
+
+// ReactionForList returns the first emoji that puts members on the list
+func ReactionForList(mappings []ReactionMapping, t UserListType) (string, bool) {
+	for _, m := range mappings {
+		if m.List == t {
+			return m.Emoji, true
+		}
+	}
+
+	return "", false
+}
+
+// ValidateReactions checks that every mapping has a valid emoji and list and that no emoji is mapped twice
+func ValidateReactions(mappings []ReactionMapping) error {
+	seen := map[string]bool{}
+	for _, m := range mappings {
+		if _, err := ParseEmoji(m.Emoji); err != nil {
+			return err
+		}
+
+		if _, err := ParseUserListType(string(m.List)); err != nil {
+			return err
+		}
+
+		if seen[m.Emoji] {
+			return fmt.Errorf("%w '%s' is mapped more than once", ErrInvalidReaction, m.Emoji)
+		}
+		seen[m.Emoji] = true
+	}
+
+	return nil
+}
+
+func serializeReactions(mappings []ReactionMapping) string {
+	parts := make([]string, len(mappings))
+	for i, m := range mappings {
+		parts[i] = fmt.Sprintf("%s=%s", m.Emoji, m.List)
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func deserializeReactions(s string) ([]ReactionMapping, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(s, ",")
+	mappings := make([]ReactionMapping, len(parts))
+	for i, part := range parts {
+		pair := strings.SplitN(part, "=", 2)
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("%w '%s'", ErrInvalidReaction, part)
+		}
+
+		t, err := ParseUserListType(pair[1])
+		if err != nil {
+			return nil, err
+		}
+
+		mappings[i] = ReactionMapping{Emoji: pair[0], List: t}
+	}
+
+	return mappings, nil
+}
+
+// GetRecurringEventForEvent returns the recurring event that the event is an instance of. Events created
+// without one, or whose recurring event has been removed, are given an empty recurring event so that the
+// defaults apply.
+func GetRecurringEventForEvent(r *redis.Client, evt Event) (RecurringEvent, error) {
+	if evt.RecurringEventID == "" {
+		return RecurringEvent{}, nil
+	}
+
+	template, err := GetRecurringEventById(r, evt.RecurringEventID)
+	if errors.Is(err, ErrUnknownRecurringEvent) {
+		return RecurringEvent{}, nil
+	} else if err != nil {
+		return RecurringEvent{}, fmt.Errorf("get recurring event: %w", err)
+	}
+
+	return template, nil
+}
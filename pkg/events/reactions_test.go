@@ -0,0 +1,71 @@
+package events
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseEmoji(t *testing.T) {
+	cases := []struct {
+		name   string
+		input  string
+		exp    string
+		expErr error
+	}{
+		{"unicode", "❌", "❌", nil},
+		{"custom", "tentative:123456", "tentative:123456", nil},
+		{"custom from message", "<:tentative:123456>", "tentative:123456", nil},
+		{"animated custom from message", "<a:tentative:123456>", "tentative:123456", nil},
+		{"custom without id", "tentative:", "", ErrInvalidReaction},
+		{"custom with invalid id", "tentative:abc", "", ErrInvalidReaction},
+		{"empty", "", "", ErrInvalidReaction},
+		{"separator", "❌=late", "", ErrInvalidReaction},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			emoji, err := ParseEmoji(c.input)
+			if !errors.Is(err, c.expErr) {
+				t.Fatalf("expected '%v' got '%v'", c.expErr, err)
+			}
+
+			if emoji != c.exp {
+				t.Errorf("expected '%v' got '%v'", c.exp, emoji)
+			}
+		})
+	}
+}
+
+func TestReactionMappings(t *testing.T) {
+	mappings := []ReactionMapping{
+		{Emoji: "tentative:123456", List: Late},
+		{Emoji: "🚫", List: Absent},
+	}
+
+	decoded, err := deserializeReactions(serializeReactions(mappings))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded) != len(mappings) || decoded[0] != mappings[0] || decoded[1] != mappings[1] {
+		t.Errorf("expected '%v' got '%v'", mappings, decoded)
+	}
+
+	list, ok := ListForReaction(decoded, "tentative:123456")
+	if !ok || list != Late {
+		t.Errorf("expected '%v' got '%v'", Late, list)
+	}
+
+	if _, ok := ListForReaction(decoded, "❌"); ok {
+		t.Error("expected unmapped reactions to be ignored")
+	}
+
+	expected := "React with <:tentative:123456> for late or 🚫 for out"
+	if instructions := ReactionInstructions(decoded); instructions != expected {
+		t.Errorf("expected '%s' got '%s'", expected, instructions)
+	}
+
+	if label := (EmbedSettings{}).Label(Late, decoded); label != "<:tentative:123456> Late" {
+		t.Errorf("expected '<:tentative:123456> Late' got '%s'", label)
+	}
+}
@@ -0,0 +1,167 @@
+package events
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/go-redis/redis"
+)
+
+// ReactionsKeyForEventId holds the reactions to the event's announcement that the bot has applied to its
+// attendance, each member as "<user id>|<emoji>"
+func ReactionsKeyForEventId(id string) string {
+	return fmt.Sprintf("event:%s:reactions", id)
+}
+
+// reactionsSeeded is a member of the reactions set once it holds every reaction to the current announcement,
+// it is never a reaction as it has no separator
+const reactionsSeeded = "seeded"
+
+func reactionMember(userID string, emoji string) string {
+	return fmt.Sprintf("%s|%s", userID, emoji)
+}
+
+// ObserveReaction records that a reaction to the event's announcement was added or removed and applied to its
+// attendance, so that ReconcileReactions does not apply it again
+func ObserveReaction(r *redis.Client, evt Event, userID string, emoji string, added bool) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		if added {
+			pipe.SAdd(ReactionsKeyForEventId(evt.ID), reactionMember(userID, emoji))
+		} else {
+			pipe.SRem(ReactionsKeyForEventId(evt.ID), reactionMember(userID, emoji))
+		}
+		pipe.Expire(ReactionsKeyForEventId(evt.ID), EventTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("observe reaction: %w", err)
+	}
+
+	return nil
+}
+
+// Discrepancy is a reaction to an announcement that was added or removed without the bot applying it to the
+// event's attendance
+type Discrepancy struct {
+	UserID  string
+	Emoji   string
+	List    UserListType
+	Removed bool
+}
+
+// ReconcileReactions applies the reactions to the event's announcement that were added or removed while the
+// bot was not connected, and returns them. Reactions that were already applied are left alone, even when the
+// member has since changed their attendance with a command or a button. The bot's own reactions are ignored.
+// The first time an announcement is reconciled its current reactions are only recorded, as there is no way
+// to tell which of them were already applied.
+func ReconcileReactions(messenger discord.Messenger, r *redis.Client, evt Event, botID string) ([]Discrepancy, error) {
+	template, err := GetRecurringEventForEvent(r, evt)
+	if err != nil {
+		return nil, err
+	}
+
+	observed, err := r.SMembers(ReactionsKeyForEventId(evt.ID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get observed reactions: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, member := range observed {
+		seen[member] = true
+	}
+
+	if !seen[reactionsSeeded] {
+		return []Discrepancy{}, seedReactions(messenger, r, evt, botID, template)
+	}
+
+	changed := []Discrepancy{}
+	for _, m := range template.ReactionMappings() {
+		userIDs, err := messenger.ReactionUsers(evt.AnnounceChannelID, evt.AnnounceMessageID, m.Emoji)
+		if err != nil {
+			return nil, fmt.Errorf("get reactions: %w", err)
+		}
+
+		current := map[string]bool{}
+		for _, id := range userIDs {
+			if id == botID {
+				continue
+			}
+
+			current[id] = true
+			if seen[reactionMember(id, m.Emoji)] {
+				continue
+			}
+
+			err = EventUserListAdd(r, evt, id, m.List)
+			if err != nil {
+				return nil, err
+			}
+
+			err = ObserveReaction(r, evt, id, m.Emoji, true)
+			if err != nil {
+				return nil, err
+			}
+
+			changed = append(changed, Discrepancy{UserID: id, Emoji: m.Emoji, List: m.List})
+		}
+
+		for _, member := range observed {
+			id, emoji := splitReactionMember(member)
+			if emoji != m.Emoji || current[id] {
+				continue
+			}
+
+			err = EventUserListRemove(r, evt, id, m.List)
+			if err != nil {
+				return nil, err
+			}
+
+			err = ObserveReaction(r, evt, id, m.Emoji, false)
+			if err != nil {
+				return nil, err
+			}
+
+			changed = append(changed, Discrepancy{UserID: id, Emoji: m.Emoji, List: m.List, Removed: true})
+		}
+	}
+
+	return changed, nil
+}
+
+// seedReactions records the current reactions to the event's announcement as applied
+func seedReactions(messenger discord.Messenger, r *redis.Client, evt Event, botID string, template RecurringEvent) error {
+	members := []interface{}{reactionsSeeded}
+	for _, m := range template.ReactionMappings() {
+		userIDs, err := messenger.ReactionUsers(evt.AnnounceChannelID, evt.AnnounceMessageID, m.Emoji)
+		if err != nil {
+			return fmt.Errorf("get reactions: %w", err)
+		}
+
+		for _, id := range userIDs {
+			if id != botID {
+				members = append(members, reactionMember(id, m.Emoji))
+			}
+		}
+	}
+
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.SAdd(ReactionsKeyForEventId(evt.ID), members...)
+		pipe.Expire(ReactionsKeyForEventId(evt.ID), EventTTL)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("seed reactions: %w", err)
+	}
+
+	return nil
+}
+
+func splitReactionMember(member string) (string, string) {
+	parts := strings.SplitN(member, "|", 2)
+	if len(parts) != 2 {
+		return member, ""
+	}
+
+	return parts[0], parts[1]
+}
@@ -0,0 +1,144 @@
+package events
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestReconcileReactions(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	evt := Event{
+		ID:                "raid",
+		Name:              "Raid",
+		Time:              time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		AnnounceChannelID: "announcements",
+	}
+	err = ScheduleEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = AnnounceEvent(fake, client, testSettings, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	evt, err = GetEventById(client, evt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A member reacted before the announcement was first reconciled and then changed their mind with a command
+	fake.AddUserReaction(evt.AnnounceMessageID, "❌", "changed")
+	err = EventUserListAdd(client, evt, "changed", Absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = EventUserListSet(client, evt, "changed", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := ReconcileReactions(fake, client, evt, "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changed) != 0 {
+		t.Errorf("expected existing reactions to be recorded without being applied got '%v'", changed)
+	}
+
+	// Reactions handled while the bot was connected
+	react := func(userID string, emoji string, list UserListType) {
+		t.Helper()
+		fake.AddUserReaction(evt.AnnounceMessageID, emoji, userID)
+		err := EventUserListAdd(client, evt, userID, list)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = ObserveReaction(client, evt, userID, emoji, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	react("cleared", "❌", Absent)
+	react("gone", "🕘", Late)
+
+	// A member who reacted and then cleared their attendance with a button keeps their reaction
+	err = EventUserListSet(client, evt, "cleared", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = EventUserListAdd(client, evt, "unreacted", Late)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reactions changed while the bot was not connected
+	fake.RemoveUserReaction(evt.AnnounceMessageID, "🕘", "gone")
+	fake.AddUserReaction(evt.AnnounceMessageID, "❌", "bot")
+	fake.AddUserReaction(evt.AnnounceMessageID, "❌", "offline")
+	fake.AddUserReaction(evt.AnnounceMessageID, "❔", "unsure")
+
+	changed, err = ReconcileReactions(fake, client, evt, "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Discrepancy{
+		{UserID: "offline", Emoji: "❌", List: Absent},
+		{UserID: "gone", Emoji: "🕘", List: Late, Removed: true},
+		{UserID: "unsure", Emoji: "❔", List: Tentative},
+	}
+	if !reflect.DeepEqual(changed, exp) {
+		t.Errorf("expected '%v' got '%v'", exp, changed)
+	}
+
+	attendance, err := GetAttendanceForEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		list UserListType
+		exp  []string
+	}{
+		{Absent, []string{"offline"}},
+		{Late, []string{"unreacted"}},
+		{Tentative, []string{"unsure"}},
+	}
+
+	for _, c := range cases {
+		t.Run(string(c.list), func(t *testing.T) {
+			if got := attendance.List(c.list); !reflect.DeepEqual(got, c.exp) {
+				t.Errorf("expected '%v' got '%v'", c.exp, got)
+			}
+		})
+	}
+
+	changed, err = ReconcileReactions(fake, client, evt, "bot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changed) != 0 {
+		t.Errorf("expected nothing to change once reconciled got '%v'", changed)
+	}
+}
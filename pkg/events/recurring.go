@@ -11,17 +11,58 @@ import (
 
 	"github.com/acastle/esperbot/pkg/util"
 	"github.com/go-redis/redis"
-	"github.com/google/uuid"
 )
 
 var ErrInvalidWeekday = errors.New("invalid weekday")
+var ErrUnknownRecurringEvent = errors.New("no recurring event with that id")
 
 const RecurrentEventIndex string = "index:recurring"
 
+const DefaultWeeksAhead int = 3
+const DefaultAnnounceDaysBefore int = 7
+
 type RecurringEvent struct {
 	ID       string
 	Name     string
 	Weekdays []time.Weekday
+
+	// WeeksAhead is the number of weeks, including the current week, that instances are scheduled for
+	WeeksAhead int
+	// AnnounceDaysBefore is how many days before an instance that its announcement is posted
+	AnnounceDaysBefore int
+	// Embed customizes the announcements of instances, it is stored with SetEmbedSettings
+	Embed EmbedSettings
+	// Reactions map the reactions to announcements to attendance lists, DefaultReactions are used when empty
+	Reactions []ReactionMapping
+}
+
+// Horizon returns the number of weeks to schedule instances for, falling back to DefaultWeeksAhead
+func (r RecurringEvent) Horizon() int {
+	if r.WeeksAhead <= 0 {
+		return DefaultWeeksAhead
+	}
+
+	return r.WeeksAhead
+}
+
+// AnnounceLead returns how long before an instance that it should be announced, falling back to
+// DefaultAnnounceDaysBefore
+func (r RecurringEvent) AnnounceLead() time.Duration {
+	days := r.AnnounceDaysBefore
+	if days <= 0 {
+		days = DefaultAnnounceDaysBefore
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ReactionMappings returns the reactions that change attendance for instances, falling back to DefaultReactions
+func (r RecurringEvent) ReactionMappings() []ReactionMapping {
+	if len(r.Reactions) == 0 {
+		return DefaultReactions
+	}
+
+	return r.Reactions
 }
 
 func RecurringEventKeyForId(id string) string {
@@ -63,6 +104,13 @@ func UpsertRecurringEvent(redis *redis.Client, event RecurringEvent) error {
 	pipe.HSet(key, "id", event.ID)
 	pipe.HSet(key, "name", event.Name)
 	pipe.HSet(key, "weekdays", days)
+	pipe.HSet(key, "weeks_ahead", event.WeeksAhead)
+	pipe.HSet(key, "announce_days_before", event.AnnounceDaysBefore)
+	if len(event.Reactions) > 0 {
+		pipe.HSet(key, "reactions", serializeReactions(event.Reactions))
+	} else {
+		pipe.HDel(key, "reactions")
+	}
 	pipe.SAdd(RecurrentEventIndex, event.ID)
 	_, err = pipe.Exec()
 	if err != nil {
@@ -72,6 +120,51 @@ func UpsertRecurringEvent(redis *redis.Client, event RecurringEvent) error {
 	return nil
 }
 
+// UpsertRecurringEventIfChanged stores the recurring event unless the stored one already matches it, and
+// returns whether it was stored. Embed settings are not compared as they are changed with commands rather than
+// configured.
+func UpsertRecurringEventIfChanged(redis *redis.Client, event RecurringEvent) (bool, error) {
+	stored, err := GetRecurringEventById(redis, event.ID)
+	if err != nil && !errors.Is(err, ErrUnknownRecurringEvent) {
+		return false, err
+	}
+
+	if err == nil && sameRecurringEvent(stored, event) {
+		return false, nil
+	}
+
+	err = UpsertRecurringEvent(redis, event)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func sameRecurringEvent(a RecurringEvent, b RecurringEvent) bool {
+	if a.ID != b.ID || a.Name != b.Name || a.WeeksAhead != b.WeeksAhead || a.AnnounceDaysBefore != b.AnnounceDaysBefore {
+		return false
+	}
+
+	if len(a.Weekdays) != len(b.Weekdays) || len(a.Reactions) != len(b.Reactions) {
+		return false
+	}
+
+	for i := range a.Weekdays {
+		if a.Weekdays[i] != b.Weekdays[i] {
+			return false
+		}
+	}
+
+	for i := range a.Reactions {
+		if a.Reactions[i] != b.Reactions[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
 func GetRecurringEventById(redis *redis.Client, id string) (RecurringEvent, error) {
 	key := RecurringEventKeyForId(id)
 	result := redis.HGetAll(key)
@@ -80,18 +173,54 @@ func GetRecurringEventById(redis *redis.Client, id string) (RecurringEvent, erro
 	}
 
 	data := result.Val()
+	if len(data) == 0 {
+		return RecurringEvent{}, ErrUnknownRecurringEvent
+	}
+
 	days, err := DeserializeWeekdays(data["weekdays"])
 	if err != nil {
 		return RecurringEvent{}, fmt.Errorf("deserialize weekdays: %w", err)
 	}
 
+	weeksAhead, err := parseOptionalInt(data["weeks_ahead"])
+	if err != nil {
+		return RecurringEvent{}, fmt.Errorf("parse weeks ahead: %w", err)
+	}
+
+	announceDaysBefore, err := parseOptionalInt(data["announce_days_before"])
+	if err != nil {
+		return RecurringEvent{}, fmt.Errorf("parse announce days before: %w", err)
+	}
+
+	embed, err := embedSettingsFromHash(data)
+	if err != nil {
+		return RecurringEvent{}, err
+	}
+
+	reactions, err := deserializeReactions(data["reactions"])
+	if err != nil {
+		return RecurringEvent{}, fmt.Errorf("deserialize reactions: %w", err)
+	}
+
 	return RecurringEvent{
-		ID:       data["id"],
-		Name:     data["name"],
-		Weekdays: days,
+		ID:                 data["id"],
+		Name:               data["name"],
+		Weekdays:           days,
+		WeeksAhead:         weeksAhead,
+		AnnounceDaysBefore: announceDaysBefore,
+		Embed:              embed,
+		Reactions:          reactions,
 	}, nil
 }
 
+func parseOptionalInt(s string) (int, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(s)
+}
+
 func GetRecurringEvents(redis *redis.Client) ([]RecurringEvent, error) {
 	result := redis.SMembers(RecurrentEventIndex)
 	if result.Err() != nil {
@@ -112,13 +241,20 @@ func GetRecurringEvents(redis *redis.Client) ([]RecurringEvent, error) {
 	return evts, nil
 }
 
+// EventIDForOccurrence returns the ID of the instance of a recurring event on the provided date. IDs are
+// derived from the date so that every scheduler computes the same ID for the same instance.
+func EventIDForOccurrence(templateID string, date time.Time) string {
+	return fmt.Sprintf("%s-%s", templateID, date.UTC().Format("2006-01-02"))
+}
+
 func WeeklyEventsForRecurringEvent(template RecurringEvent, date time.Time) ([]Event, error) {
 	evts := []Event{}
 	for _, weekday := range template.Weekdays {
+		t := util.BeginningOfDay(util.DayOfWeek(date.UTC(), weekday))
 		evts = append(evts, Event{
-			ID:               uuid.New().String(),
+			ID:               EventIDForOccurrence(template.ID, t),
 			Name:             template.Name,
-			Time:             util.BeginningOfDay(util.DayOfWeek(date.UTC(), weekday)),
+			Time:             t,
 			Status:           Unscheduled,
 			RecurringEventID: template.ID,
 		})
@@ -136,6 +272,11 @@ func ScheduleEventsForWeek(redis *redis.Client, date time.Time) error {
 		return fmt.Errorf("get recurring events: %w", err)
 	}
 
+	rules, err := GetAttendanceRules(redis)
+	if err != nil {
+		return fmt.Errorf("get attendance rules: %w", err)
+	}
+
 	for _, template := range templates {
 		evts, err := WeeklyEventsForRecurringEvent(template, date)
 		if err != nil {
@@ -151,46 +292,67 @@ func ScheduleEventsForWeek(redis *redis.Client, date time.Time) error {
 				continue
 			}
 
-			log.WithFields(log.Fields{
-				"begin": evt.Time,
-				"id":    evt.ID,
-			}).Info("scheduling event")
-			err = ScheduleEvent(redis, evt)
+			err = ScheduleInstance(redis, evt, rules)
 			if err != nil {
-				return fmt.Errorf("schedule event: %w", err)
+				return fmt.Errorf("schedule instance: %w", err)
 			}
+		}
 
-			attendance, err := GetAttendanceForDay(redis, evt.Time)
-			if err != nil {
-				return fmt.Errorf("fetch attendance for the day: %w", err)
-			}
+	}
 
-			for _, userID := range attendance.Late {
-				err := EventUserListAdd(redis, evt, userID, Late)
-				if err != nil {
-					return fmt.Errorf("add user to event list: %w", err)
-				}
-			}
+	return nil
+}
 
-			for _, userID := range attendance.Absent {
-				err := EventUserListAdd(redis, evt, userID, Absent)
-				if err != nil {
-					return fmt.Errorf("add user to event list: %w", err)
-				}
+// ScheduleInstance stores a new instance of a recurring event, carrying over any attendance already recorded
+// for its day and applying the attendance rules. Instances that have already been created, possibly by a
+// concurrent scheduler, are left untouched.
+func ScheduleInstance(redis *redis.Client, evt Event, rules []AttendanceRule) error {
+	log.WithFields(log.Fields{
+		"begin": evt.Time,
+		"id":    evt.ID,
+	}).Info("scheduling event")
+	err := CreateEvent(redis, evt)
+	if errors.Is(err, ErrEventExists) {
+		log.WithField("id", evt.ID).Info("event already scheduled")
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("create event: %w", err)
+	}
+
+	attendance, err := GetAttendanceForDay(redis, evt.Time)
+	if err != nil {
+		return fmt.Errorf("fetch attendance for the day: %w", err)
+	}
+
+	for _, t := range UserListTypes {
+		for _, userID := range attendance.List(t) {
+			err := EventUserListAdd(redis, evt, userID, t)
+			if err != nil {
+				return fmt.Errorf("add user to event list: %w", err)
 			}
 		}
+	}
 
+	err = ApplyAttendanceRules(redis, evt, rules)
+	if err != nil {
+		return fmt.Errorf("apply attendance rules: %w", err)
 	}
 
 	return nil
 }
 
 func ContainsEventForRecurringEvent(evts []Event, id string, date time.Time) bool {
+	_, ok := FindEventForRecurringEvent(evts, id, date)
+	return ok
+}
+
+func FindEventForRecurringEvent(evts []Event, id string, date time.Time) (Event, bool) {
+	expectedID := EventIDForOccurrence(id, date)
 	for _, evt := range evts {
-		if evt.RecurringEventID == id && evt.Time.Equal(date) {
-			return true
+		if evt.ID == expectedID || (evt.RecurringEventID == id && evt.Time.Equal(date)) {
+			return evt, true
 		}
 	}
 
-	return false
+	return Event{}, false
 }
@@ -53,6 +53,70 @@ func TestUpsertRecurringEvent(t *testing.T) {
 	}
 }
 
+func TestUpsertRecurringEventIfChanged(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	evt := RecurringEvent{
+		ID:       "raid",
+		Name:     "Raid",
+		Weekdays: []time.Weekday{time.Wednesday, time.Thursday},
+	}
+
+	renamed := evt
+	renamed.Name = "Mythic Raid"
+
+	reacted := renamed
+	reacted.Reactions = []ReactionMapping{{Emoji: "🚫", List: Absent}}
+
+	cases := []struct {
+		name  string
+		event RecurringEvent
+		exp   bool
+	}{
+		{"new", evt, true},
+		{"unchanged", evt, false},
+		{"renamed", renamed, true},
+		{"reactions", reacted, true},
+		{"unchanged reactions", reacted, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			changed, err := UpsertRecurringEventIfChanged(client, c.event)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if changed != c.exp {
+				t.Errorf("expected '%v' got '%v'", c.exp, changed)
+			}
+		})
+	}
+
+	// Embed settings are changed with commands, so they do not count as a change to the configuration
+	err = SetEmbedSettings(client, evt.ID, EmbedSettings{Title: "Castle Nathria"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := UpsertRecurringEventIfChanged(client, reacted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed {
+		t.Errorf("expected embed settings to be ignored")
+	}
+}
+
 func TestGetRecurringEventById(t *testing.T) {
 	svc, err := miniredis.Run()
 	if err != nil {
@@ -0,0 +1,143 @@
+package events
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/metrics"
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultRefreshWindow = 2 * time.Second
+
+// Refresher coalesces requests to refresh announcement embeds. The first request for an event starts a
+// window, further requests during the window are dropped and when it closes the event is read from storage
+// and announced once with its latest state. This keeps bursts of reactions within Discord's rate limits.
+// Refreshing an event of the current week also refreshes the summaries, coalesced in the same way.
+type Refresher struct {
+	messenger discord.Messenger
+	redis     *redis.Client
+	settings  Settings
+	window    time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*time.Timer
+	// summaries is the pending refresh of the summaries, nil when there is none
+	summaries *time.Timer
+	running   sync.WaitGroup
+}
+
+func NewRefresher(messenger discord.Messenger, redis *redis.Client, settings Settings, window time.Duration) *Refresher {
+	return &Refresher{
+		messenger: messenger,
+		redis:     redis,
+		settings:  settings,
+		window:    window,
+		pending:   map[string]*time.Timer{},
+	}
+}
+
+// Request schedules a refresh of the event's announcement at the end of the current window
+func (q *Refresher) Request(evt Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.pending[evt.ID]; ok {
+		metrics.AnnouncementsTotal.Inc("coalesced")
+		return
+	}
+
+	id := evt.ID
+	q.pending[id] = time.AfterFunc(q.window, func() {
+		q.refresh(id)
+	})
+}
+
+// Flush immediately refreshes every pending event and waits for refreshes already in progress to finish
+func (q *Refresher) Flush() {
+	q.mu.Lock()
+	ids := make([]string, 0, len(q.pending))
+	for id := range q.pending {
+		ids = append(ids, id)
+	}
+	q.mu.Unlock()
+
+	for _, id := range ids {
+		q.refresh(id)
+	}
+
+	// Refreshes in progress may still request the summaries
+	q.running.Wait()
+	q.refreshSummaries()
+	q.running.Wait()
+}
+
+func (q *Refresher) refresh(id string) {
+	q.mu.Lock()
+	timer, ok := q.pending[id]
+	if !ok {
+		q.mu.Unlock()
+		return
+	}
+	timer.Stop()
+	delete(q.pending, id)
+	q.running.Add(1)
+	q.mu.Unlock()
+	defer q.running.Done()
+
+	err := q.announce(id)
+	if err != nil {
+		metrics.ErrorsTotal.Inc("refresh")
+		log.WithField("id", id).Error(err)
+	}
+}
+
+func (q *Refresher) announce(id string) error {
+	evt, err := GetEventById(q.redis, id)
+	if err != nil {
+		return fmt.Errorf("get event: %w", err)
+	}
+
+	err = AnnounceEvent(q.messenger, q.redis, q.settings, evt)
+	if err != nil {
+		return fmt.Errorf("announce event: %w", err)
+	}
+
+	if inSummaryWeek(evt, time.Now()) {
+		q.requestSummaries()
+	}
+
+	return nil
+}
+
+func (q *Refresher) requestSummaries() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.summaries != nil {
+		metrics.AnnouncementsTotal.Inc("coalesced")
+		return
+	}
+
+	q.summaries = time.AfterFunc(q.window, q.refreshSummaries)
+}
+
+func (q *Refresher) refreshSummaries() {
+	q.mu.Lock()
+	if q.summaries == nil {
+		q.mu.Unlock()
+		return
+	}
+	q.summaries.Stop()
+	q.summaries = nil
+	q.running.Add(1)
+	q.mu.Unlock()
+	defer q.running.Done()
+
+	err := RefreshSummaries(q.messenger, q.redis, q.settings.Theme, time.Now())
+	if err != nil {
+		metrics.ErrorsTotal.Inc("summary")
+		log.Error(err)
+	}
+}
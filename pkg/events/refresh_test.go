@@ -0,0 +1,164 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+)
+
+func TestRefresher(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	evt := Event{
+		ID:                "raid",
+		Name:              "Raid",
+		Time:              time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		AnnounceChannelID: "channel",
+	}
+	err = ScheduleEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = AnnounceEvent(fake, client, testSettings, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.Reactions) != len(DefaultReactions) {
+		t.Fatalf("expected reactions on the new announcement, got '%v'", fake.Reactions)
+	}
+
+	evt, err = GetEventById(client, evt.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewRefresher(fake, client, testSettings, time.Hour)
+	for i := 0; i < 5; i++ {
+		q.Request(evt)
+	}
+
+	// The refresh must read the latest attendance rather than the state at the time of the request
+	err = EventUserListAdd(client, evt, "abc123", Absent)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake.AddUser(&discordgo.User{ID: "abc123", Username: "Tester"})
+	q.Flush()
+
+	if len(fake.Edits) != 1 {
+		t.Fatalf("expected requests to be coalesced into 1 edit, got %d", len(fake.Edits))
+	}
+
+	if fake.Edits[0].Fields[0].Value != "Tester\n" {
+		t.Errorf("expected the latest attendance in the embed, got '%s'", fake.Edits[0].Fields[0].Value)
+	}
+
+	if len(fake.Reactions) != len(DefaultReactions) {
+		t.Errorf("expected existing reactions not to be added again, got '%v'", fake.Reactions)
+	}
+}
+
+func TestRefresherWindow(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	evt := Event{
+		ID:                "raid",
+		Name:              "Raid",
+		Time:              time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+		AnnounceChannelID: "channel",
+	}
+	err = ScheduleEvent(client, evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewRefresher(fake, client, testSettings, 10*time.Millisecond)
+	q.Request(evt)
+	q.Request(evt)
+	time.Sleep(50 * time.Millisecond)
+	q.Flush()
+
+	if len(fake.Sent) != 1 {
+		t.Errorf("expected a single announcement after the window, got %d", len(fake.Sent))
+	}
+}
+
+func TestRefresherSummaries(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	now := time.Now().UTC()
+	embed, err := GetSummaryEmbed(client, DefaultEmbedTheme, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := fake.SendEmbed("summaries", embed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = SetSummary(client, Summary{GuildID: "guild", ChannelID: "summaries", MessageID: summary.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	q := NewRefresher(fake, client, testSettings, time.Hour)
+	for _, id := range []string{"first", "second"} {
+		evt := Event{ID: id, Name: "Raid", Time: now, AnnounceChannelID: "channel"}
+		err = ScheduleEvent(client, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		q.Request(evt)
+	}
+
+	if len(fake.Sent) != 1 {
+		t.Fatalf("expected nothing to be announced before the window closes, got %d messages", len(fake.Sent))
+	}
+
+	q.Flush()
+
+	if len(fake.Sent) != 3 {
+		t.Errorf("expected both events to be announced, got %d messages", len(fake.Sent)-1)
+	}
+
+	if len(fake.Edits) != 1 {
+		t.Fatalf("expected the summary to be edited once for both events, got %d edits", len(fake.Edits))
+	}
+
+	if fields := fake.Messages[summary.ID].Embeds[0].Fields; len(fields) != 2 {
+		t.Errorf("expected the summary to list both events got '%v'", fields)
+	}
+}
@@ -0,0 +1,161 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/go-redis/redis"
+)
+
+var ErrInvalidRule = errors.New("invalid attendance rule")
+
+const AttendanceRuleIndex string = "index:rules"
+
+// AttendanceRule places a user on an attendance list for every event on a given weekday. Rules with a zero
+// Until never expire.
+type AttendanceRule struct {
+	UserID  string
+	List    UserListType
+	Weekday time.Weekday
+	Until   time.Time
+}
+
+func AttendanceRuleKeyForUser(userID string) string {
+	return fmt.Sprintf("rules:%s", userID)
+}
+
+func attendanceRuleField(t UserListType, weekday time.Weekday) string {
+	return fmt.Sprintf("%s:%d", t, weekday)
+}
+
+// Applies returns true if the rule covers an event at the provided time
+func (r AttendanceRule) Applies(t time.Time) bool {
+	if t.UTC().Weekday() != r.Weekday {
+		return false
+	}
+
+	return r.Until.IsZero() || !t.After(r.Until)
+}
+
+func AddAttendanceRules(redis *redis.Client, userID string, t UserListType, rec util.Recurrence) error {
+	var until int64
+	if !rec.Until.IsZero() {
+		until = rec.Until.Unix()
+	}
+
+	pipe := redis.Pipeline()
+	key := AttendanceRuleKeyForUser(userID)
+	for _, day := range rec.Weekdays {
+		pipe.HSet(key, attendanceRuleField(t, day), until)
+	}
+
+	pipe.SAdd(AttendanceRuleIndex, userID)
+	_, err := pipe.Exec()
+	if err != nil {
+		return fmt.Errorf("execute pipeline: %w", err)
+	}
+
+	return nil
+}
+
+func RemoveAttendanceRules(redis *redis.Client, userID string, t UserListType, weekdays []time.Weekday) error {
+	key := AttendanceRuleKeyForUser(userID)
+	fields := make([]string, len(weekdays))
+	for i, day := range weekdays {
+		fields[i] = attendanceRuleField(t, day)
+	}
+
+	result := redis.HDel(key, fields...)
+	if result.Err() != nil {
+		return fmt.Errorf("remove rules: %w", result.Err())
+	}
+
+	return nil
+}
+
+func GetAttendanceRulesForUser(redis *redis.Client, userID string) ([]AttendanceRule, error) {
+	result := redis.HGetAll(AttendanceRuleKeyForUser(userID))
+	if result.Err() != nil {
+		return nil, fmt.Errorf("get rules for user: %w", result.Err())
+	}
+
+	rules := []AttendanceRule{}
+	for field, value := range result.Val() {
+		rule, err := parseAttendanceRule(userID, field, value)
+		if err != nil {
+			return nil, fmt.Errorf("parse rule '%s': %w", field, err)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+func GetAttendanceRules(redis *redis.Client) ([]AttendanceRule, error) {
+	result := redis.SMembers(AttendanceRuleIndex)
+	if result.Err() != nil {
+		return nil, fmt.Errorf("get users from rule index: %w", result.Err())
+	}
+
+	rules := []AttendanceRule{}
+	for _, userID := range result.Val() {
+		userRules, err := GetAttendanceRulesForUser(redis, userID)
+		if err != nil {
+			return nil, fmt.Errorf("get rules for user: %w", err)
+		}
+
+		rules = append(rules, userRules...)
+	}
+
+	return rules, nil
+}
+
+func parseAttendanceRule(userID string, field string, value string) (AttendanceRule, error) {
+	parts := strings.SplitN(field, ":", 2)
+	if len(parts) != 2 {
+		return AttendanceRule{}, ErrInvalidRule
+	}
+
+	day, err := strconv.Atoi(parts[1])
+	if err != nil || day < 0 || day > 6 {
+		return AttendanceRule{}, ErrInvalidWeekday
+	}
+
+	until, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return AttendanceRule{}, ErrInvalidRule
+	}
+
+	rule := AttendanceRule{
+		UserID:  userID,
+		List:    UserListType(parts[0]),
+		Weekday: time.Weekday(day),
+	}
+
+	if until != 0 {
+		rule.Until = time.Unix(until, 0).UTC()
+	}
+
+	return rule, nil
+}
+
+// ApplyAttendanceRules adds users to the lists of the provided event according to the stored attendance rules
+func ApplyAttendanceRules(redis *redis.Client, evt Event, rules []AttendanceRule) error {
+	for _, rule := range rules {
+		if !rule.Applies(evt.Time) {
+			continue
+		}
+
+		err := EventUserListAdd(redis, evt, rule.UserID, rule.List)
+		if err != nil {
+			return fmt.Errorf("add user to event list: %w", err)
+		}
+	}
+
+	return nil
+}
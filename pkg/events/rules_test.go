@@ -0,0 +1,129 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestGetAttendanceRules(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	until := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+	err = AddAttendanceRules(client, "abc123", Absent, util.Recurrence{
+		Weekdays: []time.Weekday{time.Wednesday},
+		Until:    until,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	rules, err := GetAttendanceRules(client)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := AttendanceRule{
+		UserID:  "abc123",
+		List:    Absent,
+		Weekday: time.Wednesday,
+		Until:   until,
+	}
+	if len(rules) != 1 || rules[0] != expected {
+		t.Errorf("expected '%v' got '%v'", expected, rules)
+	}
+
+	err = RemoveAttendanceRules(client, "abc123", Absent, []time.Weekday{time.Wednesday})
+	if err != nil {
+		t.Error(err)
+	}
+
+	rules, err = GetAttendanceRules(client)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(rules) != 0 {
+		t.Errorf("expected rule to be removed, got '%v'", rules)
+	}
+}
+
+func TestScheduleEventsForWeekAppliesRules(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	week := time.Date(2021, 8, 22, 0, 0, 0, 0, time.UTC)
+	err = UpsertRecurringEvent(client, RecurringEvent{
+		ID:       "raid",
+		Name:     "Raid",
+		Weekdays: []time.Weekday{time.Wednesday, time.Thursday},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = AddAttendanceRules(client, "expired", Absent, util.Recurrence{
+		Weekdays: []time.Weekday{time.Wednesday},
+		Until:    week,
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = AddAttendanceRules(client, "forever", Late, util.Recurrence{
+		Weekdays: []time.Weekday{time.Wednesday},
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = ScheduleEventsForWeek(client, week)
+	if err != nil {
+		t.Error(err)
+	}
+
+	evts, err := GetEventsForWeek(client, week)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(evts) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(evts))
+	}
+
+	for _, evt := range evts {
+		attendance, err := GetAttendanceForEvent(client, evt)
+		if err != nil {
+			t.Error(err)
+		}
+
+		if len(attendance.Absent) != 0 {
+			t.Errorf("expired rule applied to event on %s", evt.Time.Weekday())
+		}
+
+		isLate := len(attendance.Late) == 1 && attendance.Late[0] == "forever"
+		if evt.Time.Weekday() == time.Wednesday && !isLate {
+			t.Error("rule was not applied to wednesday event")
+		}
+
+		if evt.Time.Weekday() == time.Thursday && len(attendance.Late) != 0 {
+			t.Error("rule was applied to thursday event")
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/acastle/esperbot/pkg/metrics"
+	"github.com/acastle/esperbot/pkg/util"
+	"github.com/bwmarrin/discordgo"
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrNoSummary = errors.New("no summary message")
+
+// Summary is the pinned message of a guild listing the events of the current week, it is edited whenever the
+// events or their attendance change
+type Summary struct {
+	GuildID   string
+	ChannelID string
+	MessageID string
+}
+
+// SummaryIndexKey is the set of guilds with a summary message
+const SummaryIndexKey = "summaries"
+
+func SummaryKeyForGuild(guildID string) string {
+	return fmt.Sprintf("summary:%s", guildID)
+}
+
+// GetSummary returns the summary message of the guild, or ErrNoSummary when it has none
+func GetSummary(r *redis.Client, guildID string) (Summary, error) {
+	data, err := r.HGetAll(SummaryKeyForGuild(guildID)).Result()
+	if err != nil {
+		return Summary{}, fmt.Errorf("get summary: %w", err)
+	}
+
+	if data["message_id"] == "" {
+		return Summary{}, ErrNoSummary
+	}
+
+	return Summary{
+		GuildID:   guildID,
+		ChannelID: data["channel_id"],
+		MessageID: data["message_id"],
+	}, nil
+}
+
+// GetSummaries returns the summary message of every guild that has one
+func GetSummaries(r *redis.Client) ([]Summary, error) {
+	guildIDs, err := r.SMembers(SummaryIndexKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get summary index: %w", err)
+	}
+
+	cmds := make([]*redis.StringStringMapCmd, len(guildIDs))
+	_, err = r.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, guildID := range guildIDs {
+			cmds[i] = pipe.HGetAll(SummaryKeyForGuild(guildID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get summaries: %w", err)
+	}
+
+	summaries := []Summary{}
+	for i, cmd := range cmds {
+		data := cmd.Val()
+		if data["message_id"] == "" {
+			continue
+		}
+
+		summaries = append(summaries, Summary{
+			GuildID:   guildIDs[i],
+			ChannelID: data["channel_id"],
+			MessageID: data["message_id"],
+		})
+	}
+
+	return summaries, nil
+}
+
+// SetSummary stores the summary message of a guild, replacing any previous one
+func SetSummary(r *redis.Client, s Summary) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.HSet(SummaryKeyForGuild(s.GuildID), "channel_id", s.ChannelID)
+		pipe.HSet(SummaryKeyForGuild(s.GuildID), "message_id", s.MessageID)
+		pipe.SAdd(SummaryIndexKey, s.GuildID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("store summary: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveSummary forgets the summary message of a guild so that it is no longer edited
+func RemoveSummary(r *redis.Client, guildID string) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(SummaryKeyForGuild(guildID))
+		pipe.SRem(SummaryIndexKey, guildID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("remove summary: %w", err)
+	}
+
+	return nil
+}
+
+// GetSummaryEmbed lists the events of the week containing the date in order, with their start times and how
+// many members are on each attendance list, with the colour and thumbnail of the theme
+func GetSummaryEmbed(r *redis.Client, theme EmbedTheme, date time.Time) (*discordgo.MessageEmbed, error) {
+	week := util.DateRange{
+		Begin: util.BeginningOfWeek(date.UTC()),
+		End:   util.EndOfWeek(date.UTC()),
+	}
+
+	evts, err := GetEventsForDateRange(r, week)
+	if err != nil {
+		return nil, fmt.Errorf("get events for week: %w", err)
+	}
+
+	sort.Slice(evts, func(i, j int) bool {
+		return evts[i].Time.Before(evts[j].Time)
+	})
+
+	counts, err := countAttendance(r, evts)
+	if err != nil {
+		return nil, err
+	}
+
+	embed := discordgo.MessageEmbed{
+		Author: &discordgo.MessageEmbedAuthor{
+			Name: "Upcoming events",
+		},
+		Description: fmt.Sprintf("for the week of %s to %s", week.Begin.Format("Monday Jan _2 2006"), week.End.Format("Monday Jan _2 2006")),
+		Color:       theme.Color,
+		Thumbnail: &discordgo.MessageEmbedThumbnail{
+			URL: theme.ThumbnailURL,
+		},
+		Fields: []*discordgo.MessageEmbedField{},
+	}
+
+	if len(evts) == 0 {
+		embed.Description += "\nNo events are planned."
+	}
+
+	for i, evt := range evts {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  evt.Name,
+			Value: summaryLine(evt, counts[i]),
+		})
+	}
+
+	return &embed, nil
+}
+
+// summaryLine describes an event in the summary, for example "Wednesday Aug 25 19:00 UTC, 2 out, 1 late"
+func summaryLine(evt Event, counts map[UserListType]int64) string {
+	parts := []string{evt.Time.UTC().Format("Monday Jan _2 15:04 MST")}
+	if evt.Status == Canceled {
+		return parts[0] + ", canceled"
+	}
+
+	for _, t := range UserListTypes {
+		if counts[t] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[t], strings.ToLower(t.Title())))
+		}
+	}
+
+	if len(parts) == 1 {
+		parts = append(parts, "everyone in")
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// countAttendance returns the size of each attendance list of the events in a single round trip
+func countAttendance(r *redis.Client, evts []Event) ([]map[UserListType]int64, error) {
+	cmds := make([]map[UserListType]*redis.IntCmd, len(evts))
+	_, err := r.Pipelined(func(pipe redis.Pipeliner) error {
+		for i, evt := range evts {
+			cmds[i] = map[UserListType]*redis.IntCmd{}
+			for _, t := range UserListTypes {
+				cmds[i][t] = pipe.SCard(UserListKeyForEventId(evt.ID, t))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("count attendance: %w", err)
+	}
+
+	counts := make([]map[UserListType]int64, len(evts))
+	for i := range evts {
+		counts[i] = map[UserListType]int64{}
+		for t, cmd := range cmds[i] {
+			counts[i][t] = cmd.Val()
+		}
+	}
+
+	return counts, nil
+}
+
+// RefreshSummaries edits the summary message of every guild to show the current week. Summaries whose message
+// was deleted are forgotten rather than posted again.
+func RefreshSummaries(messenger discord.Messenger, r *redis.Client, theme EmbedTheme, now time.Time) error {
+	summaries, err := GetSummaries(r)
+	if err != nil {
+		return err
+	}
+
+	if len(summaries) == 0 {
+		return nil
+	}
+
+	embed, err := GetSummaryEmbed(r, theme, now)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range summaries {
+		_, err = messenger.EditEmbed(s.ChannelID, s.MessageID, embed)
+		if discord.IsUnknownMessage(err) {
+			log.WithFields(log.Fields{
+				"guild":      s.GuildID,
+				"message_id": s.MessageID,
+			}).Warn("summary message was deleted")
+			err = RemoveSummary(r, s.GuildID)
+			if err != nil {
+				return err
+			}
+		} else if err != nil {
+			return fmt.Errorf("update summary message: %w", err)
+		}
+	}
+
+	metrics.AnnouncementsTotal.Add("summary", float64(len(summaries)))
+	return nil
+}
+
+// inSummaryWeek reports whether the event is listed by summaries refreshed at the given time
+func inSummaryWeek(evt Event, now time.Time) bool {
+	now = now.UTC()
+	return !evt.Time.Before(util.BeginningOfWeek(now)) && !evt.Time.After(util.EndOfWeek(now))
+}
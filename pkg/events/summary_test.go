@@ -0,0 +1,138 @@
+package events
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/acastle/esperbot/pkg/discord"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestGetSummaryEmbed(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	week := time.Date(2021, 8, 22, 0, 0, 0, 0, time.UTC)
+	evts := []Event{
+		{ID: "friday", Name: "Friday Raid", Time: week.AddDate(0, 0, 5).Add(19 * time.Hour)},
+		{ID: "wednesday", Name: "Raid", Time: week.AddDate(0, 0, 3).Add(19 * time.Hour)},
+		{ID: "next-week", Name: "Raid", Time: week.AddDate(0, 0, 10)},
+	}
+	for _, evt := range evts {
+		err = ScheduleEvent(client, evt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, id := range []string{"a", "b"} {
+		err = EventUserListAdd(client, evts[1], id, Absent)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = EventUserListAdd(client, evts[1], "c", Late)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	embed, err := GetSummaryEmbed(client, DefaultEmbedTheme, week.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name  string
+		value string
+	}{
+		{"Raid", "Wednesday Aug 25 19:00 UTC, 2 out, 1 late"},
+		{"Friday Raid", "Friday Aug 27 19:00 UTC, everyone in"},
+	}
+
+	if len(embed.Fields) != len(cases) {
+		t.Fatalf("expected %d events got '%v'", len(cases), embed.Fields)
+	}
+
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			field := embed.Fields[i]
+			if field.Name != c.name || field.Value != c.value {
+				t.Errorf("expected '%s: %s' got '%s: %s'", c.name, c.value, field.Name, field.Value)
+			}
+		})
+	}
+
+	if embed.Thumbnail.URL != DefaultEmbedTheme.ThumbnailURL {
+		t.Errorf("expected the theme thumbnail got '%s'", embed.Thumbnail.URL)
+	}
+}
+
+func TestRefreshSummaries(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	fake := discord.NewFake()
+	now := time.Now().UTC()
+	summaries := map[string]string{}
+	for _, guildID := range []string{"kept", "deleted"} {
+		embed, err := GetSummaryEmbed(client, DefaultEmbedTheme, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg, err := fake.SendEmbed("summaries", embed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = SetSummary(client, Summary{GuildID: guildID, ChannelID: "summaries", MessageID: msg.ID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		summaries[guildID] = msg.ID
+	}
+
+	fake.DeleteMessage(summaries["deleted"])
+	err = ScheduleEvent(client, Event{ID: "raid", Name: "Raid", Time: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = RefreshSummaries(fake, client, DefaultEmbedTheme, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fields := fake.Messages[summaries["kept"]].Embeds[0].Fields; len(fields) != 1 {
+		t.Errorf("expected the summary to list the event got '%v'", fields)
+	}
+
+	_, err = GetSummary(client, "deleted")
+	if !errors.Is(err, ErrNoSummary) {
+		t.Errorf("expected the deleted summary to be forgotten got '%v'", err)
+	}
+
+	remaining, err := GetSummaries(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(remaining) != 1 || remaining[0].GuildID != "kept" {
+		t.Errorf("expected only the kept summary got '%v'", remaining)
+	}
+}
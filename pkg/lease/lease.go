@@ -0,0 +1,149 @@
+package lease
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+	log "github.com/sirupsen/logrus"
+)
+
+const DefaultTTL = 15 * time.Second
+
+// renewScript extends the lease only if it is still held by the caller
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript deletes the lease only if it is still held by the caller
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// Elector holds a leader lease in Redis so that only one of several replicas performs work that must not be
+// duplicated. The lease expires if the holder stops renewing it, allowing another replica to take over.
+type Elector struct {
+	redis *redis.Client
+	key   string
+	id    string
+	ttl   time.Duration
+
+	// OnElected is called in its own goroutine whenever this replica becomes the leader
+	OnElected func()
+
+	mu     sync.RWMutex
+	leader bool
+}
+
+func NewElector(redis *redis.Client, key string, id string, ttl time.Duration) *Elector {
+	return &Elector{
+		redis: redis,
+		key:   key,
+		id:    id,
+		ttl:   ttl,
+	}
+}
+
+func KeyForLease(name string) string {
+	return fmt.Sprintf("lease:%s", name)
+}
+
+// ID returns the identity this replica holds the lease under
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader returns true if this replica held the lease as of the last renewal
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Tick acquires the lease if it is free or renews it if it is already held, returning whether this replica
+// is the leader. Errors talking to Redis are treated as a loss of leadership.
+func (e *Elector) Tick() (bool, error) {
+	leader, err := e.tryAcquire()
+	if err != nil {
+		leader = false
+	}
+
+	e.mu.Lock()
+	elected := leader && !e.leader
+	if e.leader != leader {
+		log.WithFields(log.Fields{
+			"id":     e.id,
+			"leader": leader,
+		}).Info("leadership changed")
+	}
+	e.leader = leader
+	e.mu.Unlock()
+
+	if elected && e.OnElected != nil {
+		go e.OnElected()
+	}
+
+	return leader, err
+}
+
+func (e *Elector) tryAcquire() (bool, error) {
+	acquired, err := e.redis.SetNX(e.key, e.id, e.ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("acquire lease: %w", err)
+	}
+
+	if acquired {
+		return true, nil
+	}
+
+	renewed, err := renewScript.Run(e.redis, []string{e.key}, e.id, e.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, fmt.Errorf("renew lease: %w", err)
+	}
+
+	return renewed == 1, nil
+}
+
+// Run keeps the lease acquired or renewed until stop is closed, then releases it so another replica can
+// take over without waiting for it to expire
+func (e *Elector) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		_, err := e.Tick()
+		if err != nil {
+			log.Error(err)
+		}
+
+		select {
+		case <-stop:
+			err := e.Release()
+			if err != nil {
+				log.Error(err)
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Release gives up the lease if it is held by this replica
+func (e *Elector) Release() error {
+	e.mu.Lock()
+	e.leader = false
+	e.mu.Unlock()
+
+	err := releaseScript.Run(e.redis, []string{e.key}, e.id).Err()
+	if err != nil {
+		return fmt.Errorf("release lease: %w", err)
+	}
+
+	return nil
+}
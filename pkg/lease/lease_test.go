@@ -0,0 +1,82 @@
+package lease
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestElector(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	key := KeyForLease("test")
+	first := NewElector(client, key, "first", 10*time.Second)
+	second := NewElector(client, key, "second", 10*time.Second)
+
+	leader, err := first.Tick()
+	if err != nil || !leader {
+		t.Fatalf("expected first replica to acquire the lease, got %v %v", leader, err)
+	}
+
+	leader, err = second.Tick()
+	if err != nil || leader {
+		t.Fatalf("expected second replica to be a follower, got %v %v", leader, err)
+	}
+
+	// Renewing keeps the lease with the first replica
+	svc.FastForward(8 * time.Second)
+	leader, err = first.Tick()
+	if err != nil || !leader {
+		t.Fatalf("expected first replica to renew the lease, got %v %v", leader, err)
+	}
+
+	svc.FastForward(8 * time.Second)
+	leader, err = second.Tick()
+	if err != nil || leader {
+		t.Fatalf("expected lease to still be held after renewal, got %v %v", leader, err)
+	}
+
+	// The first replica stops renewing and the lease expires
+	svc.FastForward(3 * time.Second)
+	elected := make(chan struct{})
+	second.OnElected = func() { close(elected) }
+	leader, err = second.Tick()
+	if err != nil || !leader {
+		t.Fatalf("expected second replica to take over, got %v %v", leader, err)
+	}
+
+	select {
+	case <-elected:
+	case <-time.After(time.Second):
+		t.Error("OnElected was not called")
+	}
+
+	leader, err = first.Tick()
+	if err != nil || leader {
+		t.Fatalf("expected first replica to lose leadership, got %v %v", leader, err)
+	}
+
+	if first.IsLeader() || !second.IsLeader() {
+		t.Error("IsLeader does not reflect the last tick")
+	}
+
+	// Releasing hands the lease over immediately
+	err = second.Release()
+	if err != nil {
+		t.Error(err)
+	}
+
+	leader, err = first.Tick()
+	if err != nil || !leader {
+		t.Fatalf("expected first replica to acquire the released lease, got %v %v", leader, err)
+	}
+}
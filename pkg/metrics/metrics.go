@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+// Collector is a metric that can write itself in the Prometheus text exposition format
+type Collector interface {
+	Write(w io.Writer) error
+}
+
+// CounterVec is a set of counters partitioned by the value of a single label
+type CounterVec struct {
+	name  string
+	help  string
+	label string
+
+	mu     sync.Mutex
+	values map[string]float64
+}
+
+func NewCounterVec(name string, help string, label string) *CounterVec {
+	return &CounterVec{
+		name:   name,
+		help:   help,
+		label:  label,
+		values: map[string]float64{},
+	}
+}
+
+func (c *CounterVec) Inc(labelValue string) {
+	c.Add(labelValue, 1)
+}
+
+func (c *CounterVec) Add(labelValue string, v float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[labelValue] += v
+}
+
+// Value returns the current count for the label value
+func (c *CounterVec) Value(labelValue string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.values[labelValue]
+}
+
+func (c *CounterVec) Write(w io.Writer) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", c.name, c.help, c.name)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(c.values))
+	for k := range c.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		_, err := fmt.Fprintf(w, "%s{%s=\"%s\"} %g\n", c.name, c.label, escapeLabel(k), c.values[k])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Summary tracks the count and total of observed values, such as latencies in seconds
+type Summary struct {
+	name string
+	help string
+
+	mu    sync.Mutex
+	count uint64
+	sum   float64
+}
+
+func NewSummary(name string, help string) *Summary {
+	return &Summary{
+		name: name,
+		help: help,
+	}
+}
+
+func (s *Summary) Observe(v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.count++
+	s.sum += v
+}
+
+func (s *Summary) Write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n%s_sum %g\n%s_count %d\n", s.name, s.help, s.name, s.name, s.sum, s.name, s.count)
+	return err
+}
+
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+var (
+	CommandsTotal      = NewCounterVec("esperbot_commands_total", "Commands executed by type.", "command")
+	ErrorsTotal        = NewCounterVec("esperbot_errors_total", "Errors by the source that produced them.", "source")
+	ReactionsTotal     = NewCounterVec("esperbot_reaction_events_total", "Reaction events handled by action.", "action")
+	AnnouncementsTotal = NewCounterVec("esperbot_announcements_total", "Announcement messages created or edited.", "action")
+	RedisLatency       = NewSummary("esperbot_redis_latency_seconds", "Latency of Redis commands and pipelines.")
+)
+
+// All lists the collectors exposed by WriteAll
+var All = []Collector{
+	CommandsTotal,
+	ErrorsTotal,
+	ReactionsTotal,
+	AnnouncementsTotal,
+	RedisLatency,
+}
+
+// WriteAll writes every collector in the Prometheus text exposition format
+func WriteAll(w io.Writer) error {
+	for _, c := range All {
+		err := c.Write(w)
+		if err != nil {
+			return fmt.Errorf("write metric: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// InstrumentRedis records the latency of every command and pipeline sent by the client
+func InstrumentRedis(client *redis.Client) {
+	client.WrapProcess(func(old func(cmd redis.Cmder) error) func(cmd redis.Cmder) error {
+		return func(cmd redis.Cmder) error {
+			start := time.Now()
+			err := old(cmd)
+			RedisLatency.Observe(time.Since(start).Seconds())
+			return err
+		}
+	})
+	client.WrapProcessPipeline(func(old func(cmds []redis.Cmder) error) func(cmds []redis.Cmder) error {
+		return func(cmds []redis.Cmder) error {
+			start := time.Now()
+			err := old(cmds)
+			RedisLatency.Observe(time.Since(start).Seconds())
+			return err
+		}
+	})
+}
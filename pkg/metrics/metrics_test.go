@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestCounterVecWrite(t *testing.T) {
+	c := NewCounterVec("test_total", "A test counter.", "kind")
+	c.Inc("b")
+	c.Inc("a")
+	c.Add("b", 2)
+
+	buf := bytes.Buffer{}
+	err := c.Write(&buf)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "# HELP test_total A test counter.\n# TYPE test_total counter\ntest_total{kind=\"a\"} 1\ntest_total{kind=\"b\"} 3\n"
+	if buf.String() != expected {
+		t.Errorf("expected '%s' got '%s'", expected, buf.String())
+	}
+}
+
+func TestSummaryWrite(t *testing.T) {
+	s := NewSummary("test_seconds", "A test summary.")
+	s.Observe(0.5)
+	s.Observe(1.5)
+
+	buf := bytes.Buffer{}
+	err := s.Write(&buf)
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := "# HELP test_seconds A test summary.\n# TYPE test_seconds summary\ntest_seconds_sum 2\ntest_seconds_count 2\n"
+	if buf.String() != expected {
+		t.Errorf("expected '%s' got '%s'", expected, buf.String())
+	}
+}
+
+func TestInstrumentRedis(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	InstrumentRedis(client)
+	before := RedisLatency.count
+	client.Ping()
+	pipe := client.Pipeline()
+	pipe.Set("a", "b", 0)
+	pipe.Exec()
+
+	if RedisLatency.count != before+2 {
+		t.Errorf("expected 2 observations got %d", RedisLatency.count-before)
+	}
+}
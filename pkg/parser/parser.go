@@ -6,67 +6,61 @@ import (
 	"strings"
 
 	"github.com/acastle/esperbot/pkg/commands"
-	"github.com/acastle/esperbot/pkg/util"
 )
 
-const CommandPrefix = "!"
+const CommandPrefix = commands.DefaultPrefix
 
-var ErrMissingPrefix = errors.New("commands must start with the prefix '!'")
+var ErrMissingPrefix = errors.New("commands must start with the command prefix or a mention of the bot")
 var ErrUnknownCommand = errors.New("unknown command")
 
+// Commands is the registry that Parse dispatches from
+var Commands = commands.NewDefaultRegistry()
+
+// Parse parses a command invoked with the default prefix
 func Parse(command string) (commands.Command, error) {
-	if !strings.HasPrefix(command, CommandPrefix) {
-		return nil, ErrMissingPrefix
+	return ParseWithPrefixes(command, CommandPrefix)
+}
+
+// ParseWithPrefixes parses a command invoked with any of the provided prefixes, for example the guild's
+// configured prefix along with the MentionPrefixes of the bot
+func ParseWithPrefixes(command string, prefixes ...string) (commands.Command, error) {
+	fields, err := Split(command, prefixes...)
+	if err != nil {
+		return nil, err
 	}
 
-	fields := strings.Fields(command)
-	switch fields[0] {
-	case "!help":
-		return &commands.HelpCommand{}, nil
-	case "!setname":
-		return &commands.SetNameCommand{
-			Name: strings.Join(fields[1:], "-"),
-		}, nil
-	case "!out":
-		dates, err := util.FlagsToDateRange(fields[1:])
-		if err != nil {
-			return nil, fmt.Errorf("parse flags: %w", err)
-		}
-		return &commands.OutCommand{
-			Dates: dates,
-		}, nil
-	case "!late":
-		dates, err := util.FlagsToDateRange(fields[1:])
-		if err != nil {
-			return nil, fmt.Errorf("parse flags: %w", err)
-		}
-		return &commands.LateCommand{
-			Dates: dates,
-		}, nil
-	case "!ontime":
-		dates, err := util.FlagsToDateRange(fields[1:])
-		if err != nil {
-			return nil, fmt.Errorf("parse flags: %w", err)
+	spec, ok := Commands.Lookup(fields[0])
+	if !ok {
+		return nil, ErrUnknownCommand
+	}
+
+	return spec.Parse(fields[1:])
+}
+
+// Split removes the first matching prefix from a command and returns the command name followed by its
+// arguments
+func Split(command string, prefixes ...string) ([]string, error) {
+	for _, prefix := range prefixes {
+		if prefix == "" || !strings.HasPrefix(command, prefix) {
+			continue
 		}
-		return &commands.OnTimeCommand{
-			Dates: dates,
-		}, nil
-	case "!in":
-		dates, err := util.FlagsToDateRange(fields[1:])
-		if err != nil {
-			return nil, fmt.Errorf("parse flags: %w", err)
+
+		fields := strings.Fields(strings.TrimPrefix(command, prefix))
+		if len(fields) == 0 {
+			return nil, ErrUnknownCommand
 		}
-		return &commands.InCommand{
-			Dates: dates,
-		}, nil
-	case "!schedule":
-		return &commands.ScheduleCommand{}, nil
-	case "!events":
-		return &commands.EventsCommand{}, nil
-	case "!announce":
-		return &commands.AnnounceCommand{}, nil
-	default:
-		return nil, ErrUnknownCommand
+
+		return fields, nil
 	}
 
+	return nil, ErrMissingPrefix
+}
+
+// MentionPrefixes returns the forms a mention of the user can take at the start of a message, allowing
+// commands such as "@esperbot out friday"
+func MentionPrefixes(userID string) []string {
+	return []string{
+		fmt.Sprintf("<@%s>", userID),
+		fmt.Sprintf("<@!%s>", userID),
+	}
 }
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/acastle/esperbot/pkg/commands"
+	"github.com/acastle/esperbot/pkg/events"
 	"github.com/acastle/esperbot/pkg/util"
 )
 
@@ -18,7 +19,7 @@ func TestParse(t *testing.T) {
 		expErr     error
 		expCommand commands.Command
 	}{
-		/*{
+		{
 			"no prefix",
 			"help",
 			ErrMissingPrefix,
@@ -28,8 +29,14 @@ func TestParse(t *testing.T) {
 			"help command",
 			"!help",
 			nil,
-			&commands.HelpCommand{},
-		},*/
+			&commands.HelpCommand{Registry: Commands},
+		},
+		{
+			"help command with topic",
+			"!help !out",
+			nil,
+			&commands.HelpCommand{Registry: Commands, Topic: "out"},
+		},
 		{
 			"out command no args",
 			"!out",
@@ -42,8 +49,66 @@ func TestParse(t *testing.T) {
 			},
 		},
 		{
-			"in command",
-			"!in",
+			"out command every weekday",
+			"!out every wednesday",
+			nil,
+			&commands.RecurringAttendanceCommand{
+				Recurrence: util.Recurrence{
+					Weekdays: []time.Weekday{time.Wednesday},
+				},
+				List: events.Absent,
+			},
+		},
+		{
+			"out command alias",
+			"!ABSENT",
+			nil,
+			&commands.OutCommand{
+				Dates: util.DateRange{
+					Begin: util.BeginningOfWeek(now),
+					End:   util.EndOfWeek(now),
+				},
+			},
+		},
+		{
+			"in command every weekday",
+			"!in every wednesday",
+			nil,
+			&commands.RecurringAttendanceCommand{
+				Recurrence: util.Recurrence{
+					Weekdays: []time.Weekday{time.Wednesday},
+				},
+				List:  events.Absent,
+				Clear: true,
+			},
+		},
+		{
+			"out command for member",
+			"!out <@!123> every wednesday",
+			nil,
+			&commands.RecurringAttendanceCommand{
+				Recurrence: util.Recurrence{
+					Weekdays: []time.Weekday{time.Wednesday},
+				},
+				List:   events.Absent,
+				Target: "123",
+			},
+		},
+		{
+			"setname with spaces",
+			"!setname Big Banana",
+			nil,
+			&commands.SetNameCommand{Name: "Big Banana"},
+		},
+		{
+			"unknown command",
+			"!foo",
+			ErrUnknownCommand,
+			nil,
+		},
+		{
+			"prefix only",
+			"!",
 			ErrUnknownCommand,
 			nil,
 		},
@@ -64,3 +129,43 @@ func TestParse(t *testing.T) {
 	}
 
 }
+
+func TestParseWithPrefixes(t *testing.T) {
+	now := time.Now().UTC()
+	thisWeek := &commands.OutCommand{
+		Dates: util.DateRange{
+			Begin: util.BeginningOfWeek(now),
+			End:   util.EndOfWeek(now),
+		},
+	}
+	prefixes := append([]string{"?"}, MentionPrefixes("bot")...)
+
+	cases := []struct {
+		name       string
+		command    string
+		expErr     error
+		expCommand commands.Command
+	}{
+		{"guild prefix", "?out", nil, thisWeek},
+		{"default prefix not configured", "!out", ErrMissingPrefix, nil},
+		{"mention", "<@bot> out", nil, thisWeek},
+		{"nickname mention", "<@!bot> out", nil, thisWeek},
+		{"mention without space", "<@bot>out", nil, thisWeek},
+		{"mention without command", "<@bot>", ErrUnknownCommand, nil},
+		{"mention of another user", "<@other> out", ErrMissingPrefix, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cmd, err := ParseWithPrefixes(c.command, prefixes...)
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("expected '%v' got '%v'", c.expErr, err)
+				return
+			}
+
+			if !reflect.DeepEqual(cmd, c.expCommand) {
+				t.Errorf("expected '%v' got '%v'", c.expCommand, cmd)
+			}
+		})
+	}
+}
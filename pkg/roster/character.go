@@ -0,0 +1,420 @@
+package roster
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/go-redis/redis"
+)
+
+var ErrInvalidCharacterName = errors.New("characters must be given as Name-Realm")
+var ErrUnknownCharacter = errors.New("no character with that name")
+var ErrConcurrentUpdate = errors.New("characters were changed concurrently, try again")
+
+// Character is a character played by a member. Each member has at most one main character, the rest are alts.
+type Character struct {
+	Name  string
+	Realm string
+	Class string
+	Spec  string
+	Main  bool
+}
+
+var specNames = map[string]string{
+	"beastmastery": "Beast Mastery",
+}
+
+// String returns the character in the form Name-Realm
+func (c Character) String() string {
+	return fmt.Sprintf("%s-%s", c.Name, c.Realm)
+}
+
+// Key identifies the character regardless of capitalization
+func (c Character) Key() string {
+	return strings.ToLower(c.String())
+}
+
+// Role returns the role played by the character's specialization
+func (c Character) Role() Role {
+	return Classes[c.Class].Specs[c.Spec]
+}
+
+// Description returns the specialization and class of the character, for example "Frost Mage"
+func (c Character) Description() string {
+	spec, ok := specNames[c.Spec]
+	if !ok {
+		spec = strings.Title(c.Spec)
+	}
+
+	return fmt.Sprintf("%s %s", spec, Classes[c.Class].Name)
+}
+
+// ParseCharacterName splits a character given as Name-Realm. Realms may themselves contain dashes, as in
+// "Name-Area-52".
+func ParseCharacterName(s string) (string, string, error) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", ErrInvalidCharacterName
+	}
+
+	for _, r := range parts[0] {
+		if !unicode.IsLetter(r) {
+			return "", "", ErrInvalidCharacterName
+		}
+	}
+
+	for _, r := range parts[1] {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-'", r) {
+			return "", "", ErrInvalidCharacterName
+		}
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// CharactersKey holds a member's characters keyed by Character.Key
+func CharactersKey(userID string) string {
+	return fmt.Sprintf("characters:%s", userID)
+}
+
+// CharacterIndexKey holds the ids of every member with a character
+const CharacterIndexKey = "index:characters"
+
+// MainsKey holds the main character of every member that has one, keyed by member id, so that the mains of a
+// few members can be read without reading every member's characters
+const MainsKey = "characters:mains"
+
+// MembersKey holds the ids of the guild's current members, only their mains are counted in the composition
+const MembersKey = "roster:members"
+
+// membersStagingKey collects the chunks of a guild members request before they replace MembersKey
+const membersStagingKey = "roster:members:staging"
+
+// maxCharacterRetries bounds how many times a change to a member's characters is retried when they are
+// changed concurrently
+const maxCharacterRetries = 5
+
+func encodeCharacter(c Character) string {
+	main := "alt"
+	if c.Main {
+		main = "main"
+	}
+
+	return strings.Join([]string{c.String(), c.Class, c.Spec, main}, "|")
+}
+
+func decodeCharacter(v string) (Character, error) {
+	parts := strings.Split(v, "|")
+	if len(parts) != 4 {
+		return Character{}, fmt.Errorf("decode character '%s'", v)
+	}
+
+	name, realm, err := ParseCharacterName(parts[0])
+	if err != nil {
+		return Character{}, fmt.Errorf("decode character '%s': %w", v, err)
+	}
+
+	return Character{
+		Name:  name,
+		Realm: realm,
+		Class: parts[1],
+		Spec:  parts[2],
+		Main:  parts[3] == "main",
+	}, nil
+}
+
+// AddCharacter adds or updates a member's character. A new main replaces the previous main, which becomes an
+// alt, and a member's first character is always their main.
+func AddCharacter(r *redis.Client, userID string, c Character) (Character, error) {
+	err := watchCharacters(r, userID, func(tx *redis.Tx, existing []Character) error {
+		hasMain := false
+		for _, other := range existing {
+			if other.Main && other.Key() != c.Key() {
+				hasMain = true
+			}
+		}
+
+		if !hasMain {
+			c.Main = true
+		}
+
+		_, err := tx.Pipelined(func(pipe redis.Pipeliner) error {
+			if c.Main {
+				for _, other := range existing {
+					if other.Main && other.Key() != c.Key() {
+						other.Main = false
+						pipe.HSet(CharactersKey(userID), other.Key(), encodeCharacter(other))
+					}
+				}
+
+				pipe.HSet(MainsKey, userID, encodeCharacter(c))
+			}
+
+			pipe.HSet(CharactersKey(userID), c.Key(), encodeCharacter(c))
+			pipe.SAdd(CharacterIndexKey, userID)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		return Character{}, fmt.Errorf("store character: %w", err)
+	}
+
+	return c, nil
+}
+
+// RemoveCharacter removes one of a member's characters. When the main is removed the remaining character
+// that sorts first becomes the main.
+func RemoveCharacter(r *redis.Client, userID string, name string) (Character, error) {
+	var removed *Character
+	err := watchCharacters(r, userID, func(tx *redis.Tx, existing []Character) error {
+		removed = nil
+		remaining := []Character{}
+		for i := range existing {
+			if existing[i].Key() == strings.ToLower(name) {
+				removed = &existing[i]
+			} else {
+				remaining = append(remaining, existing[i])
+			}
+		}
+
+		if removed == nil {
+			return ErrUnknownCharacter
+		}
+
+		_, err := tx.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.HDel(CharactersKey(userID), removed.Key())
+			if removed.Main {
+				pipe.HDel(MainsKey, userID)
+			}
+
+			if len(remaining) == 0 {
+				pipe.SRem(CharacterIndexKey, userID)
+			} else if removed.Main {
+				promoted := remaining[0]
+				promoted.Main = true
+				pipe.HSet(CharactersKey(userID), promoted.Key(), encodeCharacter(promoted))
+				pipe.HSet(MainsKey, userID, encodeCharacter(promoted))
+			}
+			return nil
+		})
+		return err
+	})
+	if errors.Is(err, ErrUnknownCharacter) {
+		return Character{}, err
+	} else if err != nil {
+		return Character{}, fmt.Errorf("remove character: %w", err)
+	}
+
+	return *removed, nil
+}
+
+// watchCharacters calls fn with the member's characters and retries it when they are changed before the
+// transaction fn writes with is executed, so that mains are never promoted or demoted from a stale read
+func watchCharacters(r *redis.Client, userID string, fn func(tx *redis.Tx, existing []Character) error) error {
+	for i := 0; i < maxCharacterRetries; i++ {
+		err := r.Watch(func(tx *redis.Tx) error {
+			values, err := tx.HGetAll(CharactersKey(userID)).Result()
+			if err != nil {
+				return fmt.Errorf("get characters: %w", err)
+			}
+
+			existing, err := decodeCharacters(values)
+			if err != nil {
+				return err
+			}
+
+			return fn(tx, existing)
+		}, CharactersKey(userID))
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+
+	return ErrConcurrentUpdate
+}
+
+// GetCharacters returns a member's characters, main first and then by name
+func GetCharacters(r *redis.Client, userID string) ([]Character, error) {
+	values, err := r.HGetAll(CharactersKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get characters: %w", err)
+	}
+
+	return decodeCharacters(values)
+}
+
+func decodeCharacters(values map[string]string) ([]Character, error) {
+	characters := []Character{}
+	for _, v := range values {
+		c, err := decodeCharacter(v)
+		if err != nil {
+			return nil, err
+		}
+		characters = append(characters, c)
+	}
+
+	sort.Slice(characters, func(i, j int) bool {
+		if characters[i].Main != characters[j].Main {
+			return characters[i].Main
+		}
+
+		return characters[i].Key() < characters[j].Key()
+	})
+
+	return characters, nil
+}
+
+// GetMainsFor returns the main character of each of the members that has one, keyed by member id
+func GetMainsFor(r *redis.Client, userIDs []string) (map[string]Character, error) {
+	mains := map[string]Character{}
+	if len(userIDs) == 0 {
+		return mains, nil
+	}
+
+	values, err := r.HMGet(MainsKey, userIDs...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("get main characters: %w", err)
+	}
+
+	for i, v := range values {
+		encoded, ok := v.(string)
+		if !ok {
+			continue
+		}
+
+		c, err := decodeCharacter(encoded)
+		if err != nil {
+			return nil, err
+		}
+		mains[userIDs[i]] = c
+	}
+
+	return mains, nil
+}
+
+// GetComposition counts the main characters of the guild's current members by role
+func GetComposition(r *redis.Client) (Composition, error) {
+	var mains *redis.StringStringMapCmd
+	var members *redis.StringSliceCmd
+	_, err := r.Pipelined(func(pipe redis.Pipeliner) error {
+		mains = pipe.HGetAll(MainsKey)
+		members = pipe.SMembers(MembersKey)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("get composition: %w", err)
+	}
+
+	current := map[string]bool{}
+	for _, userID := range members.Val() {
+		current[userID] = true
+	}
+
+	comp := Composition{}
+	for userID, encoded := range mains.Val() {
+		if !current[userID] {
+			continue
+		}
+
+		c, err := decodeCharacter(encoded)
+		if err != nil {
+			return nil, err
+		}
+		comp[c.Role()]++
+	}
+
+	return comp, nil
+}
+
+// AddMembers records that the users are members of the guild
+func AddMembers(r *redis.Client, userIDs []string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	err := r.SAdd(MembersKey, stringsToInterfaces(userIDs)...).Err()
+	if err != nil {
+		return fmt.Errorf("add members: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveMember records that the user left the guild
+func RemoveMember(r *redis.Client, userID string) error {
+	err := r.SRem(MembersKey, userID).Err()
+	if err != nil {
+		return fmt.Errorf("remove member: %w", err)
+	}
+
+	return nil
+}
+
+// StageMembers collects one chunk of the members Discord sends in response to a guild members request.
+// Discord sends the chunks in order, once the last one arrives the collected members replace the current
+// ones so that members who left while no replica was connected are dropped.
+func StageMembers(r *redis.Client, userIDs []string, index int, count int) error {
+	_, err := r.TxPipelined(func(pipe redis.Pipeliner) error {
+		if index == 0 {
+			pipe.Del(membersStagingKey)
+		}
+
+		if len(userIDs) > 0 {
+			pipe.SAdd(membersStagingKey, stringsToInterfaces(userIDs)...)
+		}
+
+		if index == count-1 {
+			pipe.SUnionStore(MembersKey, membersStagingKey)
+			pipe.Del(membersStagingKey)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("stage members: %w", err)
+	}
+
+	return nil
+}
+
+func stringsToInterfaces(values []string) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+
+	return result
+}
+
+// Composition counts characters by role
+type Composition map[Role]int
+
+// Without returns the composition less the roles of the given members' main characters
+func (c Composition) Without(mains map[string]Character, userIDs []string) Composition {
+	comp := Composition{}
+	for role, n := range c {
+		comp[role] = n
+	}
+
+	for _, userID := range userIDs {
+		main, ok := mains[userID]
+		if ok && comp[main.Role()] > 0 {
+			comp[main.Role()]--
+		}
+	}
+
+	return comp
+}
+
+// String describes the composition, for example "2 tank · 4 healer · 14 dps"
+func (c Composition) String() string {
+	parts := make([]string, len(Roles))
+	for i, role := range Roles {
+		parts[i] = fmt.Sprintf("%d %s", c[role], role)
+	}
+
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,307 @@
+package roster
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis"
+)
+
+func TestParseCharacterName(t *testing.T) {
+	cases := []struct {
+		name     string
+		input    string
+		expName  string
+		expRealm string
+		expErr   error
+	}{
+		{"name and realm", "Banana-Illidan", "Banana", "Illidan", nil},
+		{"realm with dash", "Phone-Area-52", "Phone", "Area-52", nil},
+		{"realm with apostrophe", "Ring-Kel'Thuzad", "Ring", "Kel'Thuzad", nil},
+		{"missing realm", "Banana", "", "", ErrInvalidCharacterName},
+		{"empty name", "-Illidan", "", "", ErrInvalidCharacterName},
+		{"digits in name", "B4nana-Illidan", "", "", ErrInvalidCharacterName},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			name, realm, err := ParseCharacterName(c.input)
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("expected '%v' got '%v'", c.expErr, err)
+			}
+
+			if name != c.expName || realm != c.expRealm {
+				t.Errorf("expected '%s' '%s' got '%s' '%s'", c.expName, c.expRealm, name, realm)
+			}
+		})
+	}
+}
+
+func TestParseClassAndSpec(t *testing.T) {
+	cases := []struct {
+		name    string
+		class   string
+		spec    string
+		expRole Role
+		expErr  error
+	}{
+		{"full names", "Mage", "Frost", DPS, nil},
+		{"abbreviations", "dk", "blood", Tank, nil},
+		{"dashed names", "demon-hunter", "vengeance", Tank, nil},
+		{"spec abbreviation", "druid", "resto", Healer, nil},
+		{"unknown class", "bard", "song", "", ErrUnknownClass},
+		{"spec of another class", "mage", "holy", "", ErrUnknownSpec},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			class, err := ParseClass(c.class)
+			if err == nil {
+				var spec string
+				spec, err = ParseSpec(class, c.spec)
+				if err == nil && (Character{Class: class, Spec: spec}).Role() != c.expRole {
+					t.Errorf("expected '%s' got '%s'", c.expRole, (Character{Class: class, Spec: spec}).Role())
+				}
+			}
+
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("expected '%v' got '%v'", c.expErr, err)
+			}
+		})
+	}
+}
+
+func TestCharacters(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	err = AddMembers(client, []string{"user", "healer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mage := Character{Name: "Banana", Realm: "Illidan", Class: "mage", Spec: "frost"}
+	added, err := AddCharacter(client, "user", mage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !added.Main {
+		t.Error("expected the first character to be the main")
+	}
+
+	tank := Character{Name: "Phone", Realm: "Area-52", Class: "deathknight", Spec: "blood", Main: true}
+	_, err = AddCharacter(client, "user", tank)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	characters, err := GetCharacters(client, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(characters) != 2 || characters[0].Key() != tank.Key() || !characters[0].Main || characters[1].Main {
+		t.Errorf("expected the new main to replace the old one got '%v'", characters)
+	}
+
+	_, err = AddCharacter(client, "healer", Character{Name: "Ring", Realm: "Illidan", Class: "priest", Spec: "holy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comp, err := GetComposition(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comp.String() != "1 tank · 1 healer · 0 dps" {
+		t.Errorf("expected '1 tank · 1 healer · 0 dps' got '%s'", comp)
+	}
+
+	mains, err := GetMainsFor(client, []string{"healer", "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mains) != 1 || mains["healer"].Name != "Ring" {
+		t.Errorf("expected only the main of the healer got '%v'", mains)
+	}
+
+	without := comp.Without(mains, []string{"healer"})
+	if without[Healer] != 0 || without[Tank] != 1 || comp[Healer] != 1 {
+		t.Errorf("expected excluded members not to be counted got '%s'", without)
+	}
+
+	removed, err := RemoveCharacter(client, "user", "phone-area-52")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed.Key() != tank.Key() {
+		t.Errorf("expected '%s' got '%s'", tank, removed)
+	}
+
+	characters, err = GetCharacters(client, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(characters) != 1 || !characters[0].Main {
+		t.Errorf("expected the remaining character to become the main got '%v'", characters)
+	}
+
+	comp, err = GetComposition(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comp.String() != "0 tank · 1 healer · 1 dps" {
+		t.Errorf("expected the promoted main to be counted got '%s'", comp)
+	}
+
+	_, err = RemoveCharacter(client, "user", "phone-area-52")
+	if !errors.Is(err, ErrUnknownCharacter) {
+		t.Errorf("expected '%v' got '%v'", ErrUnknownCharacter, err)
+	}
+
+	_, err = RemoveCharacter(client, "user", "banana-illidan")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if svc.Exists(CharactersKey("user")) {
+		t.Error("expected characters to be removed")
+	}
+
+	ok, err := client.SIsMember(CharacterIndexKey, "user").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok {
+		t.Error("expected the member to be removed from the index")
+	}
+
+	mains, err = GetMainsFor(client, []string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mains) != 0 {
+		t.Errorf("expected the member to have no main got '%v'", mains)
+	}
+}
+
+func TestCompositionCountsCurrentMembers(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	mains := map[string]Character{
+		"tank":   {Name: "Phone", Realm: "Illidan", Class: "deathknight", Spec: "blood"},
+		"healer": {Name: "Ring", Realm: "Illidan", Class: "priest", Spec: "holy"},
+		"left":   {Name: "Banana", Realm: "Illidan", Class: "mage", Spec: "frost"},
+	}
+	for userID, c := range mains {
+		_, err = AddCharacter(client, userID, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = AddMembers(client, []string{"tank", "healer", "left"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The member who left while no replica was connected is missing from the chunks sent after reconnecting
+	err = StageMembers(client, []string{"tank"}, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comp, err := GetComposition(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comp.String() != "1 tank · 1 healer · 1 dps" {
+		t.Errorf("expected members to be kept until the last chunk got '%s'", comp)
+	}
+
+	err = StageMembers(client, []string{"healer"}, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comp, err = GetComposition(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comp.String() != "1 tank · 1 healer · 0 dps" {
+		t.Errorf("expected '1 tank · 1 healer · 0 dps' got '%s'", comp)
+	}
+
+	err = RemoveMember(client, "healer")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comp, err = GetComposition(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if comp.String() != "1 tank · 0 healer · 0 dps" {
+		t.Errorf("expected '1 tank · 0 healer · 0 dps' got '%s'", comp)
+	}
+}
+
+func TestAddCharacterRetriesConcurrentChanges(t *testing.T) {
+	svc, err := miniredis.Run()
+	if err != nil {
+		panic(err)
+	}
+	defer svc.Close()
+
+	client := redis.NewClient(&redis.Options{
+		Addr: svc.Addr(),
+	})
+
+	_, err = AddCharacter(client, "user", Character{Name: "Banana", Realm: "Illidan", Class: "mage", Spec: "frost"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another main is added between reading the member's characters and writing the new main
+	racing := false
+	err = watchCharacters(client, "user", func(tx *redis.Tx, existing []Character) error {
+		if !racing {
+			racing = true
+			_, err := AddCharacter(client, "user", Character{Name: "Ring", Realm: "Illidan", Class: "priest", Spec: "holy", Main: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if len(existing) != 1 && len(existing) != 2 {
+			t.Errorf("expected the characters to be read again got '%v'", existing)
+		}
+
+		_, err := tx.Pipelined(func(pipe redis.Pipeliner) error {
+			pipe.Set("read", len(existing), 0)
+			return nil
+		})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v, _ := svc.Get("read"); v != "2" {
+		t.Errorf("expected the write to be retried with both characters got '%s'", v)
+	}
+}
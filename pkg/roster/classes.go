@@ -0,0 +1,108 @@
+package roster
+
+import (
+	"errors"
+	"strings"
+)
+
+var ErrUnknownClass = errors.New("unknown class")
+var ErrUnknownSpec = errors.New("unknown specialization for the class")
+
+// Role is the part a specialization plays in a group
+type Role string
+
+const (
+	Tank   Role = "tank"
+	Healer Role = "healer"
+	DPS    Role = "dps"
+)
+
+// Roles lists every role in the order they are displayed
+var Roles = []Role{Tank, Healer, DPS}
+
+// Class is a playable class and the roles of its specializations
+type Class struct {
+	Name  string
+	Specs map[string]Role
+}
+
+// Classes lists every playable class keyed by its normalized name
+var Classes = map[string]Class{
+	"deathknight": {"Death Knight", map[string]Role{"blood": Tank, "frost": DPS, "unholy": DPS}},
+	"demonhunter": {"Demon Hunter", map[string]Role{"havoc": DPS, "vengeance": Tank}},
+	"druid":       {"Druid", map[string]Role{"balance": DPS, "feral": DPS, "guardian": Tank, "restoration": Healer}},
+	"hunter":      {"Hunter", map[string]Role{"beastmastery": DPS, "marksmanship": DPS, "survival": DPS}},
+	"mage":        {"Mage", map[string]Role{"arcane": DPS, "fire": DPS, "frost": DPS}},
+	"monk":        {"Monk", map[string]Role{"brewmaster": Tank, "mistweaver": Healer, "windwalker": DPS}},
+	"paladin":     {"Paladin", map[string]Role{"holy": Healer, "protection": Tank, "retribution": DPS}},
+	"priest":      {"Priest", map[string]Role{"discipline": Healer, "holy": Healer, "shadow": DPS}},
+	"rogue":       {"Rogue", map[string]Role{"assassination": DPS, "outlaw": DPS, "subtlety": DPS}},
+	"shaman":      {"Shaman", map[string]Role{"elemental": DPS, "enhancement": DPS, "restoration": Healer}},
+	"warlock":     {"Warlock", map[string]Role{"affliction": DPS, "demonology": DPS, "destruction": DPS}},
+	"warrior":     {"Warrior", map[string]Role{"arms": DPS, "fury": DPS, "protection": Tank}},
+}
+
+var classAbbreviations = map[string]string{
+	"dk":    "deathknight",
+	"dh":    "demonhunter",
+	"pally": "paladin",
+	"lock":  "warlock",
+	"warr":  "warrior",
+}
+
+var specAbbreviations = map[string]string{
+	"bm":     "beastmastery",
+	"mm":     "marksmanship",
+	"surv":   "survival",
+	"prot":   "protection",
+	"ret":    "retribution",
+	"resto":  "restoration",
+	"disc":   "discipline",
+	"sin":    "assassination",
+	"sub":    "subtlety",
+	"ele":    "elemental",
+	"enh":    "enhancement",
+	"aff":    "affliction",
+	"demo":   "demonology",
+	"destro": "destruction",
+	"brew":   "brewmaster",
+	"mw":     "mistweaver",
+	"ww":     "windwalker",
+	"boomy":  "balance",
+	"bear":   "guardian",
+	"cat":    "feral",
+	"veng":   "vengeance",
+}
+
+func normalize(s string) string {
+	return strings.NewReplacer("-", "", "_", "", " ", "", "'", "").Replace(strings.ToLower(s))
+}
+
+// ParseClass returns the normalized name of a class from its full name or a common abbreviation such as "dk"
+func ParseClass(s string) (string, error) {
+	key := normalize(s)
+	if full, ok := classAbbreviations[key]; ok {
+		key = full
+	}
+
+	if _, ok := Classes[key]; !ok {
+		return "", ErrUnknownClass
+	}
+
+	return key, nil
+}
+
+// ParseSpec returns the normalized name of one of the class's specializations from its full name or a common
+// abbreviation such as "resto"
+func ParseSpec(class string, s string) (string, error) {
+	key := normalize(s)
+	if full, ok := specAbbreviations[key]; ok {
+		key = full
+	}
+
+	if _, ok := Classes[class].Specs[key]; !ok {
+		return "", ErrUnknownSpec
+	}
+
+	return key, nil
+}
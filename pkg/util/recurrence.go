@@ -0,0 +1,136 @@
+package util
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrInvalidWeekday = errors.New("invalid weekday")
+var ErrMissingWeekday = errors.New("at least one weekday must be provided")
+
+// Recurrence describes a weekly repeating set of days, optionally ending on a given date
+type Recurrence struct {
+	Weekdays []time.Weekday
+	Until    time.Time
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"sun":       time.Sunday,
+	"monday":    time.Monday,
+	"mon":       time.Monday,
+	"tuesday":   time.Tuesday,
+	"tue":       time.Tuesday,
+	"tues":      time.Tuesday,
+	"wednesday": time.Wednesday,
+	"wed":       time.Wednesday,
+	"weds":      time.Wednesday,
+	"thursday":  time.Thursday,
+	"thu":       time.Thursday,
+	"thur":      time.Thursday,
+	"thurs":     time.Thursday,
+	"friday":    time.Friday,
+	"fri":       time.Friday,
+	"saturday":  time.Saturday,
+	"sat":       time.Saturday,
+}
+
+// ParseWeekday returns the weekday for a full or abbreviated day name. Plural forms such as
+// "wednesdays" are also accepted.
+func ParseWeekday(s string) (time.Weekday, error) {
+	s = strings.ToLower(strings.Trim(s, ",."))
+	if day, ok := weekdayNames[s]; ok {
+		return day, nil
+	}
+
+	if day, ok := weekdayNames[strings.TrimSuffix(s, "s")]; ok {
+		return day, nil
+	}
+
+	return time.Sunday, ErrInvalidWeekday
+}
+
+// FlagsToRecurrence returns a Recurrence from a slice of text flags. The flags are expected in the form
+// ["wednesday", "friday", "until", "Sep", "1"] where any number of weekdays may be provided, optionally
+// separated by "and", and the "until" clause is optional. When provided the recurrence ends at 23:59:59
+// on the until date.
+func FlagsToRecurrence(flags []string) (Recurrence, error) {
+	untilIdx := len(flags)
+	for i, f := range flags {
+		if strings.ToLower(f) == "until" {
+			untilIdx = i
+			break
+		}
+	}
+
+	r := Recurrence{}
+	for _, f := range flags[:untilIdx] {
+		if strings.ToLower(f) == "and" {
+			continue
+		}
+
+		for _, name := range strings.Split(f, ",") {
+			if name == "" {
+				continue
+			}
+
+			day, err := ParseWeekday(name)
+			if err != nil {
+				return Recurrence{}, &ParseError{
+					Input: f,
+					Err:   err,
+				}
+			}
+
+			r.Weekdays = append(r.Weekdays, day)
+		}
+	}
+
+	if len(r.Weekdays) == 0 {
+		return Recurrence{}, &ParseError{
+			Input: strings.Join(flags[:untilIdx], " "),
+			Err:   ErrMissingWeekday,
+		}
+	}
+
+	if untilIdx == len(flags) {
+		return r, nil
+	}
+
+	untilFlags := flags[untilIdx+1:]
+	if len(untilFlags) == 0 {
+		return Recurrence{}, &ParseError{
+			Input: "",
+			Err:   errors.New("missing date after 'until'"),
+		}
+	}
+
+	until, err := FlagsToDateRange(untilFlags)
+	var parseErr *ParseError
+	if errors.As(err, &parseErr) && parseErr.Suggestion != "" {
+		parseErr.Suggestion = strings.Join(flags[:untilIdx+1], " ") + " " + parseErr.Suggestion
+		return Recurrence{}, parseErr
+	} else if err != nil {
+		return Recurrence{}, err
+	}
+
+	r.Until = until.End
+	return r, nil
+}
+
+// Includes returns true when the provided time falls on one of the recurrence's weekdays and is not after
+// the until date.
+func (r Recurrence) Includes(t time.Time) bool {
+	if !r.Until.IsZero() && t.After(r.Until) {
+		return false
+	}
+
+	for _, day := range r.Weekdays {
+		if t.Weekday() == day {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,168 @@
+package util
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrUnknownExpression = errors.New("unknown relative date expression")
+var ErrInvalidDuration = errors.New("invalid duration")
+
+var numberWords = map[string]int{
+	"a":     1,
+	"an":    1,
+	"one":   1,
+	"two":   2,
+	"three": 3,
+	"four":  4,
+	"five":  5,
+	"six":   6,
+	"seven": 7,
+	"eight": 8,
+	"nine":  9,
+	"ten":   10,
+}
+
+// ParseRelativeDate resolves a relative date expression against the provided time. The supported forms are:
+// 1. "today", "tonight" and "tomorrow"
+// 2. "<weekday>" or "this <weekday>" for the next occurrence of the weekday, including today
+// 3. "next <weekday>" for the weekday in the following week
+// 4. "this week", "next week", "this weekend" and "next weekend"
+// 5. "next <n> days|weeks" for a range beginning today
+// 6. "in <n> days|weeks" for a single day in the future
+// The returned range always begins at 00:00:00 and ends at 23:59:59 in the timezone of now.
+func ParseRelativeDate(words []string, now time.Time) (DateRange, error) {
+	words = normalizeWords(words)
+	today := BeginningOfDay(now)
+	switch len(words) {
+	case 1:
+		switch words[0] {
+		case "today", "tonight":
+			return dayRange(today), nil
+		case "tomorrow", "tmrw", "tmr":
+			return dayRange(today.AddDate(0, 0, 1)), nil
+		}
+
+		if day, err := ParseWeekday(words[0]); err == nil {
+			return dayRange(nextWeekday(today, day)), nil
+		}
+	case 2:
+		switch words[0] + " " + words[1] {
+		case "this week":
+			return DateRange{Begin: BeginningOfWeek(now), End: EndOfWeek(now)}, nil
+		case "next week":
+			next := BeginningOfWeek(now).AddDate(0, 0, 7)
+			return DateRange{Begin: next, End: EndOfWeek(next)}, nil
+		case "this weekend":
+			return weekendRange(today), nil
+		case "next weekend":
+			saturday := nextWeekday(today, time.Saturday)
+			if today.Weekday() != time.Sunday {
+				saturday = saturday.AddDate(0, 0, 7)
+			}
+
+			return DateRange{Begin: saturday, End: EndOfDay(saturday.AddDate(0, 0, 1))}, nil
+		}
+
+		day, err := ParseWeekday(words[1])
+		if err != nil {
+			break
+		}
+
+		switch words[0] {
+		case "this", "on", "coming":
+			return dayRange(nextWeekday(today, day)), nil
+		case "next":
+			return dayRange(DayOfWeek(BeginningOfWeek(today).AddDate(0, 0, 7), day)), nil
+		}
+	case 3:
+		switch words[0] {
+		case "next", "for":
+			return ParseDuration(today, words[1:])
+		case "in":
+			r, err := ParseDuration(today, words[1:])
+			if err != nil {
+				return DateRange{}, err
+			}
+
+			return dayRange(r.End.AddDate(0, 0, 1)), nil
+		}
+	}
+
+	return DateRange{}, ErrUnknownExpression
+}
+
+// ParseDuration returns a range beginning at the start of the provided day that lasts for the duration
+// described by words, for example ["2", "weeks"] or ["a", "day"].
+func ParseDuration(begin time.Time, words []string) (DateRange, error) {
+	words = normalizeWords(words)
+	if len(words) != 2 {
+		return DateRange{}, ErrInvalidDuration
+	}
+
+	n, ok := numberWords[words[0]]
+	if !ok {
+		var err error
+		n, err = strconv.Atoi(words[0])
+		if err != nil || n <= 0 {
+			return DateRange{}, ErrInvalidDuration
+		}
+	}
+
+	var days int
+	switch strings.TrimSuffix(words[1], "s") {
+	case "day":
+		days = n
+	case "week", "wk":
+		days = n * 7
+	default:
+		return DateRange{}, ErrInvalidDuration
+	}
+
+	begin = BeginningOfDay(begin)
+	return DateRange{
+		Begin: begin,
+		End:   EndOfDay(begin.AddDate(0, 0, days-1)),
+	}, nil
+}
+
+func normalizeWords(words []string) []string {
+	normalized := make([]string, 0, len(words))
+	for _, w := range words {
+		w = strings.ToLower(strings.Trim(w, ",."))
+		if w != "" {
+			normalized = append(normalized, w)
+		}
+	}
+
+	return normalized
+}
+
+func dayRange(t time.Time) DateRange {
+	return DateRange{
+		Begin: BeginningOfDay(t),
+		End:   EndOfDay(t),
+	}
+}
+
+// nextWeekday returns the first occurrence of the weekday on or after the provided day
+func nextWeekday(t time.Time, day time.Weekday) time.Time {
+	offset := (int(day) - int(t.Weekday()) + 7) % 7
+	return t.AddDate(0, 0, offset)
+}
+
+// weekendRange returns the upcoming Saturday and Sunday. On a Sunday the weekend is already underway so only
+// the current day is returned.
+func weekendRange(t time.Time) DateRange {
+	if t.Weekday() == time.Sunday {
+		return dayRange(t)
+	}
+
+	saturday := nextWeekday(t, time.Saturday)
+	return DateRange{
+		Begin: BeginningOfDay(saturday),
+		End:   EndOfDay(saturday.AddDate(0, 0, 1)),
+	}
+}
@@ -9,9 +9,15 @@ import (
 	"github.com/araddon/dateparse"
 )
 
+var ErrInvertedRange = errors.New("the end date is before the begin date")
+var ErrPastRange = errors.New("the dates are in the past")
+
+// ParseError is returned when user input cannot be turned into a date. When the input was understood but
+// rejected, Suggestion holds a corrected form of the input that would be accepted.
 type ParseError struct {
-	Input string
-	Err   error
+	Input      string
+	Err        error
+	Suggestion string
 }
 
 func (e *ParseError) Error() string {
@@ -32,70 +38,196 @@ func (e *ParseError) Is(err error) bool {
 // 1. [] where the range is assumed to be the current week starting Sunday at 00:00:00 and ending the following Saturday at 23:59:59
 // 2. ["12/20"] where the range is assumed to be a single day beginning at 00:00:00 and ending at 23:59:59
 // 3. ["12/20", "to", "12/21"] where the date is assumed to be 00:00:00 on the starting date to 23:59:59 on the final date
+// 4. ["12/20", "for", "2", "weeks"] where the range begins on the date and lasts for the duration
 // This method also supports dates that are split into multiple words. For example ["Dec", "20", "2020"] will be joined and parsed as a single word "Dec 20 2020"
+// Each date may also be a relative expression such as "tomorrow" or "next wed", see ParseRelativeDate for
+// the supported forms.
+// Dates without a year fall in the current year, or the following year when their month has already passed,
+// and the end of a range without a year is moved into the following year when it would otherwise come before
+// the beginning. Ranges that end before they begin or that lie entirely in the past are rejected with a
+// ParseError. Earlier days of the current month are not rolled over, on Aug 25 2021 "aug 20" is rejected as
+// past with the suggestion "Aug 20 2022".
 func FlagsToDateRange(flags []string) (DateRange, error) {
+	return FlagsToDateRangeAt(flags, time.Now().UTC())
+}
+
+// FlagsToDateRangeAt behaves as FlagsToDateRange with relative expressions resolved against the provided time
+func FlagsToDateRangeAt(flags []string, now time.Time) (DateRange, error) {
 	// Form #1 no dates provided
 	if len(flags) == 0 {
-		now := time.Now().UTC()
 		return DateRange{
 			Begin: BeginningOfWeek(now),
 			End:   EndOfWeek(now),
 		}, nil
 	}
 
+	r, err := resolveRange(flags, now)
+	if err != nil {
+		return DateRange{}, err
+	}
+
+	err = validateRange(r, strings.Join(flags, " "), now)
+	if err != nil {
+		return DateRange{}, err
+	}
+
+	return r.DateRange, nil
+}
+
+// resolvedDate is a range produced from user input along with how it was interpreted
+type resolvedDate struct {
+	DateRange
+	Relative     bool
+	ExplicitYear bool
+}
+
+func resolveRange(flags []string, now time.Time) (resolvedDate, error) {
 	toIdx := -1
+	forIdx := -1
 	for i, f := range flags {
-		if strings.ToLower(f) == "to" {
+		switch strings.ToLower(f) {
+		case "to", "until", "through", "thru":
 			toIdx = i
+		case "for":
+			forIdx = i
 		}
 	}
 
-	// Form #2 singular date provided
-	if toIdx == -1 {
-		dateStr := strings.Join(flags, " ")
-		d, err := dateparse.ParseAny(dateStr)
+	// Form #4 with a duration, a leading "for" is handled as a relative expression beginning today
+	if forIdx > 0 && toIdx == -1 {
+		begin, err := resolveDate(flags[:forIdx], now)
 		if err != nil {
-			return DateRange{}, &ParseError{
-				Input: dateStr,
+			return resolvedDate{}, err
+		}
+
+		r, err := ParseDuration(begin.Begin, flags[forIdx+1:])
+		if err != nil {
+			return resolvedDate{}, &ParseError{
+				Input: strings.Join(flags[forIdx+1:], " "),
 				Err:   err,
 			}
 		}
 
-		d = RelativeDefaults(d)
-		return DateRange{
-			Begin: BeginningOfDay(d),
-			End:   EndOfDay(d),
-		}, nil
+		begin.DateRange = r
+		return begin, nil
+	}
+
+	// Form #2 singular date provided
+	if toIdx == -1 {
+		return resolveDate(flags, now)
 	}
 
 	// Form #3 with date range
-	beginStr := strings.Join(flags[:toIdx], " ")
-	endStr := strings.Join(flags[toIdx+1:], " ")
+	begin, err := resolveDate(flags[:toIdx], now)
+	if err != nil {
+		return resolvedDate{}, err
+	}
 
-	begin, err := dateparse.ParseAny(beginStr)
+	end, err := resolveDate(flags[toIdx+1:], now)
 	if err != nil {
-		return DateRange{}, &ParseError{
-			Input: beginStr,
+		return resolvedDate{}, err
+	}
+
+	// A range such as "dec 20 to jan 5" wraps into the next year
+	if !end.Relative && !end.ExplicitYear && end.End.Before(begin.Begin) {
+		end.DateRange = DateRange{
+			Begin: BeginningOfDay(end.Begin.AddDate(1, 0, 0)),
+			End:   EndOfDay(end.Begin.AddDate(1, 0, 0)),
+		}
+	}
+
+	return resolvedDate{
+		DateRange: DateRange{
+			Begin: begin.Begin,
+			End:   end.End,
+		},
+		Relative:     begin.Relative && end.Relative,
+		ExplicitYear: begin.ExplicitYear || end.ExplicitYear,
+	}, nil
+}
+
+// resolveDate returns the range covered by a single date expression, trying relative expressions before
+// falling back to absolute dates
+func resolveDate(words []string, now time.Time) (resolvedDate, error) {
+	r, err := ParseRelativeDate(words, now)
+	if err == nil {
+		return resolvedDate{DateRange: r, Relative: true}, nil
+	} else if !errors.Is(err, ErrUnknownExpression) {
+		return resolvedDate{}, &ParseError{
+			Input: strings.Join(words, " "),
 			Err:   err,
 		}
 	}
 
-	end, err := dateparse.ParseAny(endStr)
+	dateStr := strings.Join(words, " ")
+	d, err := dateparse.ParseAny(dateStr)
 	if err != nil {
-		return DateRange{}, &ParseError{
-			Input: endStr,
+		return resolvedDate{}, &ParseError{
+			Input: dateStr,
 			Err:   err,
 		}
 	}
 
-	begin = RelativeDefaults(begin)
-	end = RelativeDefaults(end)
-	return DateRange{
-		Begin: BeginningOfDay(begin),
-		End:   EndOfDay(end),
+	explicitYear := d.Year() != 0
+	d = relativeDefaults(d, now)
+	return resolvedDate{
+		DateRange: DateRange{
+			Begin: BeginningOfDay(d),
+			End:   EndOfDay(d),
+		},
+		ExplicitYear: explicitYear,
 	}, nil
 }
 
+// validateRange rejects ranges that are inverted or entirely in the past, suggesting a corrected range
+// where one can be inferred
+func validateRange(r resolvedDate, input string, now time.Time) error {
+	if r.End.Before(r.Begin) {
+		return &ParseError{
+			Input: input,
+			Err:   ErrInvertedRange,
+			Suggestion: FormatDateRange(DateRange{
+				Begin: BeginningOfDay(r.End),
+				End:   EndOfDay(r.Begin),
+			}),
+		}
+	}
+
+	today := BeginningOfDay(now)
+	if !r.End.Before(today) {
+		return nil
+	}
+
+	err := &ParseError{
+		Input: input,
+		Err:   ErrPastRange,
+	}
+
+	if !r.Relative {
+		years := today.Year() - r.End.Year()
+		if r.End.AddDate(years, 0, 0).Before(today) {
+			years++
+		}
+
+		err.Suggestion = FormatDateRange(DateRange{
+			Begin: r.Begin.AddDate(years, 0, 0),
+			End:   r.End.AddDate(years, 0, 0),
+		})
+	}
+
+	return err
+}
+
+// FormatDateRange returns the range in a form accepted by FlagsToDateRange
+func FormatDateRange(r DateRange) string {
+	const format = "Jan 2 2006"
+	if BeginningOfDay(r.Begin).Equal(BeginningOfDay(r.End)) {
+		return r.Begin.Format(format)
+	}
+
+	return fmt.Sprintf("%s to %s", r.Begin.Format(format), r.End.Format(format))
+}
+
 // DayOfWeek returns a new time with the same wall time for the specified day of the week
 func DayOfWeek(t time.Time, weekday time.Weekday) time.Time {
 	begin := BeginningOfWeek(t)
@@ -130,17 +262,20 @@ func EndOfWeek(t time.Time) time.Time {
 }
 
 // RelativeDefaults returns a new time defaulted with the current date and time. This is useful for when a
-// date is supplied with no month or year provided.
+// date is supplied with no month or year provided. Dates without a year in a month that has already passed
+// are moved to the following year, dates with an explicit year are left unchanged.
 func RelativeDefaults(t time.Time) time.Time {
-	now := time.Now().UTC()
+	return relativeDefaults(t, time.Now().UTC())
+}
+
+func relativeDefaults(t time.Time, now time.Time) time.Time {
 	year, month, day := t.Date()
 	hour, minute, second := t.Clock()
 	if year == 0 {
 		year = now.Year()
-	}
-
-	if month < now.Month() {
-		year = year + 1
+		if month < now.Month() {
+			year = year + 1
+		}
 	}
 
 	return time.Date(year, month, day, hour, minute, second, t.Nanosecond(), t.Location())
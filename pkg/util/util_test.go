@@ -53,13 +53,10 @@ func TestFlagsToDateRange(t *testing.T) {
 			nil,
 		},
 		{
-			"with singular date 'dec 20 2010' fmt",
+			"err with past date 'dec 20 2010' fmt",
 			[]string{"dec", "20", "2010"},
-			DateRange{
-				time.Date(2010, 12, 20, 0, 0, 0, 0, time.UTC),
-				time.Date(2010, 12, 21, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
-			},
-			nil,
+			DateRange{},
+			&ParseError{Input: "dec 20 2010"},
 		},
 		{
 			"with date range '12/20 to 12/25' fmt",
@@ -308,3 +305,308 @@ func TestRelativeDefaults(t *testing.T) {
 		})
 	}
 }
+
+func TestFlagsToRecurrence(t *testing.T) {
+	now := time.Now().UTC()
+	cases := []struct {
+		name          string
+		flags         []string
+		expRecurrence Recurrence
+		expErr        error
+	}{
+		{
+			"single weekday",
+			[]string{"wednesday"},
+			Recurrence{Weekdays: []time.Weekday{time.Wednesday}},
+			nil,
+		},
+		{
+			"abbreviated and plural weekdays",
+			[]string{"wed", "and", "fridays"},
+			Recurrence{Weekdays: []time.Weekday{time.Wednesday, time.Friday}},
+			nil,
+		},
+		{
+			"comma separated weekdays",
+			[]string{"tue,thurs"},
+			Recurrence{Weekdays: []time.Weekday{time.Tuesday, time.Thursday}},
+			nil,
+		},
+		{
+			"with until date",
+			[]string{"wednesday", "until", "dec", "20"},
+			Recurrence{
+				Weekdays: []time.Weekday{time.Wednesday},
+				Until:    time.Date(now.Year(), 12, 21, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+			},
+			nil,
+		},
+		{
+			"err with no weekday",
+			[]string{"until", "dec", "20"},
+			Recurrence{},
+			&ParseError{Input: ""},
+		},
+		{
+			"err with invalid weekday",
+			[]string{"someday"},
+			Recurrence{},
+			&ParseError{Input: "someday"},
+		},
+		{
+			"err with missing until date",
+			[]string{"wednesday", "until"},
+			Recurrence{},
+			&ParseError{Input: ""},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := FlagsToRecurrence(c.flags)
+			if !errors.Is(err, c.expErr) {
+				t.Error("did not return the expected error")
+				return
+			}
+
+			if !reflect.DeepEqual(r, c.expRecurrence) {
+				t.Errorf("did not return the correct recurrence, wanted '%v', got '%v'", c.expRecurrence, r)
+			}
+		})
+	}
+}
+
+func TestFlagsToDateRangeRelative(t *testing.T) {
+	// Wednesday
+	now := time.Date(2021, 8, 25, 15, 30, 0, 0, time.UTC)
+	day := func(month time.Month, day int) DateRange {
+		return DateRange{
+			time.Date(2021, month, day, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, month, day+1, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+		}
+	}
+	span := func(beginMonth time.Month, beginDay int, endMonth time.Month, endDay int) DateRange {
+		return DateRange{
+			time.Date(2021, beginMonth, beginDay, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, endMonth, endDay+1, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+		}
+	}
+
+	cases := []struct {
+		name     string
+		flags    []string
+		expRange DateRange
+		expErr   error
+	}{
+		{"today", []string{"today"}, day(8, 25), nil},
+		{"tonight", []string{"tonight"}, day(8, 25), nil},
+		{"tomorrow", []string{"tomorrow"}, day(8, 26), nil},
+		{"tomorrow mixed case", []string{"Tomorrow"}, day(8, 26), nil},
+		{"err yesterday", []string{"yesterday"}, DateRange{}, &ParseError{Input: "yesterday"}},
+		{"weekday is today", []string{"wednesday"}, day(8, 25), nil},
+		{"weekday later this week", []string{"fri"}, day(8, 27), nil},
+		{"weekday already passed", []string{"monday"}, day(8, 30), nil},
+		{"this weekday", []string{"this", "thursday"}, day(8, 26), nil},
+		{"next weekday", []string{"next", "wed"}, day(9, 1), nil},
+		{"next weekday early in week", []string{"next", "sunday"}, day(8, 29), nil},
+		{"this week", []string{"this", "week"}, span(8, 22, 8, 28), nil},
+		{"next week", []string{"next", "week"}, span(8, 29, 9, 4), nil},
+		{"this weekend", []string{"this", "weekend"}, span(8, 28, 8, 29), nil},
+		{"next weekend", []string{"next", "weekend"}, span(9, 4, 9, 5), nil},
+		{"next n days", []string{"next", "3", "days"}, span(8, 25, 8, 27), nil},
+		{"next n weeks", []string{"next", "2", "weeks"}, span(8, 25, 9, 7), nil},
+		{"for n weeks", []string{"for", "2", "weeks"}, span(8, 25, 9, 7), nil},
+		{"for a week", []string{"for", "a", "week"}, span(8, 25, 8, 31), nil},
+		{"in n days", []string{"in", "two", "days"}, day(8, 27), nil},
+		{"in a week", []string{"in", "a", "week"}, day(9, 1), nil},
+		{"date for duration", []string{"sep", "1", "for", "3", "days"}, span(9, 1, 9, 3), nil},
+		{"relative date for duration", []string{"next", "wed", "for", "1", "week"}, span(9, 1, 9, 7), nil},
+		{"relative range", []string{"tomorrow", "to", "next", "tue"}, span(8, 26, 8, 31), nil},
+		{"mixed range", []string{"friday", "to", "sep", "3"}, span(8, 27, 9, 3), nil},
+		{"until range", []string{"today", "until", "saturday"}, span(8, 25, 8, 28), nil},
+		{"falls back to absolute date", []string{"9/10"}, day(9, 10), nil},
+		{"err invalid duration", []string{"sep", "1", "for", "many", "weeks"}, DateRange{}, &ParseError{Input: "many weeks"}},
+		{"err invalid duration unit", []string{"in", "3", "fortnights"}, DateRange{}, &ParseError{Input: "in 3 fortnights"}},
+		{"err unknown expression", []string{"someday"}, DateRange{}, &ParseError{Input: "someday"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := FlagsToDateRangeAt(c.flags, now)
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("did not return the expected error, got '%v'", err)
+				return
+			}
+
+			if !reflect.DeepEqual(r, c.expRange) {
+				t.Errorf("did not return the correct date range, wanted '{Begin: %s, End: %s}', got '{Begin: %s, End: %s}'", c.expRange.Begin, c.expRange.End, r.Begin, r.End)
+			}
+		})
+	}
+}
+
+func TestFlagsToDateRangeRelativeOnSunday(t *testing.T) {
+	now := time.Date(2021, 8, 29, 9, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		flags    []string
+		expBegin time.Time
+		expEnd   time.Time
+	}{
+		{
+			"this weekend is today",
+			[]string{"this", "weekend"},
+			time.Date(2021, 8, 29, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, 8, 30, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+		},
+		{
+			"next weekend is the upcoming saturday",
+			[]string{"next", "weekend"},
+			time.Date(2021, 9, 4, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, 9, 6, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+		},
+		{
+			"next weekday is in the following week",
+			[]string{"next", "monday"},
+			time.Date(2021, 9, 6, 0, 0, 0, 0, time.UTC),
+			time.Date(2021, 9, 7, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := FlagsToDateRangeAt(c.flags, now)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if !r.Begin.Equal(c.expBegin) || !r.End.Equal(c.expEnd) {
+				t.Errorf("did not return the correct date range, wanted '{Begin: %s, End: %s}', got '{Begin: %s, End: %s}'", c.expBegin, c.expEnd, r.Begin, r.End)
+			}
+		})
+	}
+}
+
+func TestFlagsToDateRangeValidation(t *testing.T) {
+	cases := []struct {
+		name          string
+		now           time.Time
+		flags         []string
+		expRange      DateRange
+		expErr        error
+		expSuggestion string
+	}{
+		{
+			"explicit future year is unchanged",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"jan", "5", "2022"},
+			DateRange{
+				time.Date(2022, 1, 5, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, 1, 6, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+			},
+			nil,
+			"",
+		},
+		{
+			"implicit year in a past month is next year",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"mar", "1"},
+			DateRange{
+				time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2022, 3, 2, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+			},
+			nil,
+			"",
+		},
+		{
+			"range without years wraps into next year in january",
+			time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC),
+			[]string{"dec", "20", "to", "jan", "5"},
+			DateRange{
+				time.Date(2022, 12, 20, 0, 0, 0, 0, time.UTC),
+				time.Date(2023, 1, 6, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+			},
+			nil,
+			"",
+		},
+		{
+			"range with explicit end year does not wrap",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"sep", "5", "to", "sep", "1", "2021"},
+			DateRange{},
+			&ParseError{Input: "sep 5 to sep 1 2021"},
+			"Sep 1 2021 to Sep 5 2021",
+		},
+		{
+			"err inverted range",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"aug", "30", "to", "aug", "27", "2021"},
+			DateRange{},
+			&ParseError{Input: "aug 30 to aug 27 2021"},
+			"Aug 27 2021 to Aug 30 2021",
+		},
+		{
+			"err past date with explicit year",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"mar", "1", "2021"},
+			DateRange{},
+			&ParseError{Input: "mar 1 2021"},
+			"Mar 1 2022",
+		},
+		{
+			"err past date earlier this month",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"aug", "20"},
+			DateRange{},
+			&ParseError{Input: "aug 20"},
+			"Aug 20 2022",
+		},
+		{
+			"err past range several years ago",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"dec", "20", "2010", "to", "dec", "30", "2010"},
+			DateRange{},
+			&ParseError{Input: "dec 20 2010 to dec 30 2010"},
+			"Dec 20 2021 to Dec 30 2021",
+		},
+		{
+			"err yesterday is not a date",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"yesterday"},
+			DateRange{},
+			&ParseError{Input: "yesterday"},
+			"",
+		},
+		{
+			"range partially in the past is accepted",
+			time.Date(2021, 8, 25, 0, 0, 0, 0, time.UTC),
+			[]string{"aug", "20", "to", "aug", "30"},
+			DateRange{
+				time.Date(2021, 8, 20, 0, 0, 0, 0, time.UTC),
+				time.Date(2021, 8, 31, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond),
+			},
+			nil,
+			"",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := FlagsToDateRangeAt(c.flags, c.now)
+			if !errors.Is(err, c.expErr) {
+				t.Errorf("did not return the expected error, got '%v'", err)
+				return
+			}
+
+			var parseErr *ParseError
+			if errors.As(err, &parseErr) && parseErr.Suggestion != c.expSuggestion {
+				t.Errorf("did not return the expected suggestion, wanted '%s', got '%s'", c.expSuggestion, parseErr.Suggestion)
+			}
+
+			if !reflect.DeepEqual(r, c.expRange) {
+				t.Errorf("did not return the correct date range, wanted '{Begin: %s, End: %s}', got '{Begin: %s, End: %s}'", c.expRange.Begin, c.expRange.End, r.Begin, r.End)
+			}
+		})
+	}
+}